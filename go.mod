@@ -15,6 +15,7 @@ require (
 
 // github.com dependencies
 require (
+	github.com/expr-lang/expr v1.17.8
 	github.com/google/go-cmp v0.6.0
 	github.com/iancoleman/strcase v0.3.0
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/friendly-fhir/fhenix/internal/snek"
+	"github.com/friendly-fhir/fhenix/pkg/config"
+	"github.com/friendly-fhir/fhenix/pkg/driver"
+	"github.com/friendly-fhir/fhenix/pkg/filter"
+	"github.com/friendly-fhir/fhenix/pkg/model"
+	"github.com/friendly-fhir/fhenix/pkg/registry"
+)
+
+type ExplainCommand struct {
+	Root      string
+	Vars      varsFlag
+	FHIRCache string
+	Timeout   time.Duration
+	Verbose   bool
+
+	snek.BaseCommand
+}
+
+func (ec *ExplainCommand) Info() *snek.CommandInfo {
+	return &snek.CommandInfo{
+		Use:     "explain <fhenix config> <canonical url>",
+		Summary: "Explain why an entity is or isn't transformed",
+		Description: snek.Lines(
+			fmt.Sprintf("Load the model of the specified %v file, and explain whether each", snek.FormatKeyword.Format("fhenix config")),
+			"transformation would transform the entity with the given canonical URL.",
+			"",
+			"Every include and exclude filter of each transformation is shown, along with",
+			"whether each of its keys matched, and any errors from condition templates or",
+			"queries. Structure definitions of the base FHIR package may also be named",
+			"without their URL, such as 'Patient'.",
+		),
+		Examples: snek.Examples(
+			"fhenix explain fhenix.yaml http://hl7.org/fhir/StructureDefinition/Patient",
+			"fhenix explain fhenix.yaml Patient --set go.package=fhir",
+		),
+	}
+}
+
+func (ec *ExplainCommand) PositionalArgs() snek.PositionalArgs {
+	return snek.ExactArgs(2)
+}
+
+func (ec *ExplainCommand) Flags() []*snek.FlagSet {
+	explain := snek.NewFlagSet("Explain")
+	explain.String(&ec.Root, "root", "", "The root directory to consider all paths relative to")
+	explain.Var("set", &ec.Vars, "Override a config variable, as 'key=value'; nested variables use dotted keys")
+	explain.String(&ec.FHIRCache, "fhir-cache", "", "The configuration path to download the FHIR IGs to")
+	explain.DurationP(&ec.Timeout, "timeout", "t", 0, "Timeout for the download")
+	explain.BoolP(&ec.Verbose, "verbose", "v", false, "Enable verbose output")
+
+	return []*snek.FlagSet{
+		explain,
+	}
+}
+
+func (ec *ExplainCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return snek.UsageError("expected exactly two arguments")
+	}
+
+	var cfgopts []config.Option
+	if ec.Root != "" {
+		cfgopts = append(cfgopts, config.WithRootDir(ec.Root))
+	}
+	if len(ec.Vars) > 0 {
+		cfgopts = append(cfgopts, config.WithVars(ec.Vars))
+	}
+	cfg, err := config.FromFile(args[0], cfgopts...)
+	if err != nil {
+		return err
+	}
+
+	cache := registry.DefaultCache()
+	if ec.FHIRCache != "" {
+		cache = registry.NewCache(ec.FHIRCache)
+	}
+	if timeout := ec.Timeout; timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// The explanation is printed to stdout, so progress is logged to stderr.
+	d, err := driver.New(cfg,
+		driver.Cache(cache),
+		driver.Listeners(NewLogListener(snek.CommandErr(ctx), ec.Verbose)),
+	)
+	if err != nil {
+		return err
+	}
+	m, transforms, err := d.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	entity, name, err := lookupEntity(m, args[1])
+	if err != nil {
+		return err
+	}
+
+	out := snek.CommandOut(ctx)
+	fmt.Fprintf(out, "%s\n", name)
+	for i, t := range transforms {
+		fmt.Fprintln(out)
+		explanation := t.Explain(entity)
+		writeExplanation(out, i, t.Name(), explanation.Matched, explanation.Include, explanation.Exclude)
+	}
+	return nil
+}
+
+// lookupEntity returns the type or code system of the model with the given
+// URL, along with a display name that includes the package it is defined in.
+func lookupEntity(m *model.Model, url string) (any, string, error) {
+	if t, ok := m.Types().Lookup(url); ok {
+		if t.Source == nil {
+			return t, t.URL, nil
+		}
+		return t, fmt.Sprintf("%s (%s)", t.URL, t.Source.Package), nil
+	}
+	for _, cs := range m.CodeSystems() {
+		if cs.URL == url {
+			return cs, fmt.Sprintf("%s (%s@%s)", cs.URL, cs.Package, cs.Version), nil
+		}
+	}
+	return nil, "", fmt.Errorf("no structure definition or code system with URL '%v' in the model", url)
+}
+
+func writeExplanation(w io.Writer, i int, name string, matched bool, include, exclude []*filter.Explanation) {
+	label := fmt.Sprintf("transform(%d)", i)
+	if name != "" {
+		label += " " + name
+	}
+	verdict := "excluded"
+	if matched {
+		verdict = "included"
+	}
+	fmt.Fprintf(w, "%s: %s\n", label, verdict)
+
+	if len(include) == 0 {
+		fmt.Fprintf(w, "  include: no filters; every entity is included\n")
+	}
+	writeFilterExplanations(w, "include", include)
+	writeFilterExplanations(w, "exclude", exclude)
+}
+
+func writeFilterExplanations(w io.Writer, kind string, explanations []*filter.Explanation) {
+	for i, explanation := range explanations {
+		verdict := "not matched"
+		if explanation.Matched {
+			verdict = "matched"
+		}
+		fmt.Fprintf(w, "  %s[%d]: %s\n", kind, i, verdict)
+		if len(explanation.Clauses) == 0 {
+			fmt.Fprintf(w, "    empty filter matches nothing\n")
+		}
+		for _, clause := range explanation.Clauses {
+			mark := "x"
+			if clause.Matched {
+				mark = "✓"
+			}
+			fmt.Fprintf(w, "    %s %s: %s\n", mark, clause.Key, clause.Value)
+			if clause.Err != nil {
+				fmt.Fprintf(w, "        error: %v\n", clause.Err)
+			}
+		}
+	}
+}
+
+var _ snek.Command = (*ExplainCommand)(nil)
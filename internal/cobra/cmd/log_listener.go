@@ -112,6 +112,18 @@ func (l *Listener) AfterTransformOutput(n int, output string, err error) {
 	}
 }
 
+func (l *Listener) AfterTransform(n int, stats *driver.TransformStats) {
+	l.out.Printf("transform(%d): %d written, %d unchanged, %d skipped, %d failed", n, stats.Written, stats.Unchanged, stats.Skipped, stats.Failed)
+}
+
+func (l *Listener) OnPrune(output string, err error) {
+	if err != nil {
+		l.out.Printf("%s -- stale: %v", l.shortPath(output), err)
+	} else {
+		l.out.Printf("%s -- removed stale output", l.shortPath(output))
+	}
+}
+
 func (l *Listener) shortPath(output string) string {
 	cwd, err := os.Getwd()
 	if err != nil {
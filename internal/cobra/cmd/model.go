@@ -0,0 +1,537 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/friendly-fhir/fhenix/internal/snek"
+	"github.com/friendly-fhir/fhenix/pkg/config"
+	"github.com/friendly-fhir/fhenix/pkg/driver"
+	"github.com/friendly-fhir/fhenix/pkg/model"
+	"github.com/friendly-fhir/fhenix/pkg/model/export"
+	"github.com/friendly-fhir/fhenix/pkg/registry"
+	"gopkg.in/yaml.v3"
+)
+
+type ModelCommand struct {
+	snek.BaseCommand
+}
+
+func (mc *ModelCommand) Info() *snek.CommandInfo {
+	return &snek.CommandInfo{
+		Use:     "model <command>",
+		Summary: "Inspect the model that templates receive",
+		Description: snek.Lines(
+			fmt.Sprintf("Load the packages of a %v file into the model, and inspect its types", snek.FormatKeyword.Format("fhenix config")),
+			"exactly as they are passed to templates.",
+		),
+		Examples: snek.Examples(
+			"fhenix model list --kind resource",
+			"fhenix model show http://hl7.org/fhir/StructureDefinition/Patient",
+			"fhenix model export --format yaml --output model.yaml",
+		),
+	}
+}
+
+func (mc *ModelCommand) Commands() snek.Commands {
+	commands := snek.Commands{}
+	inspection := commands.Group("Inspection")
+	inspection.Add(&ModelListCommand{})
+	inspection.Add(&ModelShowCommand{})
+	inspection.Add(&ModelExportCommand{})
+	return commands
+}
+
+// Output formats for the --format flag of the model commands.
+const (
+	modelFormatHuman = "human"
+	modelFormatJSON  = "json"
+	modelFormatYAML  = "yaml"
+)
+
+var modelFormats = []string{
+	modelFormatHuman,
+	modelFormatJSON,
+	modelFormatYAML,
+}
+
+// modelFlags are the flags that every model command uses to load the model.
+type modelFlags struct {
+	Config    string
+	Root      string
+	Vars      varsFlag
+	FHIRCache string
+	Timeout   time.Duration
+	Verbose   bool
+}
+
+func (mf *modelFlags) flags() *snek.FlagSet {
+	load := snek.NewFlagSet("Model")
+	load.StringP(&mf.Config, "config", "c", "fhenix.yaml", "The fhenix config whose packages are loaded into the model")
+	load.String(&mf.Root, "root", "", "The root directory to consider all paths relative to")
+	load.Var("set", &mf.Vars, "Override a config variable, as 'key=value'; nested variables use dotted keys")
+	load.String(&mf.FHIRCache, "fhir-cache", "", "The configuration path to download the FHIR IGs to")
+	load.DurationP(&mf.Timeout, "timeout", "t", 0, "Timeout for the download")
+	load.BoolP(&mf.Verbose, "verbose", "v", false, "Enable verbose output")
+	return load
+}
+
+// load downloads the packages of the config, and loads them into the model.
+// Progress is logged to stderr, since the model is printed to stdout.
+func (mf *modelFlags) load(ctx context.Context) (*model.Model, error) {
+	var cfgopts []config.Option
+	if mf.Root != "" {
+		cfgopts = append(cfgopts, config.WithRootDir(mf.Root))
+	}
+	if len(mf.Vars) > 0 {
+		cfgopts = append(cfgopts, config.WithVars(mf.Vars))
+	}
+	cfg, err := config.FromFile(mf.Config, cfgopts...)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := registry.DefaultCache()
+	if mf.FHIRCache != "" {
+		cache = registry.NewCache(mf.FHIRCache)
+	}
+	if timeout := mf.Timeout; timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	d, err := driver.New(cfg,
+		driver.Cache(cache),
+		driver.Listeners(NewLogListener(snek.CommandErr(ctx), mf.Verbose)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.DownloadPackages(ctx); err != nil {
+		return nil, err
+	}
+	if err := d.LoadConformanceModule(); err != nil {
+		return nil, err
+	}
+	return d.LoadModel()
+}
+
+// writeModel writes the value in the given format, using the human function
+// for the human format.
+func writeModel(w io.Writer, format string, v any, human func(w io.Writer)) error {
+	switch format {
+	case modelFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case modelFormatYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(v); err != nil {
+			return err
+		}
+		return encoder.Close()
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	human(tw)
+	return tw.Flush()
+}
+
+type ModelListCommand struct {
+	Kinds    []string
+	Packages []string
+	Format   string
+
+	modelFlags
+	snek.BaseCommand
+}
+
+func (lc *ModelListCommand) Info() *snek.CommandInfo {
+	return &snek.CommandInfo{
+		Use:     "list",
+		Summary: "List the types of the model",
+		Description: snek.Lines(
+			fmt.Sprintf("List every type of the model that is loaded from the packages of the %v", snek.FormatKeyword.Format("fhenix config")),
+			"file, sorted by name.",
+		),
+		Examples: snek.Examples(
+			"fhenix model list",
+			"fhenix model list --kind resource --kind complex-type",
+			"fhenix model list --package hl7.fhir.us.core@6.1.0 --format json",
+		),
+	}
+}
+
+func (lc *ModelListCommand) PositionalArgs() snek.PositionalArgs {
+	return snek.ExactArgs(0)
+}
+
+func (lc *ModelListCommand) Flags() []*snek.FlagSet {
+	filter := snek.NewFlagSet("Filter")
+	filter.StringSlice(&lc.Kinds, "kind", nil, "Only list types of this kind; one of 'resource', 'complex-type', 'primitive-type', or 'logical'")
+	filter.StringSlice(&lc.Packages, "package", nil, "Only list types of this package, as 'name' or 'name@version'")
+
+	output := snek.NewFlagSet("Output")
+	output.String(&lc.Format, "format", modelFormatHuman, "The format to print in; one of 'human', 'json', or 'yaml'")
+
+	return []*snek.FlagSet{lc.modelFlags.flags(), filter, output}
+}
+
+func (lc *ModelListCommand) Run(ctx context.Context, args []string) error {
+	if !slices.Contains(modelFormats, lc.Format) {
+		return snek.UsageError(fmt.Sprintf("unknown format '%v'", lc.Format))
+	}
+	kinds := []string{
+		string(model.TypeKindResource),
+		string(model.TypeKindComplexType),
+		string(model.TypeKindPrimitive),
+		string(model.TypeKindLogical),
+	}
+	for _, kind := range lc.Kinds {
+		if !slices.Contains(kinds, kind) {
+			return snek.UsageError(fmt.Sprintf("unknown kind '%v'", kind))
+		}
+	}
+
+	m, err := lc.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	types := m.Types().TypesMatching(func(t *model.Type) bool {
+		if len(lc.Kinds) > 0 && !slices.Contains(lc.Kinds, string(t.Kind)) {
+			return false
+		}
+		if len(lc.Packages) > 0 && !slices.ContainsFunc(lc.Packages, t.InPackage) {
+			return false
+		}
+		return true
+	}).All()
+
+	summaries := make([]*typeSummary, 0, len(types))
+	for _, t := range types {
+		summaries = append(summaries, newTypeSummary(t))
+	}
+	return writeModel(snek.CommandOut(ctx), lc.Format, summaries, func(w io.Writer) {
+		fmt.Fprintln(w, "NAME\tKIND\tABSTRACT\tPACKAGE\tURL")
+		for _, s := range summaries {
+			fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\n", s.Name, s.Kind, s.Abstract, s.Package, s.URL)
+		}
+	})
+}
+
+type ModelShowCommand struct {
+	Format string
+
+	modelFlags
+	snek.BaseCommand
+}
+
+func (sc *ModelShowCommand) Info() *snek.CommandInfo {
+	return &snek.CommandInfo{
+		Use:     "show <canonical url>",
+		Summary: "Show a type of the model",
+		Description: snek.Lines(
+			"Show a single type of the model, with its fields, cardinalities, builtins,",
+			"bindings, base chain, derived types, and backbone sub-types. Structure",
+			"definitions of the base FHIR package may also be named without their URL,",
+			"such as 'Patient'.",
+		),
+		Examples: snek.Examples(
+			"fhenix model show http://hl7.org/fhir/StructureDefinition/Patient",
+			"fhenix model show Patient --format yaml",
+		),
+	}
+}
+
+func (sc *ModelShowCommand) PositionalArgs() snek.PositionalArgs {
+	return snek.ExactArgs(1)
+}
+
+func (sc *ModelShowCommand) Flags() []*snek.FlagSet {
+	output := snek.NewFlagSet("Output")
+	output.String(&sc.Format, "format", modelFormatHuman, "The format to print in; one of 'human', 'json', or 'yaml'")
+
+	return []*snek.FlagSet{sc.modelFlags.flags(), output}
+}
+
+func (sc *ModelShowCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return snek.UsageError("expected exactly one argument")
+	}
+	if !slices.Contains(modelFormats, sc.Format) {
+		return snek.UsageError(fmt.Sprintf("unknown format '%v'", sc.Format))
+	}
+	m, err := sc.load(ctx)
+	if err != nil {
+		return err
+	}
+	t, ok := m.Types().Lookup(args[0])
+	if !ok {
+		return fmt.Errorf("no type with URL '%v' in the model", args[0])
+	}
+
+	detail := newTypeDetail(t)
+	return writeModel(snek.CommandOut(ctx), sc.Format, detail, func(w io.Writer) {
+		writeTypeDetail(w, detail)
+	})
+}
+
+// typeSummary is a type of the model, as it is listed.
+type typeSummary struct {
+	Name     string `json:"name" yaml:"name"`
+	URL      string `json:"url,omitempty" yaml:"url,omitempty"`
+	Kind     string `json:"kind" yaml:"kind"`
+	Abstract bool   `json:"abstract" yaml:"abstract"`
+	Package  string `json:"package,omitempty" yaml:"package,omitempty"`
+	Base     string `json:"base,omitempty" yaml:"base,omitempty"`
+}
+
+func newTypeSummary(t *model.Type) *typeSummary {
+	result := &typeSummary{
+		Name:     t.Name,
+		URL:      t.URL,
+		Kind:     string(t.Kind),
+		Abstract: t.IsAbstract,
+		Base:     typeRef(t.Base),
+	}
+	if t.Source != nil {
+		result.Package = fmt.Sprintf("%s@%s", t.Package(), t.Version())
+	}
+	return result
+}
+
+// typeDetail is a type of the model, as it is shown.
+type typeDetail struct {
+	typeSummary `yaml:",inline"`
+
+	Description string         `json:"description,omitempty" yaml:"description,omitempty"`
+	Source      string         `json:"source,omitempty" yaml:"source,omitempty"`
+	Derivation  string         `json:"derivation,omitempty" yaml:"derivation,omitempty"`
+	Status      string         `json:"status,omitempty" yaml:"status,omitempty"`
+	BaseChain   []string       `json:"base-chain,omitempty" yaml:"base-chain,omitempty"`
+	Derived     []string       `json:"derived,omitempty" yaml:"derived,omitempty"`
+	Fields      []*fieldDetail `json:"fields,omitempty" yaml:"fields,omitempty"`
+	SubTypes    []*typeDetail  `json:"sub-types,omitempty" yaml:"sub-types,omitempty"`
+}
+
+// fieldDetail is a field of a type of the model, as it is shown.
+type fieldDetail struct {
+	Name            string         `json:"name" yaml:"name"`
+	Path            string         `json:"path" yaml:"path"`
+	Type            string         `json:"type,omitempty" yaml:"type,omitempty"`
+	Alternatives    []string       `json:"alternatives,omitempty" yaml:"alternatives,omitempty"`
+	Builtin         string         `json:"builtin,omitempty" yaml:"builtin,omitempty"`
+	Cardinality     string         `json:"cardinality" yaml:"cardinality"`
+	BaseCardinality string         `json:"base-cardinality" yaml:"base-cardinality"`
+	Binding         *bindingDetail `json:"binding,omitempty" yaml:"binding,omitempty"`
+	Short           string         `json:"short,omitempty" yaml:"short,omitempty"`
+}
+
+// bindingDetail is the terminology binding of a field, as it is shown.
+type bindingDetail struct {
+	Strength string   `json:"strength" yaml:"strength"`
+	ValueSet string   `json:"value-set" yaml:"value-set"`
+	Codes    []string `json:"codes,omitempty" yaml:"codes,omitempty"`
+}
+
+func newTypeDetail(t *model.Type) *typeDetail {
+	result := &typeDetail{
+		typeSummary: *newTypeSummary(t),
+		Description: t.Description,
+		Derivation:  t.Derivation(),
+		Status:      t.Status(),
+	}
+	if t.Source != nil && t.Source.File != "" {
+		result.Source = filepath.Base(t.Source.File)
+	}
+	for base := t.Base; base != nil; base = base.Base {
+		result.BaseChain = append(result.BaseChain, typeRef(base))
+	}
+	for _, derived := range t.Derived {
+		result.Derived = append(result.Derived, typeRef(derived))
+	}
+	slices.Sort(result.Derived)
+	for _, f := range t.Fields {
+		result.Fields = append(result.Fields, newFieldDetail(f))
+	}
+	for _, sub := range t.SubTypes {
+		result.SubTypes = append(result.SubTypes, newTypeDetail(sub))
+	}
+	return result
+}
+
+func newFieldDetail(f *model.Field) *fieldDetail {
+	result := &fieldDetail{
+		Name:            f.Name,
+		Path:            f.Path,
+		Type:            typeRef(f.Type),
+		Cardinality:     f.Cardinality.String(),
+		BaseCardinality: f.BaseCardinality.String(),
+		Short:           f.Short,
+	}
+	for _, alt := range f.Alternatives {
+		result.Alternatives = append(result.Alternatives, typeRef(alt))
+	}
+	if f.Builtin != nil {
+		result.Builtin = f.Builtin.Name
+	}
+	if b := f.Binding; b != nil {
+		result.Binding = &bindingDetail{Strength: string(b.Strength), ValueSet: b.ValueSet}
+		for _, code := range b.Codes {
+			result.Binding.Codes = append(result.Binding.Codes, code.Value)
+		}
+	}
+	return result
+}
+
+// typeRef returns the URL that refers to the type, or the name of backbone
+// types that have none.
+func typeRef(t *model.Type) string {
+	if t == nil {
+		return ""
+	}
+	if t.URL == "" {
+		return t.Name
+	}
+	return t.URL
+}
+
+// typeName returns the name of the type that the URL refers to, which is the
+// last segment of the URL.
+func typeName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+func writeTypeDetail(w io.Writer, t *typeDetail) {
+	fmt.Fprintf(w, "%s (%s)\n", t.Name, t.Kind)
+	property := func(key, value string) {
+		if value != "" {
+			fmt.Fprintf(w, "  %s:\t%s\n", key, value)
+		}
+	}
+	property("url", t.URL)
+	property("package", t.Package)
+	property("source", t.Source)
+	property("abstract", fmt.Sprint(t.Abstract))
+	property("derivation", t.Derivation)
+	property("status", t.Status)
+	names := make([]string, 0, len(t.BaseChain))
+	for _, base := range t.BaseChain {
+		names = append(names, typeName(base))
+	}
+	property("base", strings.Join(names, " -> "))
+	names = names[:0]
+	for _, derived := range t.Derived {
+		names = append(names, typeName(derived))
+	}
+	property("derived", strings.Join(names, ", "))
+
+	if len(t.Fields) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "  FIELD\tTYPE\tCARDINALITY\tBUILTIN\tBINDING")
+	}
+	for _, f := range t.Fields {
+		tp := typeName(f.Type)
+		if len(f.Alternatives) > 0 {
+			alternatives := make([]string, 0, len(f.Alternatives))
+			for _, alt := range f.Alternatives {
+				alternatives = append(alternatives, typeName(alt))
+			}
+			tp = strings.Join(alternatives, " | ")
+		}
+		cardinality := f.Cardinality
+		if f.BaseCardinality != f.Cardinality {
+			cardinality += fmt.Sprintf(" (base %s)", f.BaseCardinality)
+		}
+		binding := ""
+		if f.Binding != nil {
+			binding = fmt.Sprintf("%s %s", f.Binding.Strength, f.Binding.ValueSet)
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", f.Name, tp, cardinality, f.Builtin, binding)
+	}
+	for _, sub := range t.SubTypes {
+		fmt.Fprintln(w)
+		writeTypeDetail(w, sub)
+	}
+}
+
+type ModelExportCommand struct {
+	Format string
+	Output string
+
+	modelFlags
+	snek.BaseCommand
+}
+
+func (ec *ModelExportCommand) Info() *snek.CommandInfo {
+	return &snek.CommandInfo{
+		Use:     "export",
+		Summary: "Export the model as JSON or YAML",
+		Description: snek.Lines(
+			"Export every type and code system of the model as a JSON or YAML document, for",
+			"generators that are not written as Go templates. References between types are",
+			"canonical URLs, so the document has no cycles.",
+			"",
+			"The document is versioned, and is described by the JSON Schema at:",
+			export.SchemaURL,
+		),
+		Examples: snek.Examples(
+			"fhenix model export",
+			"fhenix model export --format yaml --output model.yaml",
+		),
+	}
+}
+
+func (ec *ModelExportCommand) PositionalArgs() snek.PositionalArgs {
+	return snek.ExactArgs(0)
+}
+
+func (ec *ModelExportCommand) Flags() []*snek.FlagSet {
+	output := snek.NewFlagSet("Output")
+	output.String(&ec.Format, "format", string(export.FormatJSON), "The format to export in; one of 'json' or 'yaml'")
+	output.StringP(&ec.Output, "output", "o", "", "The file to write the document to; defaults to stdout")
+
+	return []*snek.FlagSet{ec.modelFlags.flags(), output}
+}
+
+func (ec *ModelExportCommand) Run(ctx context.Context, args []string) error {
+	format := export.Format(ec.Format)
+	if format != export.FormatJSON && format != export.FormatYAML {
+		return snek.UsageError(fmt.Sprintf("unknown format '%v'", ec.Format))
+	}
+	m, err := ec.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	doc := export.New(m)
+	if ec.Output == "" {
+		return doc.Write(snek.CommandOut(ctx), format)
+	}
+	file, err := os.Create(ec.Output)
+	if err != nil {
+		return err
+	}
+	if err := doc.Write(file, format); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+var (
+	_ snek.Command = (*ModelCommand)(nil)
+	_ snek.Command = (*ModelListCommand)(nil)
+	_ snek.Command = (*ModelShowCommand)(nil)
+	_ snek.Command = (*ModelExportCommand)(nil)
+)
@@ -23,6 +23,9 @@ func (r *RootCommand) Info() *snek.CommandInfo {
 			"fhenix init",
 			"fhenix download hl7.fhir.r4.core 4.0.1 --registry https://packages.simplifier.net",
 			"fhenix run fhenix.yaml --parallel 4",
+			"fhenix test fhenix.yaml --update",
+			"fhenix explain fhenix.yaml http://hl7.org/fhir/StructureDefinition/Patient",
+			"fhenix model show Patient --config fhenix.yaml",
 		),
 	}
 }
@@ -35,6 +38,11 @@ func (r *RootCommand) Commands() snek.Commands {
 	generation := commands.Group("Generation")
 	generation.Add(&InitCommand{})
 	generation.Add(&RunCommand{})
+	generation.Add(&TestCommand{})
+
+	inspection := commands.Group("Inspection")
+	inspection.Add(&ExplainCommand{})
+	inspection.Add(&ModelCommand{})
 	return commands
 }
 
@@ -2,18 +2,29 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/friendly-fhir/fhenix/internal/diff"
 	"github.com/friendly-fhir/fhenix/internal/set"
 	"github.com/friendly-fhir/fhenix/internal/snek"
 	"github.com/friendly-fhir/fhenix/internal/snek/terminal"
+	"github.com/friendly-fhir/fhenix/internal/watch"
 	"github.com/friendly-fhir/fhenix/pkg/config"
 	"github.com/friendly-fhir/fhenix/pkg/driver"
+	"github.com/friendly-fhir/fhenix/pkg/driver/sink"
+	"github.com/friendly-fhir/fhenix/pkg/model"
 	"github.com/friendly-fhir/fhenix/pkg/registry"
+	"github.com/friendly-fhir/fhenix/pkg/transform/regions"
 )
 
 type RunCommand struct {
@@ -26,6 +37,15 @@ type RunCommand struct {
 	FHIRCache string
 	Verbose   bool
 	Timeout   time.Duration
+	Watch     bool
+	DryRun    bool
+	Check     bool
+	Strict    bool
+
+	NoIncremental bool
+	NoPrune       bool
+	Vars          varsFlag
+	OutputFormat  string
 
 	NoProgress bool
 	Log        string
@@ -46,6 +66,13 @@ func (rc *RunCommand) Info() *snek.CommandInfo {
 			"fhenix run fhenix.yaml --rm --output ./destination",
 			"fhenix run fhenix.yaml --fhir-cache ~/.fhir --timeout 5m",
 			"fhenix run fhenix.yaml --parallel 4",
+			"fhenix run fhenix.yaml --watch",
+			"fhenix run fhenix.yaml --dry-run",
+			"fhenix run fhenix.yaml --check",
+			"fhenix run fhenix.yaml --strict",
+			"fhenix run fhenix.yaml --set go.package=fhir --set namespace=r4",
+			"fhenix run fhenix.yaml --output-format tar.gz --output generated.tar.gz",
+			"fhenix run fhenix.yaml --output-format stdout",
 		),
 	}
 }
@@ -62,12 +89,20 @@ func (rc *RunCommand) Flags() []*snek.FlagSet {
 
 	output := snek.NewFlagSet("Output")
 	output.Bool(&rc.RM, "rm", false, "Remove all contents from the output directory prior to writing")
-	output.StringP(&rc.Output, "output", "o", "", "The output directory to write the generated code to")
+	output.Bool(&rc.NoPrune, "no-prune", false, "Keep previously generated files that are no longer produced")
+	output.Bool(&rc.NoIncremental, "no-incremental", false, "Render every output, even if its inputs are unchanged since the last run")
+	output.StringP(&rc.Output, "output", "o", "", "The output directory to write the generated code to, or the archive file for archive output formats ('-' for stdout)")
+	output.String(&rc.OutputFormat, "output-format", outputFormatDir, "The format to write the generated code in; one of 'dir', 'tar.gz', 'zip', or 'stdout'")
 	output.String(&rc.Root, "root", "", "The root directory to consider all paths relative to")
+	output.Var("set", &rc.Vars, "Override a config variable, as 'key=value'; nested variables use dotted keys")
 	output.String(&rc.FHIRCache, "fhir-cache", "", "The configuration path to download the FHIR IGs to")
 	output.BoolP(&rc.Verbose, "verbose", "v", false, "Enable verbose output")
 	output.Bool(&rc.NoProgress, "no-progress", false, "Disable progress output")
 	output.String(&rc.Log, "log", "", "The log file to write the output to")
+	output.BoolP(&rc.Watch, "watch", "w", false, "Re-run the transformations whenever the config, template, or func files change")
+	output.Bool(&rc.DryRun, "dry-run", false, "Print the files that would be generated and their inputs, without writing anything")
+	output.Bool(&rc.Check, "check", false, "Print a diff of every generated file that is out of date, and fail if any are")
+	output.Bool(&rc.Strict, "strict", false, "Fail outputs whose templates report errors or index missing map keys, rather than warning")
 
 	return []*snek.FlagSet{
 		output,
@@ -80,26 +115,49 @@ func (rc *RunCommand) Run(ctx context.Context, args []string) error {
 		return snek.UsageError("expected exactly one argument")
 	}
 
+	if !slices.Contains(outputFormats, rc.OutputFormat) {
+		return snek.UsageError(fmt.Sprintf("unknown output format '%v'", rc.OutputFormat))
+	}
+	// Archive formats write a single file; so the output flag names the archive
+	// rather than the output directory.
+	archive := rc.OutputFormat != outputFormatDir
+
 	var cfgopts []config.Option
-	if rc.Output != "" {
+	if rc.Output != "" && !archive {
 		cfgopts = append(cfgopts, config.WithOutputDir(rc.Output))
 	}
 	if rc.Root != "" {
 		cfgopts = append(cfgopts, config.WithRootDir(rc.Root))
 	}
+	if len(rc.Vars) > 0 {
+		cfgopts = append(cfgopts, config.WithVars(rc.Vars))
+	}
 	cfg, err := config.FromFile(args[0], cfgopts...)
 	if err != nil {
 		return err
 	}
+	if count(rc.Watch, rc.DryRun, rc.Check) > 1 {
+		return snek.UsageError("only one of --watch, --dry-run, or --check may be specified")
+	}
+	if archive && count(rc.Watch, rc.DryRun, rc.Check) > 0 {
+		return snek.UsageError("--watch, --dry-run, and --check require the 'dir' output format")
+	}
+	// Dry-run and check modes must not modify the output directory, and print
+	// their results to stdout; so progress is logged to stderr instead. The
+	// same applies to output formats that write to stdout.
+	stdout := rc.OutputFormat == outputFormatStdout || (archive && rc.Output == "-")
+	readonly := rc.DryRun || rc.Check || stdout
 
-	if rc.RM {
+	if rc.RM && !readonly && !archive {
 		if err := os.RemoveAll(cfg.OutputDir); err != nil {
 			return err
 		}
 	}
 
 	var listeners []driver.Listener
-	if term, ok := terminal.New(snek.CommandOut(ctx)); !rc.NoProgress && ok {
+	if readonly {
+		listeners = append(listeners, NewLogListener(snek.CommandErr(ctx), rc.Verbose))
+	} else if term, ok := terminal.New(snek.CommandOut(ctx)); !rc.NoProgress && ok {
 		term.HideCursor()
 
 		listeners = append(listeners, NewProgressListener(term, rc.Verbose))
@@ -130,37 +188,351 @@ func (rc *RunCommand) Run(ctx context.Context, args []string) error {
 		defer cancel()
 	}
 
-	var m sync.Mutex
-	var warnings []error
-	reporter := func(cause error) {
-		m.Lock()
-		defer m.Unlock()
-		warnings = append(warnings, cause)
-	}
+	warnings := &templateWarnings{}
 
 	opts := []driver.Option{
 		driver.ForceDownload(rc.Force),
+		driver.Incremental(!rc.NoIncremental),
+		driver.Prune(!rc.NoPrune),
 		driver.Parallel(rc.Parallel),
 		driver.Cache(cache),
 		driver.Listeners(listeners...),
-		driver.TemplateReportFunc(reporter),
+		driver.TemplateReporter(warnings),
+		driver.Strict(rc.Strict),
+	}
+	var finish func(error) error
+	if archive {
+		var output driver.OutputSink
+		output, finish, err = rc.outputSink(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, driver.Sink(output))
 	}
 	driver, err := driver.New(cfg, opts...)
 	if err != nil {
 		return err
 	}
 
-	defer func() {
-		seen := set.New[string]()
-		for _, warning := range warnings {
-			seen.Add(warning.Error())
+	if rc.Watch {
+		return rc.watch(ctx, args[0], cfgopts, cfg, driver, listeners, warnings)
+	}
+
+	defer warnings.Flush(ctx)
+	switch {
+	case rc.DryRun:
+		return rc.dryRun(ctx, driver)
+	case rc.Check:
+		err = rc.check(ctx, driver)
+	default:
+		err = driver.Run(ctx)
+		if finish != nil {
+			err = finish(err)
+		}
+	}
+	writeTemplateExcerpt(ctx, err)
+	return err
+}
+
+// writeTemplateExcerpt writes an excerpt of the template source that caused
+// the error to stderr, if the error is a transform.TemplateError.
+func writeTemplateExcerpt(ctx context.Context, err error) {
+	var tmplErr interface{ WriteExcerpt(io.Writer) error }
+	if errors.As(err, &tmplErr) {
+		_ = tmplErr.WriteExcerpt(snek.CommandErr(ctx))
+	}
+}
+
+// Output formats for the --output-format flag.
+const (
+	outputFormatDir     = "dir"
+	outputFormatTarball = "tar.gz"
+	outputFormatZip     = "zip"
+	outputFormatStdout  = "stdout"
+)
+
+var outputFormats = []string{
+	outputFormatDir,
+	outputFormatTarball,
+	outputFormatZip,
+	outputFormatStdout,
+}
+
+// outputSink returns the sink for an output format other than 'dir', along
+// with a function that finishes writing it once the run has completed with
+// the given error. Archives are written to the file named by --output, or
+// next to the output directory by default, and are removed if the run fails.
+func (rc *RunCommand) outputSink(ctx context.Context, cfg *config.Config) (driver.OutputSink, func(error) error, error) {
+	var file *os.File
+	w := snek.CommandOut(ctx)
+	if rc.OutputFormat != outputFormatStdout && rc.Output != "-" {
+		path := rc.Output
+		if path == "" {
+			path = filepath.Clean(cfg.OutputDir) + "." + rc.OutputFormat
+		}
+		var err error
+		if file, err = os.Create(path); err != nil {
+			return nil, nil, err
+		}
+		w = file
+	}
+
+	var output interface {
+		driver.OutputSink
+		io.Closer
+	}
+	switch rc.OutputFormat {
+	case outputFormatTarball:
+		output = sink.NewTarball(w)
+	case outputFormatZip:
+		output = sink.NewZip(w)
+	case outputFormatStdout:
+		output = sink.NewStream(w)
+	}
+
+	finish := func(err error) error {
+		if err == nil {
+			err = output.Close()
+		}
+		if file == nil {
+			return err
+		}
+		err = errors.Join(err, file.Close())
+		if err != nil {
+			_ = os.Remove(file.Name())
 		}
-		for warning := range seen {
-			snek.Warningf(ctx, "template error: %v", warning)
+		return err
+	}
+	return output, finish, nil
+}
+
+// dryRun prints every file that each transform would generate, along with the
+// URLs of the entities that would be rendered into it.
+func (rc *RunCommand) dryRun(ctx context.Context, d *driver.Driver) error {
+	model, transforms, err := d.Load(ctx)
+	if err != nil {
+		return err
+	}
+	plan, err := d.Plan(model, transforms)
+	if err != nil {
+		return err
+	}
+
+	out := snek.CommandOut(ctx)
+	for i, jobs := range plan {
+		fmt.Fprintf(out, "transform(%d): %d files\n", i, len(jobs))
+		for _, job := range jobs {
+			fmt.Fprintf(out, "  %s\n", displayPath(job.OutputPath()))
+			for _, sd := range job.StructureDefinitions() {
+				fmt.Fprintf(out, "    %s\n", sd.URL)
+			}
+			for _, cs := range job.CodeSystems() {
+				fmt.Fprintf(out, "    %s\n", cs.URL)
+			}
 		}
-	}()
+	}
+	return nil
+}
+
+// check renders every transform into memory, and prints a unified diff for
+// every output that differs from the file on disk, or that is stale and would
+// be pruned. An error is returned if any output is out of date.
+func (rc *RunCommand) check(ctx context.Context, d *driver.Driver) error {
+	model, transforms, err := d.Load(ctx)
+	if err != nil {
+		return err
+	}
+	drifts, err := d.Check(ctx, model, transforms)
+	if err != nil {
+		return err
+	}
+
+	out := snek.CommandOut(ctx)
+	for _, drift := range drifts {
+		path := displayPath(drift.Path)
+		generated := path + " (generated)"
+		if drift.Want == nil {
+			generated = path + " (pruned)"
+		}
+		fmt.Fprint(out, diff.Unified(path+" (on disk)", generated, string(drift.Got), string(drift.Want)))
+	}
+	if len(drifts) == 1 {
+		return fmt.Errorf("1 generated file is out of date")
+	}
+	if len(drifts) > 1 {
+		return fmt.Errorf("%d generated files are out of date", len(drifts))
+	}
+	return nil
+}
+
+// displayPath returns the path relative to the working directory, if possible.
+func displayPath(path string) string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(cwd, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+func count(flags ...bool) int {
+	n := 0
+	for _, flag := range flags {
+		if flag {
+			n++
+		}
+	}
+	return n
+}
+
+// watch runs the generation, and then re-runs only the transformation stages
+// whenever the config file, or any template or func file that it references,
+// is modified. The loaded model is kept in memory between runs.
+func (rc *RunCommand) watch(ctx context.Context, file string, cfgopts []config.Option, cfg *config.Config, d *driver.Driver, listeners []driver.Listener, warnings *templateWarnings) error {
+	// The download is still bounded by the timeout and the parent context.
+	if err := d.DownloadPackages(ctx); err != nil {
+		return err
+	}
+	if err := d.LoadConformanceModule(); err != nil {
+		return err
+	}
+	model, err := d.LoadModel()
+	if err != nil {
+		return err
+	}
+
+	// Watching is unbounded, so it must not be subject to the application or
+	// command timeouts; only an interrupt will stop it.
+	ctx, stop := signal.NotifyContext(context.WithoutCancel(ctx), os.Interrupt)
+	defer stop()
+
+	watcher := watch.New(watch.DefaultInterval)
+	for restart := false; ; restart = true {
+		watcher.Set(watchedFiles(file, cfg)...)
+		if restart {
+			for _, listener := range listeners {
+				if r, ok := listener.(restarter); ok {
+					r.Restart()
+				}
+			}
+		}
+		rc.transform(ctx, d, model)
+		warnings.Flush(ctx)
+
+		cfg, err = rc.waitForConfig(ctx, watcher, file, cfgopts)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		d.Reconfigure(cfg)
+	}
+}
+
+// transform runs the transformation stages of the driver. Errors are not
+// returned, since they are already reported through the driver's listeners.
+func (rc *RunCommand) transform(ctx context.Context, d *driver.Driver, model *model.Model) {
+	transforms, err := d.LoadTransforms()
+	if err != nil {
+		return
+	}
+	_ = d.Transform(ctx, model, transforms)
+}
+
+// waitForConfig waits for a change to any watched file, and then reloads the
+// config file. Config errors are reported, and waiting resumes until the
+// config can be loaded successfully.
+func (rc *RunCommand) waitForConfig(ctx context.Context, watcher *watch.Watcher, file string, cfgopts []config.Option) (*config.Config, error) {
+	for {
+		if _, err := watcher.Wait(ctx); err != nil {
+			return nil, err
+		}
+		cfg, err := config.FromFile(file, cfgopts...)
+		if err == nil {
+			return cfg, nil
+		}
+		snek.Errorf(ctx, "%v", err)
+	}
+}
+
+func watchedFiles(file string, cfg *config.Config) []string {
+	files := append([]string{file}, cfg.Includes...)
+	for _, transform := range cfg.Transforms {
+		files = append(files, transform.Files()...)
+	}
+	return files
+}
+
+// restarter is implemented by listeners that track the progress of a single
+// run, and that must be reset before the transformation stages are re-run.
+type restarter interface {
+	Restart()
+}
+
+// templateWarnings is a template error reporter that collects errors, so that
+// they may be reported once generation has completed.
+type templateWarnings struct {
+	m        sync.Mutex
+	warnings []error
+}
+
+func (tw *templateWarnings) Report(cause error) {
+	tw.m.Lock()
+	defer tw.m.Unlock()
+	tw.warnings = append(tw.warnings, cause)
+}
+
+// Flush prints all unique collected warnings, and clears them.
+func (tw *templateWarnings) Flush(ctx context.Context) {
+	tw.m.Lock()
+	defer tw.m.Unlock()
+
+	seen := set.New[string]()
+	for _, warning := range tw.warnings {
+		var orphaned *regions.OrphanedError
+		if errors.As(warning, &orphaned) {
+			seen.Add(warning.Error())
+		} else {
+			seen.Add("template error: " + warning.Error())
+		}
+	}
+	for warning := range seen {
+		snek.Warningf(ctx, "%v", warning)
+	}
+	tw.warnings = nil
+}
+
+var _ driver.Reporter = (*templateWarnings)(nil)
+
+// varsFlag is a repeatable flag of 'key=value' variable overrides.
+type varsFlag map[string]string
+
+func (vf *varsFlag) String() string {
+	entries := make([]string, 0, len(*vf))
+	for key, value := range *vf {
+		entries = append(entries, key+"="+value)
+	}
+	slices.Sort(entries)
+	return strings.Join(entries, ",")
+}
+
+func (vf *varsFlag) Set(entry string) error {
+	key, value, ok := strings.Cut(entry, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("expected 'key=value', got '%v'", entry)
+	}
+	if *vf == nil {
+		*vf = varsFlag{}
+	}
+	(*vf)[strings.TrimSpace(key)] = value
+	return nil
+}
 
-	return driver.Run(ctx)
+func (vf *varsFlag) Type() string {
+	return "key=value"
 }
 
 var _ snek.Command = (*RunCommand)(nil)
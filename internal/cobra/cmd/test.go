@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+
+	"github.com/friendly-fhir/fhenix/internal/diff"
+	"github.com/friendly-fhir/fhenix/internal/snek"
+	"github.com/friendly-fhir/fhenix/pkg/config"
+	"github.com/friendly-fhir/fhenix/pkg/driver"
+	"github.com/friendly-fhir/fhenix/pkg/driver/golden"
+	"github.com/friendly-fhir/fhenix/pkg/registry"
+)
+
+type TestCommand struct {
+	Testdata  string
+	Update    bool
+	Strict    bool
+	Root      string
+	Vars      varsFlag
+	Parallel  int
+	FHIRCache string
+
+	snek.BaseCommand
+}
+
+func (tc *TestCommand) Info() *snek.CommandInfo {
+	return &snek.CommandInfo{
+		Use:     "test <fhenix config> [test case...]",
+		Summary: "Test templates against golden files",
+		Description: snek.Lines(
+			fmt.Sprintf("Run the transformations of the specified %v file against small fixture", snek.FormatKeyword.Format("fhenix config")),
+			"packages, and compare the rendered files to golden files.",
+			"",
+			fmt.Sprintf("Each test case is a directory under %v that contains a '%v' file,", snek.FormatKeyword.Format("testdata"), golden.CaseFile),
+			"which declares the fixture as a local package path and/or inline definitions:",
+			"",
+			"  package: ../fixtures/patient   # a directory or a .tgz package archive",
+			"  definitions:",
+			"    - resourceType: StructureDefinition",
+			"      ...",
+			"",
+			fmt.Sprintf("The golden files are in the '%v' directory of the test case, relative to the", golden.GoldenDir),
+			"output directory of the config. The fixture replaces the input package of the",
+			"same name, and the other inputs and the dependencies of the fixture are loaded",
+			"from the FHIR cache. No packages are downloaded, so they must already be cached.",
+		),
+		Examples: snek.Examples(
+			"fhenix test fhenix.yaml",
+			"fhenix test fhenix.yaml patient",
+			"fhenix test fhenix.yaml --update",
+			"fhenix test fhenix.yaml --testdata ./golden-tests",
+		),
+	}
+}
+
+func (tc *TestCommand) PositionalArgs() snek.PositionalArgs {
+	return snek.MinimumNArgs(1)
+}
+
+func (tc *TestCommand) Flags() []*snek.FlagSet {
+	test := snek.NewFlagSet("Test")
+	test.String(&tc.Testdata, "testdata", "", "The directory of test cases; defaults to 'testdata' next to the config")
+	test.BoolP(&tc.Update, "update", "u", false, "Rewrite the golden files with the rendered files")
+	test.Bool(&tc.Strict, "strict", false, "Fail outputs whose templates report errors or index missing map keys, rather than warning")
+	test.String(&tc.Root, "root", "", "The root directory to consider all paths relative to")
+	test.Var("set", &tc.Vars, "Override a config variable, as 'key=value'; nested variables use dotted keys")
+	test.Int(&tc.Parallel, "parallel", runtime.NumCPU(), "The number of parallel workers to use")
+	test.String(&tc.FHIRCache, "fhir-cache", "", "The directory of the FHIR cache to load dependencies from")
+
+	return []*snek.FlagSet{
+		test,
+	}
+}
+
+func (tc *TestCommand) Run(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		return snek.UsageError("expected at least one argument")
+	}
+
+	var cfgopts []config.Option
+	if tc.Root != "" {
+		cfgopts = append(cfgopts, config.WithRootDir(tc.Root))
+	}
+	if len(tc.Vars) > 0 {
+		cfgopts = append(cfgopts, config.WithVars(tc.Vars))
+	}
+	cfg, err := config.FromFile(args[0], cfgopts...)
+	if err != nil {
+		return err
+	}
+
+	testdata := tc.Testdata
+	if testdata == "" {
+		testdata = filepath.Join(filepath.Dir(args[0]), "testdata")
+	}
+	cases, err := golden.LoadCases(testdata, args[1:]...)
+	if err != nil {
+		return err
+	}
+
+	cache := registry.DefaultCache()
+	if tc.FHIRCache != "" {
+		cache = registry.NewCache(tc.FHIRCache)
+	}
+
+	warnings := &templateWarnings{}
+	defer warnings.Flush(ctx)
+
+	out := snek.CommandOut(ctx)
+	failed := 0
+	for _, c := range cases {
+		outputs, err := c.Render(ctx, cfg, cache,
+			driver.Parallel(tc.Parallel),
+			driver.TemplateReporter(warnings),
+			driver.Strict(tc.Strict),
+		)
+		if err != nil {
+			failed++
+			fmt.Fprintf(out, "FAIL %s\n", c.Name)
+			writeTemplateExcerpt(ctx, err)
+			snek.Errorf(ctx, "%s: %v", c.Name, err)
+			continue
+		}
+		if tc.Update {
+			if err := c.Update(outputs); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "updated %s\n", c.Name)
+			continue
+		}
+
+		mismatches, err := c.Compare(outputs)
+		if err != nil {
+			return err
+		}
+		if len(mismatches) == 0 {
+			fmt.Fprintf(out, "ok   %s\n", c.Name)
+			continue
+		}
+		failed++
+		fmt.Fprintf(out, "FAIL %s\n", c.Name)
+		for _, m := range mismatches {
+			path := filepath.ToSlash(filepath.Join(c.Name, golden.GoldenDir, m.Path))
+			fmt.Fprint(out, diff.Unified(path+" (golden)", path+" (rendered)", string(m.Want), string(m.Got)))
+		}
+	}
+
+	if failed == 1 {
+		return fmt.Errorf("1 of %d test cases failed", len(cases))
+	}
+	if failed > 1 {
+		return fmt.Errorf("%d of %d test cases failed", failed, len(cases))
+	}
+	return nil
+}
+
+var _ snek.Command = (*TestCommand)(nil)
@@ -24,6 +24,11 @@ type TTYListener struct {
 	loaderPkgs     map[string]*loadPackage
 	loadTransforms map[int]*loadTransform
 	transforms     map[int]*transform
+	prune          *prune
+
+	// checkpoint is the state of the listener before the transformation stages
+	// first began, which is restored when the stages are restarted.
+	checkpoint *checkpoint
 
 	m sync.Mutex
 
@@ -46,6 +51,9 @@ func (l *TTYListener) BeforeStage(s driver.Stage) {
 	l.m.Lock()
 	defer l.m.Unlock()
 
+	if s == driver.StageLoadTransform && l.checkpoint == nil {
+		l.checkpoint = &checkpoint{offset: l.offset, stage: l.stage}
+	}
 	offset := l.offset
 	l.offset++
 	line := l.terminal.Line(offset)
@@ -67,6 +75,24 @@ func (l *TTYListener) BeforeStage(s driver.Stage) {
 	}
 }
 
+// Restart resets the progress of the transformation stages, so that re-running
+// them redraws the same lines rather than appending new ones.
+func (l *TTYListener) Restart() {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.checkpoint == nil {
+		return
+	}
+	for offset := l.checkpoint.offset; offset < l.offset; offset++ {
+		l.terminal.Line(offset).Clear()
+	}
+	l.offset, l.stage = l.checkpoint.offset, l.checkpoint.stage
+	l.loadTransforms = nil
+	l.transforms = nil
+	l.prune = nil
+}
+
 func (l *TTYListener) BeforeFetch(registry, pkg, version string) {
 	key := keyOf(registry, pkg, version)
 	l.m.Lock()
@@ -231,8 +257,49 @@ func (l *TTYListener) AfterTransformOutput(i int, output string, err error) {
 	}
 }
 
+func (l *TTYListener) AfterTransform(i int, stats *driver.TransformStats) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	content := fmt.Sprintf("transform %d", i)
+	transform := l.transform(i)
+	state := ansi.FGGreen.Format("✓")
+	if stats.Failed > 0 {
+		state = ansi.FGRed.Format("x")
+	}
+	suffix := fmt.Sprintf("%d written, %d unchanged, %d skipped", stats.Written, stats.Unchanged, stats.Skipped)
+	transform.Line.Print(l.valueProgress(state, content, suffix))
+}
+
+func (l *TTYListener) OnPrune(output string, err error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.prune == nil {
+		offset := l.offset
+		l.offset++
+		l.prune = &prune{Line: l.terminal.Line(offset)}
+	}
+	if err != nil {
+		l.prune.Kept++
+	} else {
+		l.prune.Removed++
+	}
+	state := ansi.FGGreen.Format("✓")
+	if l.prune.Kept > 0 {
+		state = ansi.FGYellow.Format("!")
+	}
+	suffix := fmt.Sprintf("%d removed, %d kept", l.prune.Removed, l.prune.Kept)
+	l.prune.Line.Print(l.valueProgress(state, "stale outputs", suffix))
+}
+
 var _ driver.Listener = (*TTYListener)(nil)
 
+type checkpoint struct {
+	offset int
+	stage  int
+}
+
 type download struct {
 	TotalBytes int64
 	Current    int64
@@ -255,6 +322,12 @@ type transform struct {
 	Spinner *spinner.Spinner
 }
 
+type prune struct {
+	Line    *terminal.Line
+	Removed int
+	Kept    int
+}
+
 func (l *TTYListener) valueProgress(state, name, suffix string) string {
 	var (
 		sb strings.Builder
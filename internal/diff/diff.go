@@ -0,0 +1,201 @@
+/*
+Package diff provides a minimal line-based unified diff, for reporting the
+difference between generated content and the content on disk.
+*/
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each change.
+const contextLines = 3
+
+// maxCells bounds the size of the LCS table, so that diffing very large files
+// with large changes degrades to a single replacement hunk rather than
+// exhausting memory.
+const maxCells = 16 * 1024 * 1024
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type op struct {
+	kind opKind
+	line string
+}
+
+// Unified returns a unified diff that transforms 'from' into 'to', labelling
+// the two sides with the given names. An empty string is returned if the
+// contents are equal.
+func Unified(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+	ops := compute(splitLines(from), splitLines(to))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	for _, h := range hunks(ops) {
+		h.write(&sb, ops)
+	}
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// compute returns the edit script that transforms a into b, using the longest
+// common subsequence of lines after trimming the common prefix and suffix.
+func compute(a, b []string) []op {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []op
+	for _, line := range a[:prefix] {
+		ops = append(ops, op{opEqual, line})
+	}
+	ops = append(ops, lcs(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, op{opEqual, line})
+	}
+	return ops
+}
+
+func lcs(a, b []string) []op {
+	var ops []op
+	if len(a)*len(b) > maxCells {
+		for _, line := range a {
+			ops = append(ops, op{opDelete, line})
+		}
+		for _, line := range b {
+			ops = append(ops, op{opInsert, line})
+		}
+		return ops
+	}
+
+	// table[i][j] is the length of the LCS of a[i:] and b[j:].
+	table := make([][]int, len(a)+1)
+	for i := range table {
+		table[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else {
+				table[i][j] = max(table[i+1][j], table[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{opEqual, a[i]})
+			i, j = i+1, j+1
+		case table[i+1][j] >= table[i][j+1]:
+			ops = append(ops, op{opDelete, a[i]})
+			i++
+		default:
+			ops = append(ops, op{opInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, op{opDelete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, op{opInsert, b[j]})
+	}
+	return ops
+}
+
+// hunk is a range [start, end) of the edit script that will be printed.
+type hunk struct {
+	start, end int
+}
+
+func hunks(ops []op) []hunk {
+	var result []hunk
+	for i, op := range ops {
+		if op.kind == opEqual {
+			continue
+		}
+		start := max(i-contextLines, 0)
+		end := min(i+1+contextLines, len(ops))
+		if n := len(result); n > 0 && start <= result[n-1].end {
+			result[n-1].end = end
+			continue
+		}
+		result = append(result, hunk{start, end})
+	}
+	return result
+}
+
+func (h hunk) write(sb *strings.Builder, ops []op) {
+	// Line numbers are 1-based, and count the lines preceding the hunk on each
+	// side of the diff.
+	fromLine, toLine := 1, 1
+	for _, op := range ops[:h.start] {
+		if op.kind != opInsert {
+			fromLine++
+		}
+		if op.kind != opDelete {
+			toLine++
+		}
+	}
+	fromCount, toCount := 0, 0
+	for _, op := range ops[h.start:h.end] {
+		if op.kind != opInsert {
+			fromCount++
+		}
+		if op.kind != opDelete {
+			toCount++
+		}
+	}
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", lineRange(fromLine, fromCount), lineRange(toLine, toCount))
+	for _, op := range ops[h.start:h.end] {
+		prefix := " "
+		switch op.kind {
+		case opDelete:
+			prefix = "-"
+		case opInsert:
+			prefix = "+"
+		}
+		sb.WriteString(prefix + op.line)
+		if !strings.HasSuffix(op.line, "\n") {
+			sb.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func lineRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
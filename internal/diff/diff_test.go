@@ -0,0 +1,77 @@
+package diff_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/friendly-fhir/fhenix/internal/diff"
+)
+
+func TestUnified(t *testing.T) {
+	testCases := []struct {
+		name string
+		from string
+		to   string
+		want string
+	}{
+		{
+			name: "equal content",
+			from: "a\nb\n",
+			to:   "a\nb\n",
+			want: "",
+		}, {
+			name: "changed line",
+			from: "a\nb\nc\n",
+			to:   "a\nx\nc\n",
+			want: strings.Join([]string{
+				"--- old",
+				"+++ new",
+				"@@ -1,3 +1,3 @@",
+				" a",
+				"-b",
+				"+x",
+				" c",
+			}, "\n") + "\n",
+		}, {
+			name: "inserted line into empty",
+			from: "",
+			to:   "a\n",
+			want: strings.Join([]string{
+				"--- old",
+				"+++ new",
+				"@@ -0,0 +1 @@",
+				"+a",
+			}, "\n") + "\n",
+		}, {
+			name: "separate hunks",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			to:   "0\n2\n3\n4\n5\n6\n7\n8\n9\nx\n",
+			want: strings.Join([]string{
+				"--- old",
+				"+++ new",
+				"@@ -1,4 +1,4 @@",
+				"-1",
+				"+0",
+				" 2",
+				" 3",
+				" 4",
+				"@@ -7,4 +7,4 @@",
+				" 7",
+				" 8",
+				" 9",
+				"-10",
+				"+x",
+			}, "\n") + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := diff.Unified("old", "new", tc.from, tc.to)
+
+			if got != tc.want {
+				t.Errorf("Unified() = \n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
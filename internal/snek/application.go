@@ -161,7 +161,11 @@ func (a *Application) Execute(ctx context.Context) (code *StatusCode) {
 			}
 		}
 	}()
-	defer cursor.Show()
+	// Restoring the cursor is only meaningful on a terminal; when stdout is
+	// piped, the escape sequence would corrupt the output.
+	if IsTerminal(a.command.OutOrStdout()) {
+		defer cursor.Show()
+	}
 
 	err := a.command.ExecuteContext(ctx)
 	status := ExitSuccess
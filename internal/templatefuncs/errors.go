@@ -5,6 +5,7 @@ import "fmt"
 var (
 	ErrInvalidType     = fmt.Errorf("invalid type")
 	ErrIndexOutOfRange = fmt.Errorf("index out of range")
+	ErrUndefinedVar    = fmt.Errorf("undefined variable")
 )
 
 const (
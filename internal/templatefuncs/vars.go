@@ -0,0 +1,63 @@
+package templatefuncs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VarsModule provides access to the user-defined variables of the config.
+type VarsModule struct {
+	Reporter Reporter
+	Vars     map[string]any
+}
+
+// NewVarsFuncs returns the 'vars' template module for the given variables.
+func NewVarsFuncs(reporter Reporter, vars map[string]any) map[string]any {
+	return map[string]any{
+		"vars": get(&VarsModule{Reporter: reporter, Vars: vars}),
+	}
+}
+
+// Get returns the variable with the given name. Nested variables are named by
+// their dot-separated path, such as "go.package".
+func (m *VarsModule) Get(name string) any {
+	v, ok := m.lookup(name)
+	if !ok && m.Reporter != nil {
+		m.Reporter.Report(fmt.Errorf("%w: '%v'", ErrUndefinedVar, name))
+	}
+	return v
+}
+
+// Has returns true if the variable with the given name is defined.
+func (m *VarsModule) Has(name string) bool {
+	_, ok := m.lookup(name)
+	return ok
+}
+
+// Default returns the variable with the given name, or the fallback value if
+// it is not defined.
+func (m *VarsModule) Default(name string, fallback any) any {
+	if v, ok := m.lookup(name); ok {
+		return v
+	}
+	return fallback
+}
+
+// All returns all variables.
+func (m *VarsModule) All() map[string]any {
+	return m.Vars
+}
+
+func (m *VarsModule) lookup(name string) (any, bool) {
+	var current any = m.Vars
+	for _, part := range strings.Split(name, ".") {
+		vars, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = vars[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
@@ -0,0 +1,64 @@
+package templatefuncs_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/friendly-fhir/fhenix/internal/templatefuncs"
+)
+
+func TestVars_Get(t *testing.T) {
+	t.Parallel()
+
+	vars := map[string]any{
+		"namespace": "fhir",
+		"go": map[string]any{
+			"package": "r4",
+		},
+	}
+	tests := []struct {
+		name        string
+		key         string
+		want        any
+		wantReports int
+	}{
+		{"top-level", "namespace", "fhir", 0},
+		{"nested", "go.package", "r4", 0},
+		{"undefined", "missing", nil, 1},
+		{"undefined nested", "namespace.package", nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reports []error
+			m := &templatefuncs.VarsModule{
+				Vars:     vars,
+				Reporter: templatefuncs.ReporterFunc(func(err error) { reports = append(reports, err) }),
+			}
+
+			if got := m.Get(tt.key); got != tt.want {
+				t.Errorf("VarsModule.Get(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+			if got := len(reports); got != tt.wantReports {
+				t.Errorf("VarsModule.Get(%q) reported %d errors, want %d", tt.key, got, tt.wantReports)
+			}
+			for _, err := range reports {
+				if !errors.Is(err, templatefuncs.ErrUndefinedVar) {
+					t.Errorf("VarsModule.Get(%q) reported %v, want %v", tt.key, err, templatefuncs.ErrUndefinedVar)
+				}
+			}
+		})
+	}
+}
+
+func TestVars_Default(t *testing.T) {
+	t.Parallel()
+
+	m := &templatefuncs.VarsModule{Vars: map[string]any{"set": "value"}}
+
+	if got, want := m.Default("set", "fallback"), "value"; got != want {
+		t.Errorf("VarsModule.Default() = %v, want %v", got, want)
+	}
+	if got, want := m.Default("unset", "fallback"), "fallback"; got != want {
+		t.Errorf("VarsModule.Default() = %v, want %v", got, want)
+	}
+}
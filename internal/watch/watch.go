@@ -0,0 +1,110 @@
+/*
+Package watch provides a simple polling file-watcher.
+
+Polling is used rather than OS-level notifications so that the behavior is
+consistent across platforms, and so that editors which replace files on save
+(rather than writing to them) are still detected.
+*/
+package watch
+
+import (
+	"context"
+	"os"
+	"slices"
+	"time"
+)
+
+// DefaultInterval is the default interval at which files are polled.
+const DefaultInterval = 250 * time.Millisecond
+
+// Watcher polls a set of files for modifications.
+type Watcher struct {
+	interval time.Duration
+	files    map[string]state
+}
+
+// state is the observed state of a single file. Files that don't exist are
+// tracked with the zero state, so that their creation is observed as a change.
+type state struct {
+	modTime time.Time
+	size    int64
+}
+
+// New creates a new watcher that polls the given files at the given interval.
+func New(interval time.Duration, files ...string) *Watcher {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	w := &Watcher{
+		interval: interval,
+		files:    make(map[string]state, len(files)),
+	}
+	w.Set(files...)
+	return w
+}
+
+// Set replaces the set of files being watched by the watcher. The current state
+// of each file is used as the baseline for future changes.
+func (w *Watcher) Set(files ...string) {
+	w.files = make(map[string]state, len(files))
+	for _, file := range files {
+		w.files[file] = stat(file)
+	}
+}
+
+// Files returns the sorted list of files being watched.
+func (w *Watcher) Files() []string {
+	files := make([]string, 0, len(w.files))
+	for file := range w.files {
+		files = append(files, file)
+	}
+	slices.Sort(files)
+	return files
+}
+
+// Wait blocks until at least one of the watched files has been modified,
+// created, or removed, returning the sorted list of changed files.
+//
+// Changes are debounced by one interval, so that editors that write files in
+// several steps produce a single notification.
+func (w *Watcher) Wait(ctx context.Context) ([]string, error) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	var changed []string
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+		next := w.poll()
+		if len(next) == 0 && len(changed) > 0 {
+			slices.Sort(changed)
+			return slices.Compact(changed), nil
+		}
+		changed = append(changed, next...)
+	}
+}
+
+func (w *Watcher) poll() []string {
+	var changed []string
+	for file, last := range w.files {
+		if current := stat(file); current != last {
+			w.files[file] = current
+			changed = append(changed, file)
+		}
+	}
+	return changed
+}
+
+func stat(file string) state {
+	info, err := os.Stat(file)
+	if err != nil {
+		return state{}
+	}
+	return state{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+	}
+}
@@ -0,0 +1,56 @@
+package watch_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/friendly-fhir/fhenix/internal/watch"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestWatcher_Wait(t *testing.T) {
+	dir := t.TempDir()
+	modified := filepath.Join(dir, "modified.txt")
+	created := filepath.Join(dir, "created.txt")
+	unchanged := filepath.Join(dir, "unchanged.txt")
+	for _, file := range []string{modified, unchanged} {
+		if err := os.WriteFile(file, []byte("hello"), 0644); err != nil {
+			t.Fatalf("os.WriteFile() = %v", err)
+		}
+	}
+	sut := watch.New(10*time.Millisecond, modified, created, unchanged)
+
+	if err := os.WriteFile(modified, []byte("hello world"), 0644); err != nil {
+		t.Fatalf("os.WriteFile() = %v", err)
+	}
+	if err := os.WriteFile(created, []byte("new"), 0644); err != nil {
+		t.Fatalf("os.WriteFile() = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	got, err := sut.Wait(ctx)
+
+	if err != nil {
+		t.Fatalf("Watcher.Wait() = %v, want nil", err)
+	}
+	want := []string{created, modified}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Watcher.Wait() = %v, want %v", got, want)
+	}
+}
+
+func TestWatcher_Wait_ContextCancelled(t *testing.T) {
+	sut := watch.New(10*time.Millisecond, filepath.Join(t.TempDir(), "file.txt"))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := sut.Wait(ctx)
+
+	if got, want := err, context.DeadlineExceeded; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+		t.Errorf("Watcher.Wait() = %v, want %v", got, want)
+	}
+}
@@ -1,5 +1,11 @@
 package config
 
+import (
+	"io/fs"
+	"path/filepath"
+	"slices"
+)
+
 // Mode is the type of template system being used for the output.
 type Mode string
 
@@ -9,6 +15,11 @@ const (
 
 	// ModeHTML is the mode for HTML-based output.
 	ModeHTML Mode = "html"
+
+	// ModeIndent is the mode for text-based output, where templates are aware
+	// of indentation: lines that contain only control actions are removed, and
+	// the indentation of the body of a block is removed from its output.
+	ModeIndent Mode = "indent"
 )
 
 // Config represents the configuration that is used for generation within Fhenix.
@@ -19,15 +30,28 @@ type Config struct {
 	// Mode is the mode type of template system being used for the output.
 	Mode Mode
 
+	// Strict is true if every transformation is strict.
+	Strict bool
+
 	// OutputDir is the output directory where generated output will be written.
 	OutputDir string
 
 	// Input are the packages that will be used as input for the generation.
 	Input []*Package
 
+	// Vars are the user-defined variables at the root of the config, including
+	// any overrides. Each transform has its own merged set of variables.
+	Vars map[string]any
+
 	// Transforms contains a list of transforms to be applied to the input
 	// definitions.
 	Transforms []*Transform
+
+	// Includes are the absolute paths of every configuration file, template
+	// pack archive, and template pack manifest that was included, directly or
+	// indirectly, by this configuration, along with the templates and funcs of
+	// template pack directories.
+	Includes []string
 }
 
 // Package is the source package that will be used as input for the generation.
@@ -47,6 +71,20 @@ type Package struct {
 // Transform is a configuration for transforming input entities into templated
 // output(s).
 type Transform struct {
+	// Name is an optional name that identifies the transformation, which is
+	// available to templates.
+	Name string
+
+	// Mode is the mode type of template system being used for the output of
+	// this transformation. If empty, the mode of the config is used.
+	Mode Mode
+
+	// Strict fails the transformation when a func has an error, when a template
+	// module reports an error, or when a template indexes a map with a missing
+	// key, rather than reporting the error as a warning. A transformation is
+	// strict if it, any preset it extends, or the config is strict.
+	Strict bool
+
 	// Include is a list of filters for conditions that an entity may satisfy to
 	// be included in the transformation. At least one of these filters must be
 	// satisfied for an entity to be included.
@@ -64,7 +102,9 @@ type Transform struct {
 
 	// Funcs is mapping of template function-names to files containing templates
 	// that will perform textual transformations. This enables more complex logic
-	// to be included in template pipelines.
+	// to be included in template pipelines. Files with the '.expr' extension
+	// are instead scripts in the expr language, which may take typed arguments
+	// and return values of any type.
 	Funcs map[string]string
 
 	// Templates is a mapping of template names to files containing
@@ -85,6 +125,66 @@ type Transform struct {
 	//   'header', followed by the appropriate intermediate template, followed by
 	//   'footer'. Replacing this will replace all the above templates.
 	Templates map[string]string
+
+	// Vars are the user-defined variables available to the templates, output
+	// path and filter conditions of this transformation. These are merged from
+	// the root of the config, the defaults, the extended presets, the
+	// transformation itself, and any overrides, in increasing order of
+	// precedence.
+	Vars map[string]any
+
+	// PostProcess is a pipeline of steps that the rendered output is passed
+	// through, in order, before it is written.
+	PostProcess []*PostProcess
+
+	// ProtectedRegions are the markers that delimit hand-written regions of an
+	// output file, which are preserved when the file is regenerated. If nil,
+	// outputs have no protected regions.
+	ProtectedRegions *ProtectedRegions
+
+	// FS is the file system that relative template and func paths are read
+	// from, such as that of a template pack. Absolute paths are always read
+	// from the local file system. If nil, all paths are absolute.
+	FS fs.FS
+}
+
+// ProtectedRegions are the markers that delimit protected regions. A region
+// begins on a line containing the Begin marker followed by the region name, and
+// ends on the next line containing the End marker.
+type ProtectedRegions struct {
+	Begin string
+	End   string
+}
+
+// PostProcess is a single step of a post-process pipeline. Exactly one of
+// Format or Command is set.
+type PostProcess struct {
+	// Format is the name of a builtin formatter; one of 'gofmt', 'json',
+	// 'trim-trailing-whitespace', or 'collapse-blank-lines'.
+	Format string
+
+	// Command is an external command, and its arguments, that reads the
+	// rendered output from stdin and writes the processed output to stdout.
+	Command []string
+}
+
+// Files returns the sorted list of template and function files on the local
+// file system that are referenced by this transform. Files within the FS of
+// the transform are not included.
+func (t *Transform) Files() []string {
+	files := make([]string, 0, len(t.Funcs)+len(t.Templates))
+	for _, path := range t.Funcs {
+		if t.FS == nil || filepath.IsAbs(path) {
+			files = append(files, path)
+		}
+	}
+	for _, path := range t.Templates {
+		if t.FS == nil || filepath.IsAbs(path) {
+			files = append(files, path)
+		}
+	}
+	slices.Sort(files)
+	return slices.Compact(files)
 }
 
 type TransformFilter struct {
@@ -110,4 +210,41 @@ type TransformFilter struct {
 	// This is meant as a back-door to allow for more complex conditions than
 	// the other filters allow.
 	Condition string
+
+	// Query is a query expression that the input entity must satisfy, such as
+	// 'kind == "resource" && !abstract'. Unlike a condition, the query is
+	// type-checked when the config is loaded. See the query package of the
+	// model for the language.
+	Query string
+
+	// Kind is an exact-match filter on the kind of the input entity, such as
+	// 'resource', 'complex-type', 'primitive-type', or 'logical'.
+	Kind string
+
+	// Derivation is an exact-match filter on the derivation of the input
+	// entity, which is either 'specialization' or 'constraint'.
+	Derivation string
+
+	// Abstract is a filter on whether the input entity is abstract, if set.
+	Abstract *bool
+
+	// Base is the name or URL of a type that the input entity must derive from,
+	// directly or transitively.
+	Base string
+
+	// Status is an exact-match filter on the publication status of the input
+	// entity, such as 'draft', 'active', 'retired', or 'unknown'.
+	Status string
+
+	// Experimental is a filter on whether the input entity is marked as
+	// experimental, if set.
+	Experimental *bool
+
+	// PackageVersion is an exact-match filter on the version of the package
+	// that the input entity is defined in.
+	PackageVersion string
+
+	// Publisher is a filter on the publisher of the input entity.
+	// This may be a regular expression.
+	Publisher string
 }
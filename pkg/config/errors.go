@@ -1,5 +1,22 @@
 package config
 
-import "errors"
+import (
+	"errors"
 
-var ErrInvalidVersion = errors.New("invalid version")
+	"github.com/friendly-fhir/fhenix/pkg/config/internal/pack"
+)
+
+var (
+	ErrInvalidVersion = errors.New("invalid version")
+	ErrUndefinedEnv   = errors.New("undefined environment variable")
+
+	ErrIncludeCycle    = errors.New("config includes itself")
+	ErrUnknownPreset   = errors.New("unknown preset")
+	ErrDuplicatePreset = errors.New("preset defined more than once")
+	ErrPresetCycle     = errors.New("preset extends itself")
+
+	ErrUnknownPackFormat = errors.New("template pack is not a directory, '.tar.gz', or '.zip'")
+	ErrDuplicatePack     = errors.New("template pack included more than once")
+	ErrPackConflict      = errors.New("transform uses files from more than one template pack")
+	ErrNoPackManifest    = pack.ErrNoManifest
+)
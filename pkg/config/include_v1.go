@@ -0,0 +1,376 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/friendly-fhir/fhenix/pkg/config/internal/cfg/v1"
+	"github.com/friendly-fhir/fhenix/pkg/config/internal/opts"
+	"github.com/friendly-fhir/fhenix/pkg/config/internal/pack"
+	"gopkg.in/yaml.v3"
+)
+
+// v1Source is a raw transform along with the options of the file it was
+// defined in, which its paths are relative to.
+type v1Source struct {
+	opts      *opts.Options
+	transform *cfg.Transform
+
+	// pack is the name of the template pack whose file system the paths of
+	// the transform are within, if any.
+	pack string
+}
+
+// v1Composition is a configuration file composed with all of the files that it
+// includes.
+type v1Composition struct {
+	// files are the absolute paths of every included file and template pack,
+	// in the order they were loaded.
+	files []string
+
+	// packs are the names of every template pack.
+	packs map[string]struct{}
+
+	// vars are the interpolated root variables of every file, where the
+	// variables of an including file take precedence over those it includes.
+	vars map[string]any
+
+	// presets are the raw presets of every file, by name.
+	presets map[string]*v1Source
+
+	// transforms are the raw transforms of every file, where the transforms of
+	// included files come first.
+	transforms []*v1Source
+
+	// resolved are the presets that have already been resolved, and resolving
+	// are the presets currently being resolved, for detecting cycles.
+	resolved  map[string]*v1Resolved
+	resolving map[string]struct{}
+
+	// loading are the files currently being loaded, for detecting cycles.
+	loading []string
+}
+
+// composeV1 composes the root configuration with all of the files that it
+// includes, recursively.
+func composeV1(opts *opts.Options, root *cfg.Root) (*v1Composition, error) {
+	result := newV1Composition()
+	if err := result.include(opts, root.Include); err != nil {
+		return nil, err
+	}
+	if err := result.loadPacks(opts, root.Packs); err != nil {
+		return nil, err
+	}
+	if err := result.add(opts, root.Vars, root.Presets, root.Transforms); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func newV1Composition() *v1Composition {
+	return &v1Composition{
+		packs:     map[string]struct{}{},
+		presets:   map[string]*v1Source{},
+		resolved:  map[string]*v1Resolved{},
+		resolving: map[string]struct{}{},
+	}
+}
+
+// include loads each of the included files, relative to the root of the
+// options.
+func (c *v1Composition) include(opts *opts.Options, includes []string) error {
+	for _, include := range includes {
+		path, err := opts.RootPath(include)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(c.loading, path) {
+			return fmt.Errorf("%w: %v", ErrIncludeCycle, path)
+		}
+		// Files that are included more than once, such as by two files that
+		// share a common base, are only composed once.
+		if slices.Contains(c.files, path) {
+			continue
+		}
+		if err := c.load(opts, path); err != nil {
+			return fmt.Errorf("include '%v': %w", include, err)
+		}
+	}
+	return nil
+}
+
+// load composes the fragment at the given absolute path, whose paths are
+// relative to its own directory.
+func (c *v1Composition) load(parent *opts.Options, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var fragment cfg.Fragment
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&fragment); err != nil {
+		return err
+	}
+	if fragment.Version != 0 && fragment.Version != 1 {
+		return fmt.Errorf("%w: version '%d' is not supported", ErrInvalidVersion, fragment.Version)
+	}
+
+	opts := *parent
+	opts.RootDir = filepath.Dir(path)
+
+	c.loading = append(c.loading, path)
+	defer func() { c.loading = c.loading[:len(c.loading)-1] }()
+	if err := c.include(&opts, fragment.Include); err != nil {
+		return err
+	}
+	if err := c.loadPacks(&opts, fragment.Packs); err != nil {
+		return err
+	}
+	c.files = append(c.files, path)
+	return c.add(&opts, fragment.Vars, fragment.Presets, fragment.Transforms)
+}
+
+// add adds the contents of a single file to the composition.
+func (c *v1Composition) add(opts *opts.Options, vars map[string]any, presets map[string]*cfg.Transform, transforms []*cfg.Transform) error {
+	interpolated, err := interpolateVars(vars)
+	if err != nil {
+		return fmt.Errorf("vars: %w", err)
+	}
+	c.vars = mergeVars(c.vars, interpolated)
+
+	for name, preset := range presets {
+		if _, ok := c.presets[name]; ok {
+			return fmt.Errorf("%w: '%v'", ErrDuplicatePreset, name)
+		}
+		c.presets[name] = &v1Source{opts: opts, transform: preset}
+	}
+	for _, transform := range transforms {
+		c.transforms = append(c.transforms, &v1Source{opts: opts, transform: transform})
+	}
+	return nil
+}
+
+// v1Resolved is a transform that has been merged with every preset that it
+// extends, along with the template pack whose file system it uses, if any.
+type v1Resolved struct {
+	transform *Transform
+	pack      string
+}
+
+// transform converts the raw transform, with paths relative to the root of
+// the options of its source, and merges it with every preset that it extends.
+func (c *v1Composition) transform(source *v1Source) (*v1Resolved, error) {
+	transform, err := fromV1Transform(source.opts, source.transform)
+	if err != nil {
+		return nil, err
+	}
+	result := &v1Resolved{transform: transform, pack: source.pack}
+	// Later presets take precedence over earlier ones, so they are merged first.
+	extends := source.transform.Extends
+	for i := len(extends) - 1; i >= 0; i-- {
+		preset, err := c.preset(extends[i])
+		if err != nil {
+			return nil, err
+		}
+		if err := result.merge(preset); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// merge merges the base into the result. A transform can only read files from
+// the file system of a single template pack.
+func (r *v1Resolved) merge(base *v1Resolved) error {
+	if base.pack != "" {
+		if r.pack != "" && r.pack != base.pack {
+			return fmt.Errorf("%w: '%v' and '%v'", ErrPackConflict, r.pack, base.pack)
+		}
+		r.pack = base.pack
+	}
+	r.transform = mergeTransforms(base.transform, r.transform)
+	return nil
+}
+
+// preset returns the named preset, merged with every preset it extends.
+func (c *v1Composition) preset(name string) (*v1Resolved, error) {
+	if preset, ok := c.resolved[name]; ok {
+		return preset, nil
+	}
+	source, ok := c.presets[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: '%v'", ErrUnknownPreset, name)
+	}
+	if _, ok := c.resolving[name]; ok {
+		return nil, fmt.Errorf("%w: '%v'", ErrPresetCycle, name)
+	}
+	c.resolving[name] = struct{}{}
+	defer delete(c.resolving, name)
+
+	preset, err := c.transform(source)
+	if err != nil {
+		return nil, fmt.Errorf("preset '%v': %w", name, err)
+	}
+	c.resolved[name] = preset
+	return preset, nil
+}
+
+// loadPacks loads each of the template packs, relative to the root of the
+// options.
+func (c *v1Composition) loadPacks(opts *opts.Options, packs []string) error {
+	for _, p := range packs {
+		path, err := opts.RootPath(p)
+		if err != nil {
+			return err
+		}
+		if err := c.loadPack(opts, path); err != nil {
+			return fmt.Errorf("pack '%v': %w", p, err)
+		}
+	}
+	return nil
+}
+
+// loadPack composes the template pack at the given absolute path, unless it
+// was already loaded. The files of a directory are read in place, whereas
+// archives are read into memory; either way, the paths of the pack are
+// confined to its file system.
+func (c *v1Composition) loadPack(parent *opts.Options, path string) error {
+	opts := *parent
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	// The manifest of a directory is recorded rather than the directory itself,
+	// since only the manifest can be watched for changes.
+	file := path
+	if info.IsDir() {
+		file = filepath.Join(path, pack.ManifestFile)
+	}
+	if slices.Contains(c.files, file) {
+		return nil
+	}
+
+	switch {
+	case info.IsDir():
+		opts.RootDir = "."
+		opts.FS = os.DirFS(path)
+	case strings.HasSuffix(path, ".tar.gz"), strings.HasSuffix(path, ".tgz"):
+		opts.RootDir = "."
+		opts.FS, err = pack.OpenTarball(path)
+	case strings.HasSuffix(path, ".zip"):
+		opts.RootDir = "."
+		opts.FS, err = pack.OpenZip(path)
+	default:
+		return ErrUnknownPackFormat
+	}
+	if err != nil {
+		return err
+	}
+
+	manifest, err := readPackManifest(opts.FS)
+	if err != nil {
+		return err
+	}
+	c.files = append(c.files, file)
+	if info.IsDir() {
+		c.files = append(c.files, packFiles(path, manifest)...)
+	}
+	return c.addPack(&opts, manifest)
+}
+
+// packFiles returns the absolute paths of the templates and funcs of the
+// directory pack, so that they are watched for changes along with its
+// manifest. Paths outside of the pack are rejected when the pack is added.
+func packFiles(dir string, manifest *cfg.Pack) []string {
+	var result []string
+	add := func(name string) {
+		if name == "" || !fs.ValidPath(name) {
+			return
+		}
+		result = append(result, filepath.Join(dir, filepath.FromSlash(name)))
+	}
+	transforms := slices.Clone(manifest.Transforms)
+	for _, preset := range manifest.Presets {
+		transforms = append(transforms, preset)
+	}
+	for _, transform := range transforms {
+		for _, name := range transform.Funcs {
+			add(name)
+		}
+		if templates := transform.Templates; templates != nil {
+			for _, name := range []string{templates.Main, templates.Header, templates.Footer, templates.CodeSystem, templates.ValueSet, templates.Type} {
+				add(name)
+			}
+			for _, name := range templates.Partials {
+				add(name)
+			}
+		}
+	}
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+// readPackManifest reads the manifest at the root of the template pack.
+func readPackManifest(fsys fs.FS) (*cfg.Pack, error) {
+	data, err := fs.ReadFile(fsys, pack.ManifestFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, pack.ErrNoManifest
+	}
+	if err != nil {
+		return nil, err
+	}
+	var manifest cfg.Pack
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&manifest); err != nil {
+		return nil, err
+	}
+	if manifest.Version != 0 && manifest.Version != 1 {
+		return nil, fmt.Errorf("%w: version '%d' is not supported", ErrInvalidVersion, manifest.Version)
+	}
+	return &manifest, nil
+}
+
+// addPack adds the contents of a template pack to the composition. Presets of
+// the pack are namespaced by the name of the pack, and the presets that its
+// transforms extend refer to the presets of the pack.
+func (c *v1Composition) addPack(opts *opts.Options, manifest *cfg.Pack) error {
+	if _, ok := c.packs[manifest.Name]; ok {
+		return fmt.Errorf("%w: '%v'", ErrDuplicatePack, manifest.Name)
+	}
+	c.packs[manifest.Name] = struct{}{}
+
+	var name string
+	if opts.FS != nil {
+		name = manifest.Name
+	}
+	qualify := func(transform *cfg.Transform) *cfg.Transform {
+		result := *transform
+		result.Extends = make(cfg.Names, len(transform.Extends))
+		for i, preset := range transform.Extends {
+			result.Extends[i] = manifest.Name + "." + preset
+		}
+		return &result
+	}
+
+	interpolated, err := interpolateVars(manifest.Vars)
+	if err != nil {
+		return fmt.Errorf("vars: %w", err)
+	}
+	c.vars = mergeVars(c.vars, interpolated)
+
+	for preset, transform := range manifest.Presets {
+		c.presets[manifest.Name+"."+preset] = &v1Source{opts: opts, transform: qualify(transform), pack: name}
+	}
+	for _, transform := range manifest.Transforms {
+		c.transforms = append(c.transforms, &v1Source{opts: opts, transform: qualify(transform), pack: name})
+	}
+	return nil
+}
@@ -23,6 +23,10 @@ func zeroIfNil[T any](t *T) *T {
 	return &result
 }
 
+func ptr[T any](v T) *T {
+	return &v
+}
+
 func TestInput(t *testing.T) {
 	testCases := []struct {
 		name    string
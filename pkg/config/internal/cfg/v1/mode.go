@@ -10,8 +10,9 @@ import (
 type Mode string
 
 const (
-	ModeText Mode = "text"
-	ModeHTML Mode = "html"
+	ModeText   Mode = "text"
+	ModeHTML   Mode = "html"
+	ModeIndent Mode = "indent"
 )
 
 // UnmarshalYAML unmarshals a YAML node into a Mode.
@@ -22,12 +23,12 @@ func (m *Mode) UnmarshalYAML(node *yaml.Node) error {
 	}
 
 	switch s {
-	case "text", "html":
+	case "text", "html", "indent":
 		*m = Mode(s)
 	default:
 		return &cfg.FieldError{
 			Field: "mode",
-			Err:   fmt.Errorf("%w: '%v', expected 'text' or 'html'", cfg.ErrInvalidField, s),
+			Err:   fmt.Errorf("%w: '%v', expected 'text', 'html', or 'indent'", cfg.ErrInvalidField, s),
 		}
 	}
 
@@ -27,6 +27,11 @@ func TestMode(t *testing.T) {
 			input:   "html",
 			want:    cfg.Mode("html"),
 			wantErr: nil,
+		}, {
+			name:    "'indent' Mode",
+			input:   "indent",
+			want:    cfg.Mode("indent"),
+			wantErr: nil,
 		}, {
 			name:    "invalid Mode",
 			input:   "invalid",
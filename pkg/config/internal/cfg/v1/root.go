@@ -1,5 +1,13 @@
 package cfg
 
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/friendly-fhir/fhenix/pkg/config/internal/cfg"
+	"gopkg.in/yaml.v3"
+)
+
 // Root is the root configuration node of the YAML file.
 type Root struct {
 	// Version is the schema version of the configuration file.
@@ -7,9 +15,13 @@ type Root struct {
 	Version int `yaml:"version"`
 
 	// Mode is the mode type of template output that will be used for the
-	// generated output. May be one of 'text' or 'html'.
+	// generated output. May be one of 'text', 'html', or 'indent'.
 	Mode Mode `yaml:"mode"`
 
+	// Strict makes every transformation strict, where errors reported by
+	// templates and missing map keys fail the transformation.
+	Strict bool `yaml:"strict"`
+
 	// RootDir is the root directory of the project.
 	//
 	// Relative paths will be translated to coherent roots in the 'config' package,
@@ -24,6 +36,25 @@ type Root struct {
 	// parsed (if specified), or relative to the current working directory if not.
 	OutputDir string `yaml:"output-dir"`
 
+	// Vars are user-defined variables that are available to all transforms.
+	// String values may reference environment variables with '${NAME}'.
+	Vars map[string]any `yaml:"vars"`
+
+	// Include is a list of paths to other configuration files, whose presets,
+	// transforms, and variables are merged into this configuration. Paths in
+	// each included file are relative to the directory of that file.
+	Include []string `yaml:"include"`
+
+	// Packs is a list of paths to template packs, which may be directories,
+	// gzipped tarballs, or zip archives. The presets of a pack are available to
+	// 'extends' as '<pack>.<preset>', and its transforms are applied along with
+	// the transforms of this configuration.
+	Packs []string `yaml:"packs"`
+
+	// Presets are named transforms that transformations may inherit from with
+	// 'extends'. Presets are never transformed on their own.
+	Presets map[string]*Transform `yaml:"presets"`
+
 	// Default is a configuration node that specifies default values to use for
 	// transforms. This just helps to reduce the boilerplate when several
 	// transformations use the same set of templates.
@@ -35,3 +66,78 @@ type Root struct {
 	// Transforms is a list of transformations to apply to the input data.
 	Transforms []*Transform `yaml:"transforms"`
 }
+
+// Fragment is the root configuration node of a YAML file that is included by
+// another configuration file. It may only contribute presets, transforms, and
+// variables; everything else is determined by the including configuration.
+type Fragment struct {
+	// Version is the schema version of the configuration file. This is
+	// optional, but must be '1' if specified.
+	Version int `yaml:"version"`
+
+	// Include is a list of paths to further configuration files to include,
+	// relative to the directory of this file.
+	Include []string `yaml:"include"`
+
+	// Packs is a list of paths to template packs to include, relative to the
+	// directory of this file.
+	Packs []string `yaml:"packs"`
+
+	// Vars are user-defined variables that are available to all transforms.
+	// Variables of the including configuration take precedence.
+	Vars map[string]any `yaml:"vars"`
+
+	// Presets are named transforms that transformations may inherit from with
+	// 'extends'.
+	Presets map[string]*Transform `yaml:"presets"`
+
+	// Transforms is a list of transformations to apply to the input data. These
+	// come before the transformations of the including configuration.
+	Transforms []*Transform `yaml:"transforms"`
+}
+
+// Pack is the root configuration node of the manifest of a template pack. All
+// paths in a pack are relative to the root of the pack, and must be within it.
+type Pack struct {
+	// Version is the schema version of the manifest. This is optional, but must
+	// be '1' if specified.
+	Version int `yaml:"version"`
+
+	// Name is the name of the pack, which namespaces its presets (mandatory).
+	Name string `yaml:"name"`
+
+	// Description is a human-readable description of the pack.
+	Description string `yaml:"description"`
+
+	// Vars are user-defined variables that are available to all transforms.
+	// Variables of the configuration that uses the pack take precedence.
+	Vars map[string]any `yaml:"vars"`
+
+	// Presets are named transforms that transformations may inherit from with
+	// 'extends'. Within the pack, presets are referred to by their plain name.
+	Presets map[string]*Transform `yaml:"presets"`
+
+	// Transforms is a list of transformations to apply to the input data.
+	Transforms []*Transform `yaml:"transforms"`
+}
+
+func (p *Pack) UnmarshalYAML(node *yaml.Node) error {
+	type pack Pack
+	var out pack
+	if err := node.Decode(&out); err != nil {
+		return err
+	}
+	if out.Name == "" {
+		return &cfg.FieldError{Field: "name", Err: cfg.ErrMissingField}
+	}
+	if !packNameRegex.MatchString(out.Name) {
+		return &cfg.FieldError{
+			Field: "name",
+			Err:   fmt.Errorf("%w: '%v' is not a valid pack name", cfg.ErrInvalidField, out.Name),
+		}
+	}
+	*p = Pack(out)
+	return nil
+}
+
+var packNameRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_\-]*$`)
@@ -4,11 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"slices"
 	"strings"
 	"text/template"
 
 	"github.com/friendly-fhir/fhenix/internal/templatefuncs"
 	"github.com/friendly-fhir/fhenix/pkg/config/internal/cfg"
+	"github.com/friendly-fhir/fhenix/pkg/model/query"
 
 	"gopkg.in/yaml.v3"
 )
@@ -16,6 +18,24 @@ import (
 // Transform is a configuration for transforming input entities into templated
 // output(s).
 type Transform struct {
+	// Name is an optional name that identifies the transformation, which is
+	// available to templates.
+	Name string `yaml:"name"`
+
+	// Extends is a list of names of presets that this transformation inherits
+	// from. Later presets take precedence over earlier ones, and the
+	// transformation itself takes precedence over all of them.
+	Extends Names `yaml:"extends"`
+
+	// Mode is the mode type of template output that will be used for this
+	// transformation, overriding the mode at the root of the config.
+	Mode Mode `yaml:"mode"`
+
+	// Strict fails the transformation when a func has an error, when a template
+	// module reports an error, or when a template indexes a map with a missing
+	// key, rather than reporting the error as a warning.
+	Strict bool `yaml:"strict"`
+
 	// Include is a list of filters for conditions that an entity may satisfy to
 	// be included in the transformation. At least one of these filters must be
 	// satisfied for an entity to be included.
@@ -33,7 +53,9 @@ type Transform struct {
 
 	// Funcs is mapping of template function-names to files containing templates
 	// that will perform textual transformations. This enables more complex logic
-	// to be included in template pipelines.
+	// to be included in template pipelines. Files with the '.expr' extension
+	// are instead scripts in the expr language, which may take typed arguments
+	// and return values of any type.
 	Funcs map[string]string `yaml:"funcs"`
 
 	// Templates is a mapping of template names to files containing
@@ -54,6 +76,18 @@ type Transform struct {
 	//   'header', followed by the appropriate intermediate template, followed by
 	//   'footer'. Replacing this will replace all the above templates.
 	Templates *TransformTemplates `yaml:"templates"`
+
+	// Vars are user-defined variables for this transform, which take precedence
+	// over the variables at the root of the config.
+	Vars map[string]any `yaml:"vars"`
+
+	// PostProcess is a pipeline of steps that the rendered output is passed
+	// through, in order, before it is written.
+	PostProcess []*PostProcessStep `yaml:"post-process"`
+
+	// ProtectedRegions are the markers that delimit hand-written regions of an
+	// output file, which are preserved when the file is regenerated.
+	ProtectedRegions *ProtectedRegions `yaml:"protected-regions"`
 }
 
 func (t *Transform) UnmarshalYAML(node *yaml.Node) error {
@@ -84,6 +118,26 @@ func (t *Transform) UnmarshalYAML(node *yaml.Node) error {
 	return nil
 }
 
+// Names is a list of names, which may also be written as a single string.
+type Names []string
+
+func (n *Names) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		var name string
+		if err := node.Decode(&name); err != nil {
+			return err
+		}
+		*n = Names{name}
+		return nil
+	}
+	var names []string
+	if err := node.Decode(&names); err != nil {
+		return err
+	}
+	*n = names
+	return nil
+}
+
 type TransformTemplates struct {
 	// Header is a template that will be included at the top of the output file.
 	Header string `yaml:"header"`
@@ -136,6 +190,116 @@ func (tt *TransformTemplates) UnmarshalYAML(node *yaml.Node) error {
 	return nil
 }
 
+// Builtin post-process formatters.
+const (
+	PostProcessGofmt                  = "gofmt"
+	PostProcessJSON                   = "json"
+	PostProcessTrimTrailingWhitespace = "trim-trailing-whitespace"
+	PostProcessCollapseBlankLines     = "collapse-blank-lines"
+)
+
+var postProcessFormats = []string{
+	PostProcessGofmt,
+	PostProcessJSON,
+	PostProcessTrimTrailingWhitespace,
+	PostProcessCollapseBlankLines,
+}
+
+// PostProcessStep is a single step of a post-process pipeline. It may either
+// be written as a plain string naming a builtin formatter, or as an object
+// specifying an external command.
+type PostProcessStep struct {
+	// Format is the name of a builtin formatter.
+	Format string `yaml:"format"`
+
+	// Command is an external command, and its arguments, that reads the
+	// rendered output from stdin and writes the processed output to stdout.
+	Command []string `yaml:"command"`
+}
+
+func (ps *PostProcessStep) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		var format string
+		if err := node.Decode(&format); err != nil {
+			return err
+		}
+		node = &yaml.Node{
+			Kind: yaml.MappingNode,
+			Content: []*yaml.Node{
+				{Kind: yaml.ScalarNode, Value: "format"},
+				{Kind: yaml.ScalarNode, Value: format},
+			},
+		}
+	}
+
+	type postProcessStep PostProcessStep
+	var out postProcessStep
+	if err := node.Decode(&out); err != nil {
+		return err
+	}
+
+	switch {
+	case out.Format == "" && len(out.Command) == 0:
+		return &cfg.FieldError{
+			Field: "transform.post-process",
+			Err:   fmt.Errorf("%w: one of 'format' or 'command' must be specified", cfg.ErrMissingField),
+		}
+	case out.Format != "" && len(out.Command) != 0:
+		return &cfg.FieldError{
+			Field: "transform.post-process",
+			Err:   fmt.Errorf("%w: only one of 'format' or 'command' may be specified", cfg.ErrInvalidField),
+		}
+	case out.Format != "" && !slices.Contains(postProcessFormats, out.Format):
+		return &cfg.FieldError{
+			Field: "transform.post-process.format",
+			Err:   fmt.Errorf("%w: unknown format '%v'", cfg.ErrInvalidField, out.Format),
+		}
+	case len(out.Command) != 0 && strings.TrimSpace(out.Command[0]) == "":
+		return &cfg.FieldError{
+			Field: "transform.post-process.command",
+			Err:   fmt.Errorf("%w: command name must not be empty", cfg.ErrInvalidField),
+		}
+	}
+
+	*ps = PostProcessStep(out)
+	return nil
+}
+
+// ProtectedRegions are the markers that delimit protected regions. A region
+// begins on a line containing the begin marker followed by the region name,
+// and ends on the next line containing the end marker.
+type ProtectedRegions struct {
+	// Begin is the marker that begins a named protected region.
+	Begin string `yaml:"begin"`
+
+	// End is the marker that ends a protected region.
+	End string `yaml:"end"`
+}
+
+func (pr *ProtectedRegions) UnmarshalYAML(node *yaml.Node) error {
+	type protectedRegions ProtectedRegions
+	var out protectedRegions
+	if err := node.Decode(&out); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(out.Begin) == "" || strings.TrimSpace(out.End) == "" {
+		return &cfg.FieldError{
+			Field: "transform.protected-regions",
+			Err:   fmt.Errorf("%w: both 'begin' and 'end' markers must be specified", cfg.ErrMissingField),
+		}
+	}
+	if strings.Contains(out.Begin, out.End) || strings.Contains(out.End, out.Begin) {
+		return &cfg.FieldError{
+			Field: "transform.protected-regions",
+			Err:   fmt.Errorf("%w: 'begin' and 'end' markers must not contain each other", cfg.ErrInvalidField),
+		}
+	}
+
+	*pr = ProtectedRegions(out)
+	return nil
+}
+
 type TransformFilterType string
 
 const (
@@ -166,13 +330,66 @@ type TransformFilter struct {
 	// This is meant as a back-door to allow for more complex conditions than
 	// the other filters allow.
 	Condition string `yaml:"condition"`
+
+	// Query is a query expression that the input entity must satisfy, such as
+	// 'kind == "resource" && !abstract'.
+	Query string `yaml:"query"`
+
+	// Kind is an exact-match filter on the kind of the input entity.
+	Kind string `yaml:"kind"`
+
+	// Derivation is an exact-match filter on the derivation of the input entity.
+	Derivation string `yaml:"derivation"`
+
+	// Abstract is a filter on whether the input entity is abstract.
+	Abstract *bool `yaml:"abstract"`
+
+	// Base is the name or URL of a type that the input entity must derive from,
+	// directly or transitively.
+	Base string `yaml:"base"`
+
+	// Status is an exact-match filter on the publication status of the input
+	// entity.
+	Status string `yaml:"status"`
+
+	// Experimental is a filter on whether the input entity is experimental.
+	Experimental *bool `yaml:"experimental"`
+
+	// PackageVersion is an exact-match filter on the version of the package
+	// that the input entity is defined in.
+	PackageVersion string `yaml:"package-version"`
+
+	// Publisher is a filter on the publisher of the input entity.
+	// This may be a regular expression.
+	Publisher string `yaml:"publisher"`
+}
+
+var (
+	filterKinds       = []string{"resource", "complex-type", "primitive-type", "logical"}
+	filterDerivations = []string{"specialization", "constraint"}
+	filterStatuses    = []string{"draft", "active", "retired", "unknown"}
+)
+
+func verifyOneOf(v string, values []string) error {
+	if v == "" || slices.Contains(values, v) {
+		return nil
+	}
+	return fmt.Errorf("%w: '%v', expected '%v'", cfg.ErrInvalidField, v, strings.Join(values, "', '"))
+}
+
+func verifyQuery(v string) error {
+	if strings.TrimSpace(v) == "" {
+		return nil
+	}
+	_, err := query.Compile(v)
+	return err
 }
 
 func verifyTemplate(v string) error {
 	if strings.TrimSpace(v) == "" {
 		return nil
 	}
-	_, err := template.New("").Funcs(templatefuncs.NewFuncs(nil)).Parse(v)
+	_, err := template.New("").Funcs(templatefuncs.NewFuncs(nil)).Funcs(templatefuncs.NewVarsFuncs(nil, nil)).Parse(v)
 	return err
 }
 
@@ -208,7 +425,9 @@ func (tf *TransformFilter) UnmarshalYAML(node *yaml.Node) error {
 		return err
 	}
 
-	if !hasSet(out.Name, out.Type, out.URL, out.Package, out.Source, out.Condition) {
+	hasBool := out.Abstract != nil || out.Experimental != nil
+	if !hasBool && !hasSet(out.Name, out.Type, out.URL, out.Package, out.Source, out.Condition, out.Query,
+		out.Kind, out.Derivation, out.Base, out.Status, out.PackageVersion, out.Publisher) {
 		return &cfg.FieldError{
 			Field: "transform.filter",
 			Err:   fmt.Errorf("%w: at least one filter option must be specified", cfg.ErrMissingField),
@@ -223,6 +442,28 @@ func (tf *TransformFilter) UnmarshalYAML(node *yaml.Node) error {
 		return &cfg.FieldError{Field: "transform.filter.condition", Err: err}
 	}
 
+	if err := verifyQuery(out.Query); err != nil {
+		return &cfg.FieldError{Field: "transform.filter.query", Err: err}
+	}
+
+	if err := verifyRegex(out.Publisher); err != nil {
+		return &cfg.FieldError{Field: "transform.filter.publisher", Err: err}
+	}
+
+	var errs []error
+	if err := verifyOneOf(out.Kind, filterKinds); err != nil {
+		errs = append(errs, &cfg.FieldError{Field: "transform.filter.kind", Err: err})
+	}
+	if err := verifyOneOf(out.Derivation, filterDerivations); err != nil {
+		errs = append(errs, &cfg.FieldError{Field: "transform.filter.derivation", Err: err})
+	}
+	if err := verifyOneOf(out.Status, filterStatuses); err != nil {
+		errs = append(errs, &cfg.FieldError{Field: "transform.filter.status", Err: err})
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
 	*tf = TransformFilter(out)
 
 	return nil
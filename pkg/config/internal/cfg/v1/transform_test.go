@@ -5,6 +5,7 @@ import (
 
 	rootcfg "github.com/friendly-fhir/fhenix/pkg/config/internal/cfg"
 	"github.com/friendly-fhir/fhenix/pkg/config/internal/cfg/v1"
+	"github.com/friendly-fhir/fhenix/pkg/model/query"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"gopkg.in/yaml.v3"
@@ -153,6 +154,116 @@ func TestTransformTemplates(t *testing.T) {
 	}
 }
 
+func TestPostProcessStep(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   string
+		want    *cfg.PostProcessStep
+		wantErr error
+	}{
+		{
+			name:  "builtin format as string",
+			input: `gofmt`,
+			want: &cfg.PostProcessStep{
+				Format: "gofmt",
+			},
+		}, {
+			name:  "builtin format as object",
+			input: `format: json`,
+			want: &cfg.PostProcessStep{
+				Format: "json",
+			},
+		}, {
+			name:  "command",
+			input: `command: ["clang-format", "--style=google"]`,
+			want: &cfg.PostProcessStep{
+				Command: []string{"clang-format", "--style=google"},
+			},
+		}, {
+			name:    "unknown format",
+			input:   `prettier`,
+			wantErr: rootcfg.ErrInvalidField,
+		}, {
+			name: "format and command",
+			input: lines(
+				`format: gofmt`,
+				`command: ["gofmt"]`,
+			),
+			wantErr: rootcfg.ErrInvalidField,
+		}, {
+			name:    "empty command",
+			input:   `command: [""]`,
+			wantErr: rootcfg.ErrInvalidField,
+		}, {
+			name:    "neither format nor command",
+			input:   `command: []`,
+			wantErr: rootcfg.ErrMissingField,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got cfg.PostProcessStep
+			err := yaml.Unmarshal([]byte(tc.input), &got)
+
+			if got, want := err, tc.wantErr; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+				t.Fatalf("PostProcessStep.UnmarshalYAML(...) = %v, want %v", got, want)
+			}
+			if want := zeroIfNil(tc.want); !cmp.Equal(&got, want) {
+				t.Errorf("PostProcessStep.UnmarshalYAML() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestProtectedRegions(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   string
+		want    *cfg.ProtectedRegions
+		wantErr error
+	}{
+		{
+			name: "valid markers",
+			input: lines(
+				`begin: "fhenix:begin"`,
+				`end: "fhenix:end"`,
+			),
+			want: &cfg.ProtectedRegions{
+				Begin: "fhenix:begin",
+				End:   "fhenix:end",
+			},
+		}, {
+			name: "missing end",
+			input: lines(
+				`begin: "fhenix:begin"`,
+			),
+			wantErr: rootcfg.ErrMissingField,
+		}, {
+			name: "begin contains end",
+			input: lines(
+				`begin: "protected-end-begin"`,
+				`end: "end"`,
+			),
+			wantErr: rootcfg.ErrInvalidField,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got cfg.ProtectedRegions
+			err := yaml.Unmarshal([]byte(tc.input), &got)
+
+			if got, want := err, tc.wantErr; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+				t.Fatalf("ProtectedRegions.UnmarshalYAML(...) = %v, want %v", got, want)
+			}
+			if want := zeroIfNil(tc.want); !cmp.Equal(&got, want) {
+				t.Errorf("ProtectedRegions.UnmarshalYAML() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
 func TestTransformFilter(t *testing.T) {
 	testCases := []struct {
 		name    string
@@ -203,6 +314,84 @@ func TestTransformFilter(t *testing.T) {
 				`condition: "{{ .Field | invalid-func }}"`,
 			),
 			wantErr: cmpopts.AnyError,
+		}, {
+			name: "valid filter with query",
+			input: lines(
+				`query: 'kind == "resource" && derivesFrom("DomainResource") && !abstract'`,
+			),
+			want: &cfg.TransformFilter{
+				Query: `kind == "resource" && derivesFrom("DomainResource") && !abstract`,
+			},
+		}, {
+			name: "query is not a bool",
+			input: lines(
+				`query: 'name + "s"'`,
+			),
+			wantErr: query.ErrQuery,
+		}, {
+			name: "query has unknown identifier",
+			input: lines(
+				`query: 'kynd == "resource"'`,
+			),
+			wantErr: query.ErrQuery,
+		}, {
+			name: "query has type error",
+			input: lines(
+				`query: 'abstract == "yes"'`,
+			),
+			wantErr: query.ErrQuery,
+		}, {
+			name: "valid filter with declarative keys",
+			input: lines(
+				"kind: resource",
+				"derivation: specialization",
+				"abstract: false",
+				"base: DomainResource",
+				"status: active",
+				"experimental: false",
+				"package-version: 4.0.1",
+				"publisher: ^HL7",
+			),
+			want: &cfg.TransformFilter{
+				Kind:           "resource",
+				Derivation:     "specialization",
+				Abstract:       ptr(false),
+				Base:           "DomainResource",
+				Status:         "active",
+				Experimental:   ptr(false),
+				PackageVersion: "4.0.1",
+				Publisher:      "^HL7",
+			},
+		}, {
+			name: "only abstract is set",
+			input: lines(
+				"abstract: true",
+			),
+			want: &cfg.TransformFilter{Abstract: ptr(true)},
+		}, {
+			name: "invalid kind",
+			input: lines(
+				"kind: backbone",
+			),
+			wantErr: rootcfg.ErrInvalidField,
+		}, {
+			name: "invalid derivation",
+			input: lines(
+				"derivation: profile",
+			),
+			wantErr: rootcfg.ErrInvalidField,
+		}, {
+			name: "invalid status",
+			input: lines(
+				"status: published",
+			),
+			wantErr: rootcfg.ErrInvalidField,
+		}, {
+			name: "invalid publisher regex",
+			input: lines(
+				"publisher: '[a-z'",
+			),
+			wantErr: cmpopts.AnyError,
 		},
 	}
 
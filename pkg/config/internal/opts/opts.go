@@ -5,7 +5,10 @@ configuration dispatching.
 package opts
 
 import (
+	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 )
 
@@ -31,6 +34,15 @@ type Options struct {
 	// RootDir is the root directory which all configuration paths will be
 	// considered relative to.
 	RootDir string
+
+	// FS is the file system of the template pack being read, if any. When set,
+	// paths are slash-separated paths within the file system, relative to the
+	// root directory.
+	FS fs.FS
+
+	// Vars are variable overrides, keyed by their dot-separated path, which
+	// take precedence over all variables defined in the configuration.
+	Vars map[string]string
 }
 
 func (o *Options) Apply(opts ...Option) {
@@ -42,8 +54,18 @@ func (o *Options) Apply(opts ...Option) {
 // RootPath returns the absolute path of the specified path relative to the
 // root directory. If the path is already absolute, it will be returned as is.
 // If the root directory is not set, the current working directory will be used.
-func (o *Options) RootPath(path string) (string, error) {
-	return o.path(o.RootDir, path)
+//
+// If a file system is set, the path is instead resolved within it, and must not
+// be absolute or refer outside of it.
+func (o *Options) RootPath(name string) (string, error) {
+	if o.FS != nil {
+		result := path.Join(o.RootDir, filepath.ToSlash(name))
+		if filepath.IsAbs(name) || path.IsAbs(name) || !fs.ValidPath(result) {
+			return "", fmt.Errorf("path '%v' is not within the template pack", name)
+		}
+		return result, nil
+	}
+	return o.path(o.RootDir, name)
 }
 
 // OutputPath returns the absolute path of the specified path relative to the
@@ -0,0 +1,111 @@
+/*
+Package pack opens the archives that template packs are distributed in as file
+systems.
+*/
+package pack
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// ManifestFile is the name of the manifest at the root of every pack.
+const ManifestFile = "fhenix-pack.yaml"
+
+// ErrNoManifest is returned when a pack does not contain a manifest.
+var ErrNoManifest = errors.New("template pack has no '" + ManifestFile + "'")
+
+// OpenTarball reads the gzipped tarball at the given path into memory, and
+// returns the root of the pack within it.
+func OpenTarball(name string) (fs.FS, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	// Packs are small, and the tarball must be read in full to be accessed
+	// randomly, so it is repacked into an in-memory zip archive, which is
+	// already a file system.
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := path.Clean(header.Name)
+		if header.Typeflag != tar.TypeReg || !fs.ValidPath(name) {
+			continue
+		}
+		entry := &zip.FileHeader{Name: name, Method: zip.Store, Modified: header.ModTime}
+		entry.SetMode(fs.FileMode(header.Mode).Perm())
+		w, err := writer.CreateHeader(entry)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(w, reader); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return openZip(buf.Bytes())
+}
+
+// OpenZip reads the zip archive at the given path into memory, and returns
+// the root of the pack within it.
+func OpenZip(name string) (fs.FS, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return openZip(data)
+}
+
+func openZip(data []byte) (fs.FS, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	return Root(reader)
+}
+
+// Root returns the root of the pack within the file system, which is either
+// the file system itself or its single top-level directory, as is common for
+// archives.
+func Root(fsys fs.FS) (fs.FS, error) {
+	if _, err := fs.Stat(fsys, ManifestFile); err == nil {
+		return fsys, nil
+	}
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		sub, err := fs.Sub(fsys, entries[0].Name())
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fs.Stat(sub, ManifestFile); err == nil {
+			return sub, nil
+		}
+	}
+	return nil, ErrNoManifest
+}
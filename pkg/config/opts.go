@@ -18,6 +18,19 @@ func WithOutputDir(dir string) Option {
 	})
 }
 
+// WithVars sets variable overrides, keyed by their dot-separated path, which
+// take precedence over all variables defined in the configuration.
+func WithVars(vars map[string]string) Option {
+	return opts.OptionFunc(func(cfg *opts.Options) {
+		if cfg.Vars == nil {
+			cfg.Vars = map[string]string{}
+		}
+		for key, value := range vars {
+			cfg.Vars[key] = value
+		}
+	})
+}
+
 // Option is an interface for composable optins that can be provided to
 // configuration objects that can be read.
 type Option = opts.Option
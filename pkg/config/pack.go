@@ -0,0 +1,87 @@
+package config
+
+import (
+	"io/fs"
+
+	"github.com/friendly-fhir/fhenix/pkg/config/internal/opts"
+	"github.com/friendly-fhir/fhenix/pkg/config/internal/pack"
+)
+
+// Pack is a template pack: a reusable set of presets and transforms, along
+// with the templates and funcs that they reference.
+type Pack struct {
+	// Name is the name of the pack.
+	Name string
+
+	// Description is a human-readable description of the pack.
+	Description string
+
+	// Vars are the user-defined variables of the pack, including any overrides.
+	Vars map[string]any
+
+	// Presets are the presets of the pack by their plain name, each merged with
+	// the presets that it extends.
+	Presets map[string]*Transform
+
+	// Transforms are the transforms of the pack, each merged with the presets
+	// that it extends.
+	Transforms []*Transform
+}
+
+// LoadPack loads the template pack from the root of the file system, or from
+// its single top-level directory, such as a pack embedded into a Go program.
+// The templates and funcs of the pack are read from the file system.
+func LoadPack(fsys fs.FS, options ...Option) (*Pack, error) {
+	var opts opts.Options
+	opts.Apply(options...)
+
+	root, err := pack.Root(fsys)
+	if err != nil {
+		return nil, err
+	}
+	opts.RootDir = "."
+	opts.FS = root
+
+	manifest, err := readPackManifest(root)
+	if err != nil {
+		return nil, err
+	}
+	composed := newV1Composition()
+	if err := composed.addPack(&opts, manifest); err != nil {
+		return nil, err
+	}
+
+	result := &Pack{
+		Name:        manifest.Name,
+		Description: manifest.Description,
+		Vars:        overrideVars(composed.vars, opts.Vars),
+		Presets:     make(map[string]*Transform, len(manifest.Presets)),
+	}
+	for _, source := range composed.transforms {
+		transform, err := composed.transform(source)
+		if err != nil {
+			return nil, err
+		}
+		result.Transforms = append(result.Transforms, transform.transform)
+	}
+	for name := range manifest.Presets {
+		preset, err := composed.preset(manifest.Name + "." + name)
+		if err != nil {
+			return nil, err
+		}
+		result.Presets[name] = preset.transform
+	}
+
+	// Variables are only merged once every preset has been resolved, since the
+	// resolved presets are merged into the transforms that extend them.
+	vars := func(transform *Transform) {
+		transform.Vars = overrideVars(mergeVars(composed.vars, transform.Vars), opts.Vars)
+	}
+	for _, transform := range result.Transforms {
+		vars(transform)
+	}
+	for _, preset := range result.Presets {
+		vars(preset)
+	}
+	return result, nil
+}
@@ -0,0 +1,333 @@
+package config_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/friendly-fhir/fhenix/pkg/config"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// packArchives writes the directory as a gzipped tarball and a zip archive
+// into a temporary directory, each with a single top-level directory, and
+// returns their paths.
+func packArchives(t *testing.T, dir string) (tarball, zipfile string) {
+	t.Helper()
+	fsys := fstest.MapFS{}
+	err := fs.WalkDir(os.DirFS(dir), ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(filepath.Join(dir, path))
+		fsys["pack/"+path] = &fstest.MapFile{Data: data, Mode: 0644}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WalkDir() = %v", err)
+	}
+
+	var tgz bytes.Buffer
+	gz := gzip.NewWriter(&tgz)
+	tw := tar.NewWriter(gz)
+	if err := tw.AddFS(fsys); err != nil {
+		t.Fatalf("tar.Writer.AddFS() = %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar.Writer.Close() = %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("gzip.Writer.Close() = %v", err)
+	}
+
+	var z bytes.Buffer
+	zw := zip.NewWriter(&z)
+	if err := zw.AddFS(fsys); err != nil {
+		t.Fatalf("zip.Writer.AddFS() = %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip.Writer.Close() = %v", err)
+	}
+
+	tmp := t.TempDir()
+	tarball = filepath.Join(tmp, "pack.tar.gz")
+	zipfile = filepath.Join(tmp, "pack.zip")
+	if err := os.WriteFile(tarball, tgz.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(zipfile, z.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return tarball, zipfile
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fhenix.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFromFile_Packs(t *testing.T) {
+	dir := filepath.Join(thisdir(t), "testdata", "packs", "gopack")
+	tarball, zipfile := packArchives(t, dir)
+	vars := map[string]any{"language": "go"}
+
+	testCases := []struct {
+		name         string
+		pack         string
+		wantIncludes []string
+		wantErr      error
+	}{
+		{
+			name: "directory",
+			pack: dir,
+			wantIncludes: []string{
+				filepath.Join(dir, "fhenix-pack.yaml"),
+				filepath.Join(dir, "funcs", "receiver.tmpl"),
+				filepath.Join(dir, "templates", "header.tmpl"),
+			},
+		}, {
+			name:         "gzipped tarball",
+			pack:         tarball,
+			wantIncludes: []string{tarball},
+		}, {
+			name:         "zip archive",
+			pack:         zipfile,
+			wantIncludes: []string{zipfile},
+		}, {
+			name:    "unknown format",
+			pack:    filepath.Join(dir, "fhenix-pack.yaml"),
+			wantErr: config.ErrUnknownPackFormat,
+		}, {
+			name:    "missing manifest",
+			pack:    filepath.Join(dir, "templates"),
+			wantErr: config.ErrNoPackManifest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			input := writeConfig(t, strings.Join([]string{
+				`version: 1`,
+				`input:`,
+				`  packages:`,
+				`    - name: hl7.fhir.r4.core`,
+				`      version: 4.0.1`,
+				`packs:`,
+				`  - ` + tc.pack,
+				`transforms:`,
+				`  - name: types`,
+				`    extends: go.types`,
+			}, "\n"))
+			cfg, err := config.FromFile(input)
+			if got, want := err, tc.wantErr; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+				t.Fatalf("FromFile() = %v, want %v", got, want)
+			}
+			if err != nil {
+				return
+			}
+
+			want := []*config.Transform{
+				{
+					Name:      "codes",
+					Include:   []*config.TransformFilter{{Type: "CodeSystem"}},
+					Templates: map[string]string{"header": "templates/header.tmpl"},
+					Vars:      vars,
+				}, {
+					Name:       "types",
+					OutputPath: "{{ .Name }}.go",
+					Funcs:      map[string]string{"receiver": "funcs/receiver.tmpl"},
+					Templates:  map[string]string{"header": "templates/header.tmpl"},
+					Vars:       vars,
+				},
+			}
+			opts := []cmp.Option{cmpopts.EquateEmpty(), cmpopts.IgnoreFields(config.Transform{}, "FS")}
+			if got := cfg.Transforms; !cmp.Equal(got, want, opts...) {
+				t.Errorf("FromFile() transforms (-got +want)\n%s", cmp.Diff(got, want, opts...))
+			}
+			if got, want := cfg.Includes, tc.wantIncludes; !cmp.Equal(got, want) {
+				t.Errorf("FromFile() includes = %v, want %v", got, want)
+			}
+			for _, transform := range cfg.Transforms {
+				if transform.FS == nil {
+					t.Fatalf("FromFile() transform %q has no FS", transform.Name)
+				}
+				if _, err := fs.ReadFile(transform.FS, transform.Templates["header"]); err != nil {
+					t.Errorf("ReadFile() = %v, want nil", err)
+				}
+			}
+		})
+	}
+}
+
+func TestFromFile_DirectoryPackOutside(t *testing.T) {
+	testCases := []struct {
+		name      string
+		transform string
+	}{
+		{
+			name: "template outside of the pack",
+			transform: `
+  - templates:
+      header: ../outside.tmpl
+`,
+		}, {
+			name: "func outside of the pack",
+			transform: `
+  - funcs:
+      receiver: ../../funcs/receiver.tmpl
+`,
+		}, {
+			name: "post-process command in the pack",
+			transform: `
+  - post-process:
+      - command: [./format.sh]
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			pack := filepath.Join(dir, "pack")
+			if err := os.Mkdir(pack, 0755); err != nil {
+				t.Fatal(err)
+			}
+			manifest := "name: outside\ntransforms:" + tc.transform
+			if err := os.WriteFile(filepath.Join(pack, "fhenix-pack.yaml"), []byte(manifest), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "outside.tmpl"), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+			input := writeConfig(t, `version: 1
+input:
+  packages:
+    - name: hl7.fhir.r4.core
+      version: 4.0.1
+packs:
+  - `+pack+`
+`)
+
+			if _, err := config.FromFile(input); err == nil {
+				t.Errorf("FromFile() = nil, want error")
+			}
+		})
+	}
+}
+
+func TestFromFile_PackConflict(t *testing.T) {
+	first, _ := packArchives(t, filepath.Join(thisdir(t), "testdata", "packs", "gopack"))
+	other := t.TempDir()
+	if err := os.WriteFile(filepath.Join(other, "fhenix-pack.yaml"), []byte(strings.Join([]string{
+		`name: other`,
+		`presets:`,
+		`  base:`,
+		`    templates:`,
+		`      footer: footer.tmpl`,
+	}, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	second, _ := packArchives(t, other)
+
+	input := writeConfig(t, strings.Join([]string{
+		`version: 1`,
+		`input:`,
+		`  packages:`,
+		`    - name: hl7.fhir.r4.core`,
+		`      version: 4.0.1`,
+		`packs:`,
+		`  - ` + first,
+		`  - ` + second,
+		`transforms:`,
+		`  - extends: [go.types, other.base]`,
+	}, "\n"))
+
+	_, err := config.FromFile(input)
+	if got, want := err, config.ErrPackConflict; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+		t.Errorf("FromFile() = %v, want %v", got, want)
+	}
+}
+
+func TestLoadPack(t *testing.T) {
+	testCases := []struct {
+		name    string
+		fsys    fs.FS
+		want    *config.Pack
+		wantErr error
+	}{
+		{
+			name: "pack in top-level directory",
+			fsys: fstest.MapFS{
+				"gopack/fhenix-pack.yaml": {Data: []byte(strings.Join([]string{
+					`name: go`,
+					`description: Go types.`,
+					`vars:`,
+					`  language: go`,
+					`presets:`,
+					`  types:`,
+					`    output-path: "{{ .Name }}.go"`,
+					`    templates:`,
+					`      type: templates/type.tmpl`,
+					`transforms:`,
+					`  - extends: types`,
+					`    include:`,
+					`      - type: StructureDefinition`,
+				}, "\n"))},
+			},
+			want: &config.Pack{
+				Name:        "go",
+				Description: "Go types.",
+				Vars:        map[string]any{"language": "go"},
+				Presets: map[string]*config.Transform{
+					"types": {
+						OutputPath: "{{ .Name }}.go",
+						Templates:  map[string]string{"type": "templates/type.tmpl"},
+						Vars:       map[string]any{"language": "go"},
+					},
+				},
+				Transforms: []*config.Transform{
+					{
+						Include:    []*config.TransformFilter{{Type: "StructureDefinition"}},
+						OutputPath: "{{ .Name }}.go",
+						Templates:  map[string]string{"type": "templates/type.tmpl"},
+						Vars:       map[string]any{"language": "go"},
+					},
+				},
+			},
+		}, {
+			name:    "path outside of the pack",
+			fsys:    os.DirFS(filepath.Join(thisdir(t), "testdata", "packs", "escape")),
+			wantErr: cmpopts.AnyError,
+		}, {
+			name:    "missing manifest",
+			fsys:    fstest.MapFS{"a.tmpl": {}, "b.tmpl": {}},
+			wantErr: config.ErrNoPackManifest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := config.LoadPack(tc.fsys)
+			if got, want := err, tc.wantErr; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+				t.Fatalf("LoadPack() = %v, want %v", got, want)
+			}
+
+			opts := []cmp.Option{cmpopts.EquateEmpty(), cmpopts.IgnoreFields(config.Transform{}, "FS")}
+			if want := tc.want; !cmp.Equal(got, want, opts...) {
+				t.Errorf("LoadPack() (-got +want)\n%s", cmp.Diff(got, want, opts...))
+			}
+		})
+	}
+}
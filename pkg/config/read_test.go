@@ -80,3 +80,181 @@ func TestFromFile(t *testing.T) {
 		})
 	}
 }
+
+func TestFromFile_Vars(t *testing.T) {
+	t.Setenv("FHENIX_TEST_PACKAGE", "r4")
+	goVars := map[string]any{"package": "r4", "module": "example.com/fhir"}
+
+	testCases := []struct {
+		name    string
+		input   string
+		opts    []config.Option
+		want    []map[string]any
+		wantErr error
+	}{
+		{
+			name:  "vars are merged and interpolated",
+			input: "testdata/vars.yaml",
+			want: []map[string]any{
+				{"namespace": "default", "go": goVars, "kind": "types"},
+				{"namespace": "codes", "go": goVars},
+			},
+		}, {
+			name:  "overrides take precedence",
+			input: "testdata/vars.yaml",
+			opts: []config.Option{
+				config.WithVars(map[string]string{"namespace": "set", "go.package": "set"}),
+			},
+			want: []map[string]any{
+				{"namespace": "set", "go": map[string]any{"package": "set", "module": "example.com/fhir"}, "kind": "types"},
+				{"namespace": "set", "go": map[string]any{"package": "set", "module": "example.com/fhir"}},
+			},
+		}, {
+			name:    "undefined environment variable",
+			input:   "testdata/vars-undefined-env.yaml",
+			wantErr: config.ErrUndefinedEnv,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := config.FromFile(tc.input, tc.opts...)
+			if got, want := err, tc.wantErr; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+				t.Fatalf("FromFile(%q) = %v, want %v", tc.input, got, want)
+			}
+			if err != nil {
+				return
+			}
+
+			var got []map[string]any
+			for _, transform := range cfg.Transforms {
+				got = append(got, transform.Vars)
+			}
+			if want := tc.want; !cmp.Equal(got, want) {
+				t.Errorf("FromFile(%q) vars (-got +want)\n%s", tc.input, cmp.Diff(got, want))
+			}
+		})
+	}
+}
+
+func TestFromFile_Include(t *testing.T) {
+	dir := filepath.Join(thisdir(t), "testdata", "include")
+	vars := map[string]any{"namespace": "fhir", "language": "go", "license": "MIT"}
+	postProcess := []*config.PostProcess{{Format: "gofmt"}}
+	funcs := map[string]string{
+		"receiver": filepath.Join(dir, "shared", "funcs", "receiver.tmpl"),
+	}
+	templates := map[string]string{
+		"type":   filepath.Join(dir, "shared", "templates", "type.tmpl"),
+		"header": filepath.Join(dir, "common", "header.tmpl"),
+	}
+
+	testCases := []struct {
+		name         string
+		input        string
+		want         []*config.Transform
+		wantIncludes []string
+		wantErr      error
+	}{
+		{
+			name:  "includes and presets are composed",
+			input: "testdata/include/fhenix.yaml",
+			want: []*config.Transform{
+				{
+					Name:        "codes",
+					Mode:        config.ModeIndent,
+					Include:     []*config.TransformFilter{{Type: "CodeSystem"}},
+					OutputPath:  "{{ .Name }}.go",
+					Funcs:       funcs,
+					Templates:   templates,
+					Vars:        vars,
+					PostProcess: postProcess,
+				}, {
+					Name:        "types",
+					Mode:        config.ModeIndent,
+					Include:     []*config.TransformFilter{{Type: "StructureDefinition"}},
+					OutputPath:  "{{ .Name }}.go",
+					Funcs:       funcs,
+					Templates:   templates,
+					Vars:        vars,
+					PostProcess: postProcess,
+				}, {
+					Name:       "local",
+					Mode:       config.ModeText,
+					Strict:     true,
+					OutputPath: "local/{{ .Name }}.go",
+					Funcs:      funcs,
+					Templates: map[string]string{
+						"type":   filepath.Join(dir, "templates", "type.tmpl"),
+						"header": filepath.Join(dir, "common", "header.tmpl"),
+					},
+					Vars:        vars,
+					PostProcess: postProcess,
+				},
+			},
+			wantIncludes: []string{
+				filepath.Join(dir, "common", "base.yaml"),
+				filepath.Join(dir, "shared", "presets.yaml"),
+			},
+		}, {
+			name:    "include cycle",
+			input:   "testdata/include/cycle.yaml",
+			wantErr: config.ErrIncludeCycle,
+		}, {
+			name:    "unknown preset",
+			input:   "testdata/include/unknown-preset.yaml",
+			wantErr: config.ErrUnknownPreset,
+		}, {
+			name:    "preset cycle",
+			input:   "testdata/include/preset-cycle.yaml",
+			wantErr: config.ErrPresetCycle,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := config.FromFile(tc.input)
+			if got, want := err, tc.wantErr; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+				t.Fatalf("FromFile(%q) = %v, want %v", tc.input, got, want)
+			}
+			if err != nil {
+				return
+			}
+
+			if got, want := cfg.Transforms, tc.want; !cmp.Equal(got, want, cmpopts.EquateEmpty()) {
+				t.Errorf("FromFile(%q) transforms (-got +want)\n%s", tc.input, cmp.Diff(got, want, cmpopts.EquateEmpty()))
+			}
+			if got, want := cfg.Includes, tc.wantIncludes; !cmp.Equal(got, want) {
+				t.Errorf("FromFile(%q) includes = %v, want %v", tc.input, got, want)
+			}
+		})
+	}
+}
+
+func TestFromFile_Strict(t *testing.T) {
+	input := writeConfig(t, `version: 1
+strict: true
+input:
+  packages:
+    - name: hl7.fhir.r4.core
+      version: 4.0.1
+transforms:
+  - name: inherited
+  - name: explicit
+    strict: true
+`)
+
+	cfg, err := config.FromFile(input)
+	if err != nil {
+		t.Fatalf("FromFile() = %v", err)
+	}
+
+	if got, want := cfg.Strict, true; got != want {
+		t.Errorf("FromFile().Strict = %v, want %v", got, want)
+	}
+	for _, transform := range cfg.Transforms {
+		if got, want := transform.Strict, true; got != want {
+			t.Errorf("FromFile().Transforms[%q].Strict = %v, want %v", transform.Name, got, want)
+		}
+	}
+}
@@ -2,6 +2,9 @@ package config
 
 import (
 	"bytes"
+	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/friendly-fhir/fhenix/pkg/config/internal/cfg/v1"
 	"github.com/friendly-fhir/fhenix/pkg/config/internal/opts"
@@ -23,6 +26,7 @@ func fromV1(data []byte, opts *opts.Options) (*Config, error) {
 	} else {
 		result.Mode = ModeText
 	}
+	result.Strict = cfg.Strict
 
 	var err error
 	if opts.OutputDir != "" {
@@ -36,10 +40,19 @@ func fromV1(data []byte, opts *opts.Options) (*Config, error) {
 		return nil, err
 	}
 
-	base, err := fromV1Transform(opts, &cfg.Default)
+	composed, err := composeV1(opts, &cfg)
 	if err != nil {
 		return nil, err
 	}
+	result.Includes = composed.files
+	result.Vars = overrideVars(composed.vars, opts.Vars)
+
+	base, err := composed.transform(&v1Source{opts: opts, transform: &cfg.Default})
+	if err != nil {
+		return nil, err
+	}
+	base.transform.Vars = mergeVars(composed.vars, base.transform.Vars)
+	base.transform.Strict = base.transform.Strict || cfg.Strict
 
 	for _, pkg := range cfg.Input.Packages {
 		result.Input = append(result.Input, &Package{
@@ -49,12 +62,17 @@ func fromV1(data []byte, opts *opts.Options) (*Config, error) {
 		})
 	}
 
-	result.Transforms = make([]*Transform, len(cfg.Transforms))
-	for i := range result.Transforms {
-		result.Transforms[i], err = mergeV1Transforms(opts, base, cfg.Transforms[i])
+	result.Transforms = make([]*Transform, len(composed.transforms))
+	for i, source := range composed.transforms {
+		transform, err := composed.transform(source)
 		if err != nil {
 			return nil, err
 		}
+		if err := transform.merge(base); err != nil {
+			return nil, err
+		}
+		result.Transforms[i] = transform.transform
+		result.Transforms[i].Vars = overrideVars(result.Transforms[i].Vars, opts.Vars)
 	}
 
 	return &result, nil
@@ -105,19 +123,64 @@ func fromV1Transform(opts *opts.Options, transform *cfg.Transform) (*Transform,
 
 	result.Include = fromV1Filters(transform.Include)
 	result.Exclude = fromV1Filters(transform.Exclude)
+	result.Name = transform.Name
+	result.Mode = Mode(transform.Mode)
+	result.Strict = transform.Strict
+	result.Vars, err = interpolateVars(transform.Vars)
+	if err != nil {
+		return nil, fmt.Errorf("transform vars: %w", err)
+	}
 	result.OutputPath = transform.OutputPath
+	for _, step := range transform.PostProcess {
+		command := slices.Clone(step.Command)
+		// Commands given as paths are relative to the root, like all other paths;
+		// bare command names are looked up in the PATH. Files within a template
+		// pack cannot be executed.
+		if len(command) > 0 && strings.ContainsRune(command[0], '/') {
+			if opts.FS != nil {
+				return nil, fmt.Errorf("post-process command '%v' cannot be run from a template pack", command[0])
+			}
+			command[0], err = opts.RootPath(command[0])
+			if err != nil {
+				return nil, err
+			}
+		}
+		result.PostProcess = append(result.PostProcess, &PostProcess{
+			Format:  step.Format,
+			Command: command,
+		})
+	}
+	if regions := transform.ProtectedRegions; regions != nil {
+		result.ProtectedRegions = &ProtectedRegions{
+			Begin: regions.Begin,
+			End:   regions.End,
+		}
+	}
+	result.FS = opts.FS
 
 	return &result, nil
 }
 
-func mergeV1Transforms(opts *opts.Options, base *Transform, transformv1 *cfg.Transform) (*Transform, error) {
-	result, err := fromV1Transform(opts, transformv1)
-	if err != nil {
-		return nil, err
+// mergeTransforms merges the base into the result, where the result takes
+// precedence over the base, and returns the result.
+func mergeTransforms(base, result *Transform) *Transform {
+	if result.Mode == "" {
+		result.Mode = base.Mode
 	}
+	result.Strict = result.Strict || base.Strict
 	if result.OutputPath == "" {
 		result.OutputPath = base.OutputPath
 	}
+	if result.PostProcess == nil {
+		result.PostProcess = base.PostProcess
+	}
+	if result.ProtectedRegions == nil {
+		result.ProtectedRegions = base.ProtectedRegions
+	}
+	if result.FS == nil {
+		result.FS = base.FS
+	}
+	result.Vars = mergeVars(base.Vars, result.Vars)
 
 	for name, path := range base.Funcs {
 		if _, ok := result.Funcs[name]; !ok {
@@ -132,7 +195,7 @@ func mergeV1Transforms(opts *opts.Options, base *Transform, transformv1 *cfg.Tra
 
 	result.Include = append(result.Include, base.Include...)
 	result.Exclude = append(result.Exclude, base.Exclude...)
-	return result, nil
+	return result
 }
 
 func fromV1Filters(filters []*cfg.TransformFilter) []*TransformFilter {
@@ -145,11 +208,20 @@ func fromV1Filters(filters []*cfg.TransformFilter) []*TransformFilter {
 
 func fromV1Filter(filter *cfg.TransformFilter) *TransformFilter {
 	return &TransformFilter{
-		Name:      filter.Name,
-		Type:      filter.Type,
-		URL:       filter.URL,
-		Package:   filter.Package,
-		Source:    filter.Source,
-		Condition: filter.Condition,
+		Name:           filter.Name,
+		Type:           filter.Type,
+		URL:            filter.URL,
+		Package:        filter.Package,
+		Source:         filter.Source,
+		Condition:      filter.Condition,
+		Query:          filter.Query,
+		Kind:           filter.Kind,
+		Derivation:     filter.Derivation,
+		Abstract:       filter.Abstract,
+		Base:           filter.Base,
+		Status:         filter.Status,
+		Experimental:   filter.Experimental,
+		PackageVersion: filter.PackageVersion,
+		Publisher:      filter.Publisher,
 	}
 }
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// envRegex matches '${NAME}' and '${NAME:-default}' environment references.
+var envRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// interpolateVars returns a copy of the vars, with every environment reference
+// in string values replaced by the value of the environment variable. A
+// reference to an unset variable without a default is an error.
+func interpolateVars(vars map[string]any) (map[string]any, error) {
+	if vars == nil {
+		return nil, nil
+	}
+	result, err := interpolate(vars)
+	if err != nil {
+		return nil, err
+	}
+	return result.(map[string]any), nil
+}
+
+func interpolate(v any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		return interpolateString(v)
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, value := range v {
+			var err error
+			if result[key], err = interpolate(value); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	case []any:
+		result := make([]any, len(v))
+		for i, value := range v {
+			var err error
+			if result[i], err = interpolate(value); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+	return v, nil
+}
+
+func interpolateString(s string) (string, error) {
+	var err error
+	result := envRegex.ReplaceAllStringFunc(s, func(match string) string {
+		parts := envRegex.FindStringSubmatch(match)
+		if value, ok := os.LookupEnv(parts[1]); ok {
+			return value
+		}
+		if strings.Contains(match, ":-") {
+			return parts[2]
+		}
+		if err == nil {
+			err = fmt.Errorf("%w: '%v'", ErrUndefinedEnv, parts[1])
+		}
+		return match
+	})
+	return result, err
+}
+
+// mergeVars returns the vars of the base, overridden by the vars of the
+// override. Like funcs and templates, variables are merged by their top-level
+// name only.
+func mergeVars(base, override map[string]any) map[string]any {
+	if base == nil && override == nil {
+		return nil
+	}
+	result := maps.Clone(base)
+	if result == nil {
+		result = map[string]any{}
+	}
+	maps.Copy(result, override)
+	return result
+}
+
+// overrideVars returns a copy of the vars with each override set, where the
+// key of an override is the dot-separated path of a possibly nested variable.
+func overrideVars(vars map[string]any, overrides map[string]string) map[string]any {
+	if len(overrides) == 0 {
+		return vars
+	}
+	result := maps.Clone(vars)
+	if result == nil {
+		result = map[string]any{}
+	}
+	for key, value := range overrides {
+		setVar(result, strings.Split(key, "."), value)
+	}
+	return result
+}
+
+func setVar(vars map[string]any, path []string, value string) {
+	if len(path) == 1 {
+		vars[path[0]] = value
+		return
+	}
+	// Nested maps are cloned, so that maps shared with other transforms are not
+	// modified.
+	nested, _ := vars[path[0]].(map[string]any)
+	nested = maps.Clone(nested)
+	if nested == nil {
+		nested = map[string]any{}
+	}
+	setVar(nested, path[1:], value)
+	vars[path[0]] = nested
+}
@@ -2,18 +2,38 @@
 Package driver is the primary driver behind the application.
 It is responsible for the main loop of the application which does the actual
 code generation.
+
+The driver may also be embedded into other Go programs without a config file,
+by constructing it with options alone:
+
+	d, err := driver.New(nil,
+		driver.ExplicitPackages(registry.NewPackageRef("default", "hl7.fhir.r4.core", "4.0.1")),
+		driver.Transforms(&config.Transform{...}),
+		driver.TemplateFS(templates),
+		driver.TemplateFuncs(transform.Funcs{"upper": strings.ToUpper}),
+		driver.Sink(sink),
+	)
 */
 package driver
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/friendly-fhir/fhenix/internal/task"
 	"github.com/friendly-fhir/fhenix/internal/templatefuncs"
 	"github.com/friendly-fhir/fhenix/pkg/config"
 	"github.com/friendly-fhir/fhenix/pkg/driver/job"
+	"github.com/friendly-fhir/fhenix/pkg/driver/manifest"
 	"github.com/friendly-fhir/fhenix/pkg/model"
 	"github.com/friendly-fhir/fhenix/pkg/model/conformance"
 	"github.com/friendly-fhir/fhenix/pkg/model/loader"
@@ -55,6 +75,8 @@ type Listener interface {
 	BeforeTransform(n int, jobs int)
 	OnTransformOutput(n int, output string)
 	AfterTransformOutput(n int, output string, err error)
+	AfterTransform(n int, stats *TransformStats)
+	OnPrune(output string, err error)
 
 	loader.Listener
 	registry.CacheListener
@@ -74,25 +96,122 @@ func (BaseListener) AfterLoadTransform(n int, err error) {}
 func (BaseListener) BeforeTransform(i, jobs int)                          {}
 func (BaseListener) OnTransformOutput(i int, output string)               {}
 func (BaseListener) AfterTransformOutput(i int, output string, err error) {}
+func (BaseListener) AfterTransform(i int, stats *TransformStats)          {}
+func (BaseListener) OnPrune(output string, err error)                     {}
 
 var _ Listener = (*BaseListener)(nil)
 
+// ErrModified is reported to [Listener.OnPrune] when a stale output was not
+// removed, because it was modified after it was generated.
+var ErrModified = errors.New("modified since it was generated; not removed")
+
+// TransformStats are the counts of outcomes of the jobs of a single transform.
+type TransformStats struct {
+	// Written is the number of outputs that were written, because their content
+	// changed.
+	Written int
+
+	// Unchanged is the number of outputs that were rendered, but not written
+	// because their content was identical to the existing file.
+	Unchanged int
+
+	// Skipped is the number of outputs that were not rendered, because none of
+	// their inputs changed since they were last generated.
+	Skipped int
+
+	// Failed is the number of outputs that failed to be generated.
+	Failed int
+}
+
 type Driver struct {
 	outputPath string
 
 	module *conformance.Module
 	cache  *registry.Cache
 
-	mode config.Mode
+	mode   config.Mode
+	strict bool
 
 	transformConfigs []*config.Transform
 
 	forceDownload    bool
+	incremental      bool
+	prune            bool
 	parallel         int
 	explicitPackages []registry.PackageRef
 
 	listeners []Listener
 	reporter  templatefuncs.Reporter
+	funcs     transform.Funcs
+	fsys      fs.FS
+	sink      OutputSink
+}
+
+// OutputDir returns an [Option] for the [Driver] that will set the directory
+// that outputs are written to, and that relative output paths are relative to.
+func OutputDir(dir string) Option {
+	return option(func(d *Driver) {
+		d.outputPath = dir
+	})
+}
+
+// Mode returns an [Option] for the [Driver] that will set the template mode of
+// the transforms.
+func Mode(mode config.Mode) Option {
+	return option(func(d *Driver) {
+		d.mode = mode
+	})
+}
+
+// Strict returns an [Option] for the [Driver] that will make every transform
+// strict, even if it is not strict in the config. Errors reported by templates
+// then fail the output being rendered, rather than being reported.
+func Strict(strict bool) Option {
+	return option(func(d *Driver) {
+		d.strict = strict
+	})
+}
+
+// Transforms returns an [Option] for the [Driver] that will add transforms to
+// apply to the model.
+func Transforms(transforms ...*config.Transform) Option {
+	return option(func(d *Driver) {
+		d.transformConfigs = append(d.transformConfigs, transforms...)
+	})
+}
+
+// TemplateFS returns an [Option] for the [Driver] that will set the file
+// system that relative template and func paths are read from, for every
+// transform that does not have a file system of its own. This enables the
+// templates to be embedded into a Go program.
+func TemplateFS(fsys fs.FS) Option {
+	return option(func(d *Driver) {
+		d.fsys = fsys
+	})
+}
+
+// TemplateFuncs returns an [Option] for the [Driver] that will add Go
+// functions that are callable from every template, including func templates
+// and scripts. These take precedence over the builtin functions of the same
+// name.
+func TemplateFuncs(funcs transform.Funcs) Option {
+	return option(func(d *Driver) {
+		if d.funcs == nil {
+			d.funcs = transform.Funcs{}
+		}
+		for name, fn := range funcs {
+			d.funcs[name] = fn
+		}
+	})
+}
+
+// Sink returns an [Option] for the [Driver] that will write every output to
+// the sink instead of the output directory. With a sink, every output is
+// rendered on every run, and no generation manifest is kept.
+func Sink(sink OutputSink) Option {
+	return option(func(d *Driver) {
+		d.sink = sink
+	})
 }
 
 // Cache returns an [Option] for the [Driver] that will set the cache to use
@@ -135,6 +254,25 @@ func ForceDownload(force bool) Option {
 	})
 }
 
+// Incremental returns an [Option] for the [Driver] that will set whether to
+// skip transform outputs whose inputs have not changed since the previous run,
+// as recorded in the generation manifest of the output directory.
+func Incremental(incremental bool) Option {
+	return option(func(d *Driver) {
+		d.incremental = incremental
+	})
+}
+
+// Prune returns an [Option] for the [Driver] that will set whether to delete
+// outputs that were generated by the previous run, but that are no longer
+// produced by any transform. Files that are not recorded in the generation
+// manifest are never deleted.
+func Prune(prune bool) Option {
+	return option(func(d *Driver) {
+		d.prune = prune
+	})
+}
+
 // Listeners returns an [Option] for the [Driver] that will set the
 // listeners to notify when a package is downloaded or loaded.
 func Listeners(listeners ...Listener) Option {
@@ -159,21 +297,29 @@ func TemplateReportFunc(report func(error)) Option {
 	})
 }
 
-func New(config *config.Config, opts ...Option) (*Driver, error) {
+// New creates a new driver from the given config, which may be nil to
+// configure the driver entirely from its options. Options are applied after
+// the config, and so may extend it.
+func New(cfg *config.Config, opts ...Option) (*Driver, error) {
 	driver := &Driver{
-		outputPath: config.OutputDir,
-		parallel:   runtime.NumCPU(),
+		parallel: runtime.NumCPU(),
 
-		mode: config.Mode,
+		mode: config.ModeText,
 
 		module: conformance.DefaultModule(),
 		cache:  registry.DefaultCache(),
 
-		forceDownload:    false,
-		transformConfigs: config.Transforms,
+		forceDownload: false,
+		incremental:   true,
+		prune:         true,
 	}
-	for _, pkg := range config.Input {
-		driver.explicitPackages = append(driver.explicitPackages, registry.NewPackageRef("default", pkg.Name, pkg.Version))
+	if cfg != nil {
+		driver.outputPath = cfg.OutputDir
+		driver.mode = cfg.Mode
+		driver.transformConfigs = cfg.Transforms
+		for _, pkg := range cfg.Input {
+			driver.explicitPackages = append(driver.explicitPackages, registry.NewPackageRef("default", pkg.Name, pkg.Version))
+		}
 	}
 	for _, opt := range opts {
 		opt.set(driver)
@@ -182,6 +328,16 @@ func New(config *config.Config, opts ...Option) (*Driver, error) {
 	return driver, nil
 }
 
+// Reconfigure updates the transform configuration of the driver from the given
+// config, so that subsequent calls to [Driver.LoadTransforms] and
+// [Driver.Transform] reflect it. Input packages are not reloaded, since the
+// loaded model is expected to be reused between calls.
+func (d *Driver) Reconfigure(config *config.Config) {
+	d.outputPath = config.OutputDir
+	d.mode = config.Mode
+	d.transformConfigs = config.Transforms
+}
+
 func (d *Driver) DownloadPackages(ctx context.Context) error {
 	for _, listener := range d.listeners {
 		listener.BeforeStage(StageDownload)
@@ -215,7 +371,15 @@ func (d *Driver) LoadTransforms() ([]*transform.Transform, error) {
 		for _, listener := range d.listeners {
 			listener.BeforeLoadTransform(i)
 		}
-		t, err := transform.New(d.mode, t, transform.WithFuncs(Funcs), transform.WithReporter(d.reporter))
+		opts := []transform.Option{
+			transform.WithFuncs(d.templateFuncs()),
+			transform.WithReporter(d.reporter),
+			transform.WithStrict(d.strict),
+		}
+		if t.FS == nil && d.fsys != nil {
+			opts = append(opts, transform.WithFS(d.fsys))
+		}
+		t, err := transform.New(d.mode, t, opts...)
 		for _, listener := range d.listeners {
 			listener.AfterLoadTransform(i, err)
 		}
@@ -233,6 +397,22 @@ func (d *Driver) LoadTransforms() ([]*transform.Transform, error) {
 	return transforms, err
 }
 
+// templateFuncs returns the builtin [Funcs], along with the funcs from
+// [TemplateFuncs].
+func (d *Driver) templateFuncs() transform.Funcs {
+	if len(d.funcs) == 0 {
+		return Funcs
+	}
+	result := make(transform.Funcs, len(Funcs)+len(d.funcs))
+	for name, fn := range Funcs {
+		result[name] = fn
+	}
+	for name, fn := range d.funcs {
+		result[name] = fn
+	}
+	return result
+}
+
 func (d *Driver) LoadConformanceModule() error {
 	for _, listener := range d.listeners {
 		listener.BeforeStage(StageLoadConformance)
@@ -262,26 +442,50 @@ func (d *Driver) LoadModel() (*model.Model, error) {
 }
 
 func (d *Driver) Transform(ctx context.Context, model *model.Model, transforms []*transform.Transform) error {
+	if d.sink != nil {
+		return d.transformToSink(ctx, model, transforms)
+	}
 	for _, listener := range d.listeners {
 		listener.BeforeStage(StageTransform)
 	}
 	runner := task.NewRunner(d.parallel)
 
+	previous, err := manifest.Load(d.outputPath)
+	if err != nil {
+		return err
+	}
+	current := manifest.New(d.outputPath)
+	skippable := previous
+	if !d.incremental {
+		skippable = nil
+	}
+
+	// Every primary output is claimed up front, so that files emitted by one
+	// job may not overwrite the output of another, regardless of the order
+	// that the jobs run in.
+	claims := &job.ClaimSet{}
+	stats := make([]transformCounters, len(transforms))
 	for i, t := range transforms {
-		jobs, err := job.New(model, d.outputPath, t)
+		jobs, err := job.New(model, d.outputPath, t, d.jobOptions(i, job.Manifests(skippable, current), job.Claims(claims))...)
 		for _, listener := range d.listeners {
 			listener.BeforeTransform(i, len(jobs))
 		}
 		if err != nil {
 			return err
 		}
+		for _, job := range jobs {
+			if err := claims.Claim(job.OutputPath(), job); err != nil {
+				return err
+			}
+		}
 		for _, job := range jobs {
 			runner.Add(task.Func(func(ctx context.Context) error {
 				for _, listener := range d.listeners {
 					listener.OnTransformOutput(i, job.OutputPath())
 				}
 
-				err := job.Execute(ctx)
+				status, err := job.Execute(ctx)
+				stats[i].add(status, err)
 
 				for _, listener := range d.listeners {
 					listener.AfterTransformOutput(i, job.OutputPath(), err)
@@ -291,25 +495,293 @@ func (d *Driver) Transform(ctx context.Context, model *model.Model, transforms [
 		}
 	}
 
-	_, err := runner.Run(ctx)
+	_, err = runner.Run(ctx)
+	for i := range stats {
+		for _, listener := range d.listeners {
+			listener.AfterTransform(i, stats[i].stats())
+		}
+	}
+	if err == nil {
+		if d.prune {
+			err = d.pruneStale(previous, current)
+		}
+	} else {
+		// Outputs of failed jobs are not produced by this run, but they are not
+		// stale either; so they are kept in the manifest to be pruned later.
+		for _, path := range previous.Paths() {
+			if _, ok := current.Lookup(path); !ok {
+				entry, _ := previous.Lookup(path)
+				current.Set(path, entry)
+			}
+		}
+	}
+	// The manifest only records jobs that succeeded, so it is saved even on
+	// failure so that the successful outputs may be skipped next time.
+	err = errors.Join(err, current.Save())
 	for _, listener := range d.listeners {
 		listener.AfterStage(StageTransform, err)
 	}
 	return err
 }
 
-func (d *Driver) Run(ctx context.Context) error {
-	if err := d.DownloadPackages(ctx); err != nil {
+// transformCounters accumulates the [TransformStats] of concurrently executed
+// jobs.
+type transformCounters struct {
+	written, unchanged, skipped, failed atomic.Int64
+}
+
+func (c *transformCounters) add(status job.Status, err error) {
+	switch {
+	case err != nil:
+		c.failed.Add(1)
+	case status == job.StatusWritten:
+		c.written.Add(1)
+	case status == job.StatusUnchanged:
+		c.unchanged.Add(1)
+	case status == job.StatusSkipped:
+		c.skipped.Add(1)
+	}
+}
+
+func (c *transformCounters) stats() *TransformStats {
+	return &TransformStats{
+		Written:   int(c.written.Load()),
+		Unchanged: int(c.unchanged.Load()),
+		Skipped:   int(c.skipped.Load()),
+		Failed:    int(c.failed.Load()),
+	}
+}
+
+// pruneStale deletes every output recorded in the previous manifest that is
+// not recorded in the current one, along with any directories left empty
+// within the output directory. Outputs that were modified since they were
+// generated are reported with [ErrModified] and kept.
+func (d *Driver) pruneStale(previous, current *manifest.Manifest) error {
+	var errs []error
+	for _, path := range staleOutputs(previous, current) {
+		err := d.pruneOutput(path, previous)
+		for _, listener := range d.listeners {
+			listener.OnPrune(path, err)
+		}
+		if err != nil && !errors.Is(err, ErrModified) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// staleOutputs returns every output recorded in the previous manifest that is
+// not recorded in the current one.
+func staleOutputs(previous, current *manifest.Manifest) []string {
+	var result []string
+	for _, path := range previous.Paths() {
+		if _, ok := current.Lookup(path); ok {
+			continue
+		}
+		// Outputs without content only emitted other files, and were never
+		// written themselves.
+		if entry, _ := previous.Lookup(path); entry.Content == "" {
+			continue
+		}
+		result = append(result, path)
+	}
+	return result
+}
+
+func (d *Driver) pruneOutput(path string, previous *manifest.Manifest) error {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
 		return err
 	}
-	if err := d.LoadConformanceModule(); err != nil {
+	if entry, _ := previous.Lookup(path); entry.Content != manifest.Digest(content) {
+		return ErrModified
+	}
+	if err := os.Remove(path); err != nil {
 		return err
 	}
+
+	root := filepath.Clean(d.outputPath)
+	for dir := filepath.Dir(path); dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		// Removing a non-empty directory fails, which ends the walk upwards.
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// jobOptions returns the options for the jobs of the i'th transform.
+func (d *Driver) jobOptions(i int, opts ...job.Option) []job.Option {
+	return append([]job.Option{
+		job.Index(i),
+		job.Packages(d.explicitPackages...),
+	}, opts...)
+}
+
+// Plan returns the jobs that each transform would execute, indexed by the
+// transform, without executing them. The jobs of each transform are sorted by
+// their output path. Files emitted by templates with file blocks are not known
+// until the jobs are rendered, and so are not part of the plan.
+func (d *Driver) Plan(model *model.Model, transforms []*transform.Transform) ([][]*job.Job, error) {
+	result := make([][]*job.Job, len(transforms))
+	for i, t := range transforms {
+		jobs, err := job.New(model, d.outputPath, t, d.jobOptions(i)...)
+		if err != nil {
+			return nil, err
+		}
+		slices.SortFunc(jobs, func(lhs, rhs *job.Job) int {
+			return strings.Compare(lhs.OutputPath(), rhs.OutputPath())
+		})
+		result[i] = jobs
+	}
+	return result, nil
+}
+
+// Drift is a transform output whose content on disk differs from what would be
+// generated.
+type Drift struct {
+	// Transform is the index of the transform that produces the output.
+	Transform int
+
+	// Path is the output path of the drifted file.
+	Path string
+
+	// Want is the content that would be generated, or nil if the output is
+	// stale and would be pruned.
+	Want []byte
+
+	// Got is the content currently on disk, or nil if the file does not exist.
+	Got []byte
+}
+
+// Check renders every transform output into memory and compares it against the
+// files on disk, returning every output that has drifted, sorted by path. If
+// pruning is enabled, stale outputs that would be deleted have also drifted,
+// and are reported with a Transform of -1. Nothing is written to disk.
+func (d *Driver) Check(ctx context.Context, model *model.Model, transforms []*transform.Transform) ([]*Drift, error) {
+	for _, listener := range d.listeners {
+		listener.BeforeStage(StageTransform)
+	}
+	plan, err := d.Plan(model, transforms)
+	if err != nil {
+		for _, listener := range d.listeners {
+			listener.AfterStage(StageTransform, err)
+		}
+		return nil, err
+	}
+
+	var m sync.Mutex
+	var drifts []*Drift
+	current := manifest.New(d.outputPath)
+	runner := task.NewRunner(d.parallel)
+	for i, jobs := range plan {
+		for _, listener := range d.listeners {
+			listener.BeforeTransform(i, len(jobs))
+		}
+		for _, job := range jobs {
+			runner.Add(task.Func(func(ctx context.Context) error {
+				for _, listener := range d.listeners {
+					listener.OnTransformOutput(i, job.OutputPath())
+				}
+				drift, err := d.check(ctx, i, job, current)
+				m.Lock()
+				drifts = append(drifts, drift...)
+				m.Unlock()
+				for _, listener := range d.listeners {
+					listener.AfterTransformOutput(i, job.OutputPath(), err)
+				}
+				return err
+			}))
+		}
+	}
+
+	_, err = runner.Run(ctx)
+	if err == nil && d.prune {
+		var stale []*Drift
+		stale, err = d.checkStale(current)
+		drifts = append(drifts, stale...)
+	}
+	for _, listener := range d.listeners {
+		listener.AfterStage(StageTransform, err)
+	}
+	slices.SortFunc(drifts, func(lhs, rhs *Drift) int {
+		return strings.Compare(lhs.Path, rhs.Path)
+	})
+	return drifts, err
+}
+
+// check renders the outputs of the job, and records each of them in the
+// current manifest so that stale outputs may be found afterwards.
+func (d *Driver) check(ctx context.Context, i int, job *job.Job, current *manifest.Manifest) ([]*Drift, error) {
+	outputs, err := job.Render(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var drifts []*Drift
+	for _, output := range outputs {
+		current.Set(output.Path, &manifest.Entry{Content: manifest.Digest(output.Content)})
+		got, err := os.ReadFile(output.Path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if err == nil && bytes.Equal(got, output.Content) {
+			continue
+		}
+		drifts = append(drifts, &Drift{Transform: i, Path: output.Path, Want: output.Content, Got: got})
+	}
+	return drifts, nil
+}
+
+// checkStale returns a drift for every stale output that pruning would delete.
+// Stale outputs that were modified since they were generated would be kept,
+// and so have not drifted.
+func (d *Driver) checkStale(current *manifest.Manifest) ([]*Drift, error) {
+	previous, err := manifest.Load(d.outputPath)
+	if err != nil {
+		return nil, err
+	}
+	var drifts []*Drift
+	for _, path := range staleOutputs(previous, current) {
+		got, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entry, _ := previous.Lookup(path); entry.Content != manifest.Digest(got) {
+			continue
+		}
+		drifts = append(drifts, &Drift{Transform: -1, Path: path, Got: got})
+	}
+	return drifts, nil
+}
+
+// Load runs every stage of the driver that precedes the transformation, and
+// returns the loaded model and transforms.
+func (d *Driver) Load(ctx context.Context) (*model.Model, []*transform.Transform, error) {
+	if err := d.DownloadPackages(ctx); err != nil {
+		return nil, nil, err
+	}
+	if err := d.LoadConformanceModule(); err != nil {
+		return nil, nil, err
+	}
 	model, err := d.LoadModel()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	transforms, err := d.LoadTransforms()
+	if err != nil {
+		return nil, nil, err
+	}
+	return model, transforms, nil
+}
+
+func (d *Driver) Run(ctx context.Context) error {
+	model, transforms, err := d.Load(ctx)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,440 @@
+package driver_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/friendly-fhir/fhenix/pkg/config"
+	"github.com/friendly-fhir/fhenix/pkg/driver"
+	"github.com/friendly-fhir/fhenix/pkg/driver/job"
+	"github.com/friendly-fhir/fhenix/pkg/driver/manifest"
+	"github.com/friendly-fhir/fhenix/pkg/model"
+	"github.com/friendly-fhir/fhenix/pkg/model/conformance"
+	"github.com/friendly-fhir/fhenix/pkg/registry"
+	"github.com/friendly-fhir/fhenix/pkg/transform"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func structureDefinition(name string) []byte {
+	return []byte(`{
+		"resourceType": "StructureDefinition",
+		"url": "http://example.com/StructureDefinition/` + name + `",
+		"name": "` + name + `",
+		"status": "active",
+		"kind": "logical",
+		"abstract": false,
+		"type": "` + name + `",
+		"snapshot": {"element": [{"id": "` + name + `", "path": "` + name + `"}]}
+	}`)
+}
+
+func TestDriver_Sink(t *testing.T) {
+	module := conformance.NewModule("http://example.com")
+	pkg := registry.NewPackageRef("default", "example.test", "1.0.0")
+	for _, name := range []string{"Patient", "Practitioner"} {
+		if err := module.ParseJSON(structureDefinition(name), pkg); err != nil {
+			t.Fatalf("Module.ParseJSON() = %v", err)
+		}
+	}
+	templates := fstest.MapFS{
+		"templates/type.tmpl": {Data: []byte(`{{ greet .Name }}`)},
+	}
+
+	got := map[string]string{}
+	sut, err := driver.New(nil,
+		driver.ConformanceModule(module),
+		driver.OutputDir("gen"),
+		driver.Transforms(&config.Transform{
+			OutputPath: "{{ .Name | string.Lower }}.txt",
+			Templates: map[string]string{
+				"structure-definition": "templates/type.tmpl",
+			},
+		}),
+		driver.TemplateFS(templates),
+		driver.TemplateFuncs(transform.Funcs{
+			"greet": func(name string) string { return "hello " + strings.ToLower(name) },
+		}),
+		driver.Sink(driver.OutputSinkFunc(func(path string, content []byte) error {
+			got[path] = string(content)
+			return nil
+		})),
+	)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+
+	model, err := sut.LoadModel()
+	if err != nil {
+		t.Fatalf("Driver.LoadModel() = %v", err)
+	}
+	transforms, err := sut.LoadTransforms()
+	if err != nil {
+		t.Fatalf("Driver.LoadTransforms() = %v", err)
+	}
+	if err := sut.Transform(context.Background(), model, transforms); err != nil {
+		t.Fatalf("Driver.Transform() = %v", err)
+	}
+
+	want := map[string]string{
+		"patient.txt":      "hello patient",
+		"practitioner.txt": "hello practitioner",
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Driver.Transform() outputs (-got +want)\n%s", cmp.Diff(got, want))
+	}
+}
+
+// recorder is a [driver.Listener] that records the stats of every transform,
+// and the outcome of every pruned output.
+type recorder struct {
+	driver.BaseListener
+	stats  []*driver.TransformStats
+	pruned map[string]error
+}
+
+func (r *recorder) AfterTransform(n int, stats *driver.TransformStats) {
+	r.stats = append(r.stats, stats)
+}
+
+func (r *recorder) OnPrune(output string, err error) {
+	if r.pruned == nil {
+		r.pruned = map[string]error{}
+	}
+	r.pruned[output] = err
+}
+
+// typesDriver creates a driver for a model of the named logical types from the
+// given package version, with a single transform that renders each type to
+// 'types/<name>/type.txt' in the output directory.
+func typesDriver(t *testing.T, dir, version string, names []string, fsys fs.FS, vars map[string]any, opts ...driver.Option) (*driver.Driver, *recorder) {
+	t.Helper()
+	module := conformance.NewModule("http://example.com")
+	pkg := registry.NewPackageRef("default", "example.test", version)
+	for _, name := range names {
+		if err := module.ParseJSON(structureDefinition(name), pkg); err != nil {
+			t.Fatalf("Module.ParseJSON() = %v", err)
+		}
+	}
+	rec := &recorder{}
+	opts = append([]driver.Option{
+		driver.ConformanceModule(module),
+		driver.OutputDir(dir),
+		driver.Transforms(&config.Transform{
+			OutputPath: "types/{{ .Name | string.Lower }}/type.txt",
+			Templates: map[string]string{
+				"structure-definition": "templates/type.tmpl",
+			},
+			Vars: vars,
+		}),
+		driver.TemplateFS(fsys),
+		driver.Listeners(rec),
+	}, opts...)
+	sut, err := driver.New(nil, opts...)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	return sut, rec
+}
+
+// load loads the model and transforms of the driver.
+func load(t *testing.T, sut *driver.Driver) (*model.Model, []*transform.Transform) {
+	t.Helper()
+	model, err := sut.LoadModel()
+	if err != nil {
+		t.Fatalf("Driver.LoadModel() = %v", err)
+	}
+	transforms, err := sut.LoadTransforms()
+	if err != nil {
+		t.Fatalf("Driver.LoadTransforms() = %v", err)
+	}
+	return model, transforms
+}
+
+// transformTypes transforms the types with the driver from [typesDriver].
+func transformTypes(t *testing.T, dir, version string, names []string, fsys fs.FS, vars map[string]any, opts ...driver.Option) (*recorder, error) {
+	t.Helper()
+	sut, rec := typesDriver(t, dir, version, names, fsys, vars, opts...)
+	model, transforms := load(t, sut)
+	return rec, sut.Transform(context.Background(), model, transforms)
+}
+
+func templateFS(template string) fstest.MapFS {
+	return fstest.MapFS{
+		"templates/type.tmpl": {Data: []byte(template)},
+	}
+}
+
+func TestDriver_Transform_Incremental(t *testing.T) {
+	names := []string{"Patient", "Practitioner"}
+	vars := map[string]any{"greeting": "hello"}
+
+	testCases := []struct {
+		name     string
+		template string
+		vars     map[string]any
+		version  string
+		opts     []driver.Option
+		modify   string
+		want     *driver.TransformStats
+	}{
+		{
+			name:     "unchanged inputs are skipped",
+			template: "{{ .Name }}",
+			vars:     vars,
+			version:  "1.0.0",
+			want:     &driver.TransformStats{Skipped: 2},
+		}, {
+			name:     "changed template is regenerated",
+			template: "type {{ .Name }}",
+			vars:     vars,
+			version:  "1.0.0",
+			want:     &driver.TransformStats{Written: 2},
+		}, {
+			name:     "changed vars are regenerated",
+			template: "{{ .Name }}",
+			vars:     map[string]any{"greeting": "goodbye"},
+			version:  "1.0.0",
+			want:     &driver.TransformStats{Unchanged: 2},
+		}, {
+			name:     "changed inputs are regenerated",
+			template: "{{ .Name }}",
+			vars:     vars,
+			version:  "1.0.1",
+			want:     &driver.TransformStats{Unchanged: 2},
+		}, {
+			name:     "modified outputs are regenerated",
+			template: "{{ .Name }}",
+			vars:     vars,
+			version:  "1.0.0",
+			modify:   "types/patient/type.txt",
+			want:     &driver.TransformStats{Written: 1, Skipped: 1},
+		}, {
+			name:     "not incremental",
+			template: "{{ .Name }}",
+			vars:     vars,
+			version:  "1.0.0",
+			opts:     []driver.Option{driver.Incremental(false)},
+			want:     &driver.TransformStats{Unchanged: 2},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if _, err := transformTypes(t, dir, "1.0.0", names, templateFS("{{ .Name }}"), vars); err != nil {
+				t.Fatalf("Driver.Transform() = %v", err)
+			}
+			if tc.modify != "" {
+				if err := os.WriteFile(filepath.Join(dir, tc.modify), []byte("modified"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			rec, err := transformTypes(t, dir, tc.version, names, templateFS(tc.template), tc.vars, tc.opts...)
+			if err != nil {
+				t.Fatalf("Driver.Transform() = %v", err)
+			}
+
+			if got, want := rec.stats, []*driver.TransformStats{tc.want}; !cmp.Equal(got, want) {
+				t.Errorf("Driver.Transform() stats (-got +want)\n%s", cmp.Diff(got, want))
+			}
+		})
+	}
+}
+
+func TestDriver_Check_Stale(t *testing.T) {
+	dir := t.TempDir()
+	fsys := templateFS("{{ .Name }}")
+	if _, err := transformTypes(t, dir, "1.0.0", []string{"Patient", "Practitioner", "Device"}, fsys, nil); err != nil {
+		t.Fatalf("Driver.Transform() = %v", err)
+	}
+	modified := filepath.Join(dir, "types", "device", "type.txt")
+	if err := os.WriteFile(modified, []byte("modified"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name string
+		opts []driver.Option
+		want []*driver.Drift
+	}{
+		{
+			name: "stale outputs have drifted",
+			want: []*driver.Drift{{
+				Transform: -1,
+				Path:      filepath.Join(dir, "types", "practitioner", "type.txt"),
+				Got:       []byte("Practitioner"),
+			}},
+		}, {
+			name: "not pruned",
+			opts: []driver.Option{driver.Prune(false)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sut, _ := typesDriver(t, dir, "1.0.0", []string{"Patient"}, fsys, nil, tc.opts...)
+			model, transforms := load(t, sut)
+
+			got, err := sut.Check(context.Background(), model, transforms)
+			if err != nil {
+				t.Fatalf("Driver.Check() = %v", err)
+			}
+
+			if !cmp.Equal(got, tc.want) {
+				t.Errorf("Driver.Check() (-got +want)\n%s", cmp.Diff(got, tc.want))
+			}
+		})
+	}
+}
+
+func TestDriver_Transform_Prune(t *testing.T) {
+	fsys := templateFS("{{ .Name }}")
+	names := []string{"Patient", "Practitioner"}
+	output := func(dir, name string) string {
+		return filepath.Join(dir, "types", name, "type.txt")
+	}
+
+	t.Run("stale output is deleted", func(t *testing.T) {
+		dir := t.TempDir()
+		if _, err := transformTypes(t, dir, "1.0.0", names, fsys, nil); err != nil {
+			t.Fatalf("Driver.Transform() = %v", err)
+		}
+
+		rec, err := transformTypes(t, dir, "1.0.0", []string{"Patient"}, fsys, nil)
+		if err != nil {
+			t.Fatalf("Driver.Transform() = %v", err)
+		}
+
+		want := map[string]error{output(dir, "practitioner"): nil}
+		if !cmp.Equal(rec.pruned, want, cmpopts.EquateErrors()) {
+			t.Errorf("Driver.Transform() pruned (-got +want)\n%s", cmp.Diff(rec.pruned, want, cmpopts.EquateErrors()))
+		}
+		if _, err := os.Stat(output(dir, "practitioner")); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("os.Stat(stale) = %v, want %v", err, fs.ErrNotExist)
+		}
+		if _, err := os.Stat(output(dir, "patient")); err != nil {
+			t.Errorf("os.Stat(current) = %v, want nil", err)
+		}
+	})
+
+	t.Run("modified output is kept", func(t *testing.T) {
+		dir := t.TempDir()
+		if _, err := transformTypes(t, dir, "1.0.0", names, fsys, nil); err != nil {
+			t.Fatalf("Driver.Transform() = %v", err)
+		}
+		if err := os.WriteFile(output(dir, "practitioner"), []byte("modified"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		rec, err := transformTypes(t, dir, "1.0.0", []string{"Patient"}, fsys, nil)
+		if err != nil {
+			t.Fatalf("Driver.Transform() = %v", err)
+		}
+
+		want := map[string]error{output(dir, "practitioner"): driver.ErrModified}
+		if !cmp.Equal(rec.pruned, want, cmpopts.EquateErrors()) {
+			t.Errorf("Driver.Transform() pruned (-got +want)\n%s", cmp.Diff(rec.pruned, want, cmpopts.EquateErrors()))
+		}
+		if got, err := os.ReadFile(output(dir, "practitioner")); err != nil || string(got) != "modified" {
+			t.Errorf("os.ReadFile(modified) = %q, %v; want %q, nil", got, err, "modified")
+		}
+	})
+
+	t.Run("empty directories are removed up to the output directory", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "gen")
+		if _, err := transformTypes(t, dir, "1.0.0", names, fsys, nil); err != nil {
+			t.Fatalf("Driver.Transform() = %v", err)
+		}
+
+		if _, err := transformTypes(t, dir, "1.0.0", nil, fsys, nil); err != nil {
+			t.Fatalf("Driver.Transform() = %v", err)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "types")); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("os.Stat(types) = %v, want %v", err, fs.ErrNotExist)
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			t.Errorf("os.Stat(output dir) = %v, want directory", err)
+		}
+	})
+
+	t.Run("failed job keeps its previous entry", func(t *testing.T) {
+		dir := t.TempDir()
+		if _, err := transformTypes(t, dir, "1.0.0", names, fsys, nil); err != nil {
+			t.Fatalf("Driver.Transform() = %v", err)
+		}
+		fail := driver.TemplateFuncs(transform.Funcs{
+			"check": func(name string) (string, error) {
+				if name == "Practitioner" {
+					return "", errors.New("unsupported")
+				}
+				return name, nil
+			},
+		})
+
+		_, err := transformTypes(t, dir, "1.0.0", names, templateFS("{{ check .Name }}"), nil, fail)
+		if err == nil {
+			t.Fatalf("Driver.Transform() = nil, want error")
+		}
+
+		previous, err := manifest.Load(dir)
+		if err != nil {
+			t.Fatalf("manifest.Load() = %v", err)
+		}
+		if _, ok := previous.Lookup(output(dir, "practitioner")); !ok {
+			t.Errorf("Manifest.Lookup(failed) = false, want true")
+		}
+		if _, err := os.Stat(output(dir, "practitioner")); err != nil {
+			t.Errorf("os.Stat(failed) = %v, want nil", err)
+		}
+	})
+}
+
+func TestDriver_Transform_EmittedPaths(t *testing.T) {
+	testCases := []struct {
+		name     string
+		template string
+		wantErr  error
+	}{
+		{
+			name:     "relative path",
+			template: `{{ .Name }}{{ file (printf "emitted/%s.txt" .Name) }}x{{ endfile }}`,
+		}, {
+			name:     "absolute path",
+			template: `{{ .Name }}{{ file "/tmp/emitted.txt" }}x{{ endfile }}`,
+			wantErr:  job.ErrInvalidPath,
+		}, {
+			name:     "escaping path",
+			template: `{{ .Name }}{{ file "../emitted.txt" }}x{{ endfile }}`,
+			wantErr:  job.ErrInvalidPath,
+		}, {
+			name:     "same path emitted by two jobs",
+			template: `{{ .Name }}{{ file "emitted.txt" }}x{{ endfile }}`,
+			wantErr:  job.ErrCollision,
+		}, {
+			name:     "path of the output of another job",
+			template: `{{ .Name }}{{ if eq .Name "Patient" }}{{ file "types/practitioner/type.txt" }}x{{ endfile }}{{ end }}`,
+			wantErr:  job.ErrCollision,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			_, err := transformTypes(t, dir, "1.0.0", []string{"Patient", "Practitioner"}, templateFS(tc.template), nil)
+
+			if got, want := err, tc.wantErr; !errors.Is(got, want) {
+				t.Errorf("Driver.Transform() = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -2,11 +2,13 @@ package driver
 
 import (
 	"github.com/friendly-fhir/fhenix/pkg/model"
+	"github.com/friendly-fhir/fhenix/pkg/model/sample"
 	"github.com/friendly-fhir/fhenix/pkg/transform"
 )
 
 var Funcs = transform.Funcs{
 	"commonbase": CommonBase,
+	"sample":     Sample,
 }
 
 func commonBase(t1, t2 *model.Type) *model.Type {
@@ -35,3 +37,10 @@ func CommonBase(types []*model.Type) *model.Type {
 	}
 	return base
 }
+
+// Sample generates a synthetic example instance of the given type, using the
+// given seed so that the output is reproducible across runs. The result is a
+// JSON-compatible value, and is intended to be paired with 'json.EncodeIndent'.
+func Sample(t *model.Type, seed int) any {
+	return sample.New(sample.Seed(int64(seed))).Generate(t)
+}
@@ -0,0 +1,393 @@
+/*
+Package golden runs the transforms of a config against small fixture packages,
+and compares the rendered files to golden files that are checked in alongside
+the templates. This gives template authors a fast feedback loop, since no
+packages are downloaded from a registry: the fixture stands in for the input
+package of the same name, and the other inputs of the config are loaded from
+the FHIR cache as its dependencies.
+
+Each test case is a directory containing a [CaseFile], which declares the
+fixture that the transforms are run against, along with a [GoldenDir] that
+mirrors the output directory of the config:
+
+	testdata/
+	  patient/
+	    test.yaml
+	    golden/
+	      patient.go
+*/
+package golden
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/friendly-fhir/fhenix/pkg/config"
+	"github.com/friendly-fhir/fhenix/pkg/driver"
+	"github.com/friendly-fhir/fhenix/pkg/driver/sink"
+	"github.com/friendly-fhir/fhenix/pkg/model/conformance"
+	"github.com/friendly-fhir/fhenix/pkg/model/loader"
+	"github.com/friendly-fhir/fhenix/pkg/registry"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// CaseFile is the name of the file that declares a test case.
+	CaseFile = "test.yaml"
+
+	// GoldenDir is the name of the directory of a test case that contains the
+	// golden files, relative to the output directory of the config.
+	GoldenDir = "golden"
+)
+
+var (
+	// ErrNoCases is an error returned when no test cases are found.
+	ErrNoCases = errors.New("no test cases")
+
+	// ErrNoFixture is an error returned when a test case declares neither a
+	// package nor any definitions.
+	ErrNoFixture = errors.New("test case has no fixture")
+)
+
+// fixtureRef is the reference of a fixture package that has no package.json,
+// and of inline definitions. Fixtures are referenced as packages of the
+// default registry, as the packages of a config are, so that templates render
+// the same package references as they would in a run.
+var fixtureRef = registry.NewPackageRef(registry.Default, "fixture", "0.0.0")
+
+// Case is a single golden test case.
+type Case struct {
+	// Name is the path of the test case directory, relative to the directory
+	// that the cases were loaded from.
+	Name string
+
+	// Dir is the path of the test case directory.
+	Dir string
+
+	// Package is the path of the fixture package, which is either a directory
+	// of FHIR definitions, or a package archive ending in '.tgz' or '.tar.gz'.
+	// This is empty if the fixture only has inline definitions.
+	Package string
+
+	// Definitions are the inline FHIR definitions of the fixture, as JSON.
+	Definitions []json.RawMessage
+}
+
+// caseFile is the content of a [CaseFile].
+type caseFile struct {
+	// Package is the path of the fixture package, relative to the case file.
+	Package string `yaml:"package"`
+
+	// Definitions are inline FHIR definitions, such as StructureDefinitions.
+	Definitions []map[string]any `yaml:"definitions"`
+}
+
+// LoadCases loads every test case in the directory, which are the directories
+// that contain a [CaseFile], sorted by name. If any names are given, only the
+// cases with those names are loaded.
+func LoadCases(root string, names ...string) ([]*Case, error) {
+	var cases []*Case
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || entry.Name() != CaseFile {
+			return nil
+		}
+		dir := filepath.Dir(path)
+		name, err := filepath.Rel(root, dir)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		if len(names) > 0 && !slices.Contains(names, name) {
+			return nil
+		}
+		c, err := loadCase(name, dir)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		cases = append(cases, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("%w in %s", ErrNoCases, root)
+	}
+	slices.SortFunc(cases, func(lhs, rhs *Case) int {
+		return strings.Compare(lhs.Name, rhs.Name)
+	})
+	return cases, nil
+}
+
+func loadCase(name, dir string) (*Case, error) {
+	content, err := os.ReadFile(filepath.Join(dir, CaseFile))
+	if err != nil {
+		return nil, err
+	}
+	var file caseFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, err
+	}
+	if file.Package == "" && len(file.Definitions) == 0 {
+		return nil, ErrNoFixture
+	}
+	result := &Case{Name: name, Dir: dir}
+	if file.Package != "" {
+		result.Package = filepath.Join(dir, filepath.FromSlash(file.Package))
+	}
+	for _, def := range file.Definitions {
+		data, err := json.Marshal(def)
+		if err != nil {
+			return nil, err
+		}
+		result.Definitions = append(result.Definitions, data)
+	}
+	return result, nil
+}
+
+// Module loads the fixture of the test case into a new conformance module,
+// and returns the reference of the fixture package. The dependencies, along
+// with the dependencies declared by the fixture package, are loaded from the
+// cache; they are not downloaded, so they must already be cached. Dependencies
+// with the same name as the fixture package are replaced by the fixture.
+func (c *Case) Module(cache *registry.Cache, deps ...registry.PackageRef) (*conformance.Module, registry.PackageRef, error) {
+	module := conformance.DefaultModule()
+	ref := fixtureRef
+	var refs []registry.PackageRef
+	if c.Package != "" {
+		pkg, err := c.loadPackage(module)
+		if err != nil {
+			return nil, "", err
+		}
+		ref = pkg.Ref
+		for name, version := range pkg.Dependencies() {
+			refs = append(refs, registry.NewPackageRef(registry.Default, name, version))
+		}
+	}
+	for i, def := range c.Definitions {
+		if err := module.ParseJSON(def, ref); err != nil {
+			return nil, "", fmt.Errorf("definition %d: %w", i, err)
+		}
+	}
+
+	refs = slices.DeleteFunc(append(refs, deps...), func(dep registry.PackageRef) bool {
+		return dep.Name() == ref.Name()
+	})
+	if err := loader.New(cache, loader.WithModule(module)).Load(refs...); err != nil {
+		return nil, "", fmt.Errorf("dependencies: %w", err)
+	}
+	return module, ref, nil
+}
+
+// loadPackage loads the fixture package into the module. Archives are
+// unpacked into a temporary directory, as they would be into the cache.
+func (c *Case) loadPackage(module *conformance.Module) (*registry.Package, error) {
+	if !isArchive(c.Package) {
+		return loadDir(module, c.Package)
+	}
+
+	file, err := os.Open(c.Package)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	r, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "fhenix-golden-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := registry.Unpack(r, dir); err != nil {
+		return nil, err
+	}
+	return loadDir(module, dir)
+}
+
+// loadDir loads the package in the directory into the module. A directory
+// without a package.json is a plain directory of definitions.
+func loadDir(module *conformance.Module, dir string) (*registry.Package, error) {
+	pkg, err := registry.NewPackage(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, err
+		}
+		pkg, err = &registry.Package{Path: dir}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := loadInto(module, pkg); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// loadInto loads the package into the module, named by its manifest if it has
+// one.
+func loadInto(module *conformance.Module, pkg *registry.Package) error {
+	pkg.Ref = fixtureRef
+	if pkg.Name() != "" {
+		pkg.Ref = registry.NewPackageRef(registry.Default, pkg.Name(), pkg.Version())
+	}
+	return module.FromPackage(pkg)
+}
+
+// isArchive returns true if the path is a package archive.
+func isArchive(path string) bool {
+	return strings.HasSuffix(path, ".tgz") || strings.HasSuffix(path, ".tar.gz")
+}
+
+// Render runs every transform of the config against the fixture of the test
+// case, and returns the content of every rendered file by its slash-separated
+// path relative to the output directory. The input package of the config with
+// the name of the fixture package is replaced by the fixture, the other input
+// packages are loaded from the cache, and nothing is written to disk.
+func (c *Case) Render(ctx context.Context, cfg *config.Config, cache *registry.Cache, opts ...driver.Option) (map[string][]byte, error) {
+	var deps []registry.PackageRef
+	for _, pkg := range cfg.Input {
+		deps = append(deps, registry.NewPackageRef(registry.Default, pkg.Name, pkg.Version))
+	}
+	module, ref, err := c.Module(cache, deps...)
+	if err != nil {
+		return nil, err
+	}
+	deps = slices.DeleteFunc(deps, func(dep registry.PackageRef) bool {
+		return dep.Name() == ref.Name()
+	})
+
+	// Protected regions are read from existing files in the output directory,
+	// so the outputs are rendered relative to an empty directory instead.
+	dir, err := os.MkdirTemp("", "fhenix-golden-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	fixture := *cfg
+	fixture.Input = nil
+	var outputs sink.Memory
+	d, err := driver.New(&fixture, append([]driver.Option{
+		driver.ConformanceModule(module),
+		driver.ExplicitPackages(append([]registry.PackageRef{ref}, deps...)...),
+		driver.OutputDir(dir),
+		driver.Sink(&outputs),
+	}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	model, err := d.LoadModel()
+	if err != nil {
+		return nil, err
+	}
+	transforms, err := d.LoadTransforms()
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Transform(ctx, model, transforms); err != nil {
+		return nil, err
+	}
+	return outputs.Files(), nil
+}
+
+// Mismatch is a rendered file that differs from its golden file.
+type Mismatch struct {
+	// Path is the slash-separated path of the file, relative to the output
+	// directory.
+	Path string
+
+	// Want is the content of the golden file, or nil if there is none.
+	Want []byte
+
+	// Got is the rendered content, or nil if the file was not rendered.
+	Got []byte
+}
+
+// Compare compares the rendered files to the golden files of the test case,
+// and returns every mismatch, sorted by path.
+func (c *Case) Compare(outputs map[string][]byte) ([]*Mismatch, error) {
+	golden, err := c.golden()
+	if err != nil {
+		return nil, err
+	}
+	var result []*Mismatch
+	for path, got := range outputs {
+		want, ok := golden[path]
+		if !ok || !bytes.Equal(got, want) {
+			result = append(result, &Mismatch{Path: path, Want: want, Got: got})
+		}
+	}
+	for path, want := range golden {
+		if _, ok := outputs[path]; !ok {
+			result = append(result, &Mismatch{Path: path, Want: want})
+		}
+	}
+	slices.SortFunc(result, func(lhs, rhs *Mismatch) int {
+		return strings.Compare(lhs.Path, rhs.Path)
+	})
+	return result, nil
+}
+
+// Update replaces the golden files of the test case with the rendered files.
+func (c *Case) Update(outputs map[string][]byte) error {
+	dir := filepath.Join(c.Dir, GoldenDir)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return writeAll(sink.Dir(dir), outputs)
+}
+
+func writeAll(dir sink.Dir, outputs map[string][]byte) error {
+	paths := make([]string, 0, len(outputs))
+	for path := range outputs {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	for _, path := range paths {
+		if err := dir.WriteOutput(path, outputs[path]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// golden reads every golden file of the test case, by its slash-separated path
+// relative to the golden directory. A missing golden directory has no files.
+func (c *Case) golden() (map[string][]byte, error) {
+	dir := filepath.Join(c.Dir, GoldenDir)
+	result := map[string][]byte{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == dir {
+			return fs.SkipAll
+		}
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		result[filepath.ToSlash(rel)] = content
+		return nil
+	})
+	return result, err
+}
@@ -0,0 +1,343 @@
+package golden_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/friendly-fhir/fhenix/pkg/config"
+	"github.com/friendly-fhir/fhenix/pkg/driver/golden"
+	"github.com/friendly-fhir/fhenix/pkg/registry"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func structureDefinition(name string) string {
+	return `{
+		"resourceType": "StructureDefinition",
+		"url": "http://example.com/StructureDefinition/` + name + `",
+		"name": "` + name + `",
+		"status": "active",
+		"kind": "logical",
+		"abstract": false,
+		"type": "` + name + `",
+		"snapshot": {"element": [{"id": "` + name + `", "path": "` + name + `"}]}
+	}`
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newConfig() *config.Config {
+	return &config.Config{
+		Mode:      config.ModeText,
+		OutputDir: "gen",
+		Input:     []*config.Package{{Name: "hl7.fhir.r4.core", Version: "4.0.1"}},
+		Transforms: []*config.Transform{{
+			OutputPath: "{{ .Name | string.Lower }}.txt",
+			Templates: map[string]string{
+				"structure-definition": "type.tmpl",
+			},
+			FS: fstest.MapFS{
+				"type.tmpl": {Data: []byte(`{{ .Name }} from {{ .Source.Package }}`)},
+			},
+		}},
+	}
+}
+
+// newCache returns a cache in a temporary directory, with each package in the
+// default registry, by its reference, containing the given files.
+func newCache(t *testing.T, packages map[registry.PackageRef]map[string]string) *registry.Cache {
+	t.Helper()
+	cache := registry.NewCache(t.TempDir())
+	for ref, files := range packages {
+		writeFiles(t, cache.CacheDir(ref.Parts()), files)
+	}
+	return cache
+}
+
+// tarball returns a gzipped package archive of the files, nested under the
+// 'package' directory as they are in a registry.
+func tarball(t *testing.T, files map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	w := tar.NewWriter(gz)
+	for name, content := range files {
+		header := &tar.Header{Name: "package/" + name, Mode: 0644, Size: int64(len(content))}
+		if err := w.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestLoadCases(t *testing.T) {
+	testCases := []struct {
+		name    string
+		files   map[string]string
+		names   []string
+		want    []string
+		wantErr error
+	}{
+		{
+			name:    "no cases",
+			files:   map[string]string{"README.md": ""},
+			wantErr: golden.ErrNoCases,
+		}, {
+			name:    "case without fixture",
+			files:   map[string]string{"empty/test.yaml": "{}"},
+			wantErr: golden.ErrNoFixture,
+		}, {
+			name: "nested cases are sorted",
+			files: map[string]string{
+				"patient/test.yaml":        "package: ../fixture",
+				"nested/widget/test.yaml":  "package: ../../fixture",
+				"nested/widget/golden/a.b": "",
+			},
+			want: []string{"nested/widget", "patient"},
+		}, {
+			name: "cases are selected by name",
+			files: map[string]string{
+				"patient/test.yaml":      "package: ../fixture",
+				"practitioner/test.yaml": "package: ../fixture",
+			},
+			names: []string{"practitioner"},
+			want:  []string{"practitioner"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, tc.files)
+
+			cases, err := golden.LoadCases(root, tc.names...)
+
+			if got, want := err, tc.wantErr; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+				t.Fatalf("LoadCases() = %v, want %v", got, want)
+			}
+			var got []string
+			for _, c := range cases {
+				got = append(got, c.Name)
+			}
+			if !cmp.Equal(got, tc.want) {
+				t.Errorf("LoadCases() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCase_Module(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  registry.PackageRef
+	}{
+		{
+			name: "package with manifest",
+			files: map[string]string{
+				"case/test.yaml":                      "package: ../fixture",
+				"fixture/package.json":                `{"name": "example.fixture", "version": "1.2.3"}`,
+				"fixture/StructureDefinition-A.json":  structureDefinition("A"),
+				"fixture/StructureDefinition-B.json":  structureDefinition("B"),
+				"fixture/not-a-fhir-definition.txt":   "ignored",
+				"fixture/nested/ValueSet-ignored.txt": "ignored",
+			},
+			want: registry.NewPackageRef(registry.Default, "example.fixture", "1.2.3"),
+		}, {
+			name: "directory without manifest",
+			files: map[string]string{
+				"case/test.yaml":                     "package: ../fixture",
+				"fixture/StructureDefinition-A.json": structureDefinition("A"),
+				"fixture/StructureDefinition-B.json": structureDefinition("B"),
+			},
+			want: registry.NewPackageRef(registry.Default, "fixture", "0.0.0"),
+		}, {
+			name: "package archive",
+			files: map[string]string{
+				"case/test.yaml": "package: ../fixture.tgz",
+				"fixture.tgz": tarball(t, map[string]string{
+					"package.json":               `{"name": "example.fixture", "version": "1.2.3"}`,
+					"StructureDefinition-A.json": structureDefinition("A"),
+					"StructureDefinition-B.json": structureDefinition("B"),
+					"other/.index.json":          `{}`,
+					"not-a-fhir-definition.txt":  "ignored",
+				}),
+			},
+			want: registry.NewPackageRef(registry.Default, "example.fixture", "1.2.3"),
+		}, {
+			name: "inline definitions",
+			files: map[string]string{
+				"case/test.yaml": "definitions:\n  - " + structureDefinition("A") + "\n  - " + structureDefinition("B") + "\n",
+			},
+			want: registry.NewPackageRef(registry.Default, "fixture", "0.0.0"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, tc.files)
+			cases, err := golden.LoadCases(root)
+			if err != nil {
+				t.Fatalf("LoadCases() = %v", err)
+			}
+
+			module, ref, err := cases[0].Module(newCache(t, nil))
+			if err != nil {
+				t.Fatalf("Case.Module() = %v", err)
+			}
+
+			if got, want := ref, tc.want; got != want {
+				t.Errorf("Case.Module() ref = %v, want %v", got, want)
+			}
+			if got, want := len(module.StructureDefinitions()), 2; got != want {
+				t.Errorf("Case.Module() has %d structure definitions, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestCase_Module_Dependencies(t *testing.T) {
+	fixture := registry.NewPackageRef(registry.Default, "example.fixture", "1.0.0")
+	dep := registry.NewPackageRef(registry.Default, "example.dep", "1.0.0")
+	input := registry.NewPackageRef(registry.Default, "example.input", "1.0.0")
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"case/test.yaml":                     "package: ../fixture",
+		"fixture/package.json":               `{"name": "example.fixture", "version": "1.0.0", "dependencies": {"example.dep": "1.0.0"}}`,
+		"fixture/StructureDefinition-A.json": structureDefinition("A"),
+	})
+	cache := newCache(t, map[registry.PackageRef]map[string]string{
+		dep: {
+			"package.json":               `{"name": "example.dep", "version": "1.0.0"}`,
+			"StructureDefinition-B.json": structureDefinition("B"),
+		},
+		input: {
+			"package.json":               `{"name": "example.input", "version": "1.0.0"}`,
+			"StructureDefinition-C.json": structureDefinition("C"),
+		},
+	})
+	cases, err := golden.LoadCases(root)
+	if err != nil {
+		t.Fatalf("LoadCases() = %v", err)
+	}
+
+	// The cached version of the fixture is not loaded, since the fixture
+	// replaces it.
+	module, _, err := cases[0].Module(cache, input, registry.NewPackageRef(registry.Default, "example.fixture", "0.9.0"))
+	if err != nil {
+		t.Fatalf("Case.Module() = %v", err)
+	}
+
+	want := map[string]registry.PackageRef{"A": fixture, "B": dep, "C": input}
+	got := map[string]registry.PackageRef{}
+	for _, sd := range module.StructureDefinitions() {
+		got[sd.GetName().GetValue()] = module.SourceOf(sd).Package
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Case.Module() structure definitions mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCase_Module_MissingDependency(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"case/test.yaml": "definitions:\n  - " + structureDefinition("A") + "\n",
+	})
+	cases, err := golden.LoadCases(root)
+	if err != nil {
+		t.Fatalf("LoadCases() = %v", err)
+	}
+
+	_, _, err = cases[0].Module(newCache(t, nil), registry.NewPackageRef(registry.Default, "example.input", "1.0.0"))
+
+	if got, want := err, os.ErrNotExist; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+		t.Errorf("Case.Module() = %v, want %v", got, want)
+	}
+}
+
+func TestCase_Golden(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"case/test.yaml":                     "package: ../fixture",
+		"fixture/package.json":               `{"name": "example.fixture", "version": "1.0.0"}`,
+		"fixture/StructureDefinition-A.json": structureDefinition("A"),
+		"fixture/StructureDefinition-B.json": structureDefinition("B"),
+	})
+	cases, err := golden.LoadCases(root)
+	if err != nil {
+		t.Fatalf("LoadCases() = %v", err)
+	}
+	c := cases[0]
+	ctx := context.Background()
+
+	cache := newCache(t, map[registry.PackageRef]map[string]string{
+		registry.NewPackageRef(registry.Default, "hl7.fhir.r4.core", "4.0.1"): {
+			"package.json": `{"name": "hl7.fhir.r4.core", "version": "4.0.1"}`,
+		},
+	})
+
+	outputs, err := c.Render(ctx, newConfig(), cache)
+	if err != nil {
+		t.Fatalf("Case.Render() = %v", err)
+	}
+	want := map[string][]byte{
+		"a.txt": []byte("A from default::example.fixture@1.0.0"),
+		"b.txt": []byte("B from default::example.fixture@1.0.0"),
+	}
+	if !cmp.Equal(outputs, want) {
+		t.Fatalf("Case.Render() = %q, want %q", outputs, want)
+	}
+
+	mismatches, err := c.Compare(outputs)
+	if err != nil {
+		t.Fatalf("Case.Compare() = %v", err)
+	}
+	if got, want := len(mismatches), 2; got != want {
+		t.Fatalf("Case.Compare() = %d mismatches without golden files, want %d", got, want)
+	}
+
+	writeFiles(t, filepath.Join(c.Dir, golden.GoldenDir), map[string]string{"stale.txt": "stale"})
+	if err := c.Update(outputs); err != nil {
+		t.Fatalf("Case.Update() = %v", err)
+	}
+	if mismatches, err = c.Compare(outputs); err != nil || len(mismatches) != 0 {
+		t.Fatalf("Case.Compare() = %v, %v after update, want no mismatches", mismatches, err)
+	}
+
+	writeFiles(t, filepath.Join(c.Dir, golden.GoldenDir), map[string]string{"a.txt": "changed"})
+	mismatches, err = c.Compare(outputs)
+	if err != nil {
+		t.Fatalf("Case.Compare() = %v", err)
+	}
+	wantMismatches := []*golden.Mismatch{{Path: "a.txt", Want: []byte("changed"), Got: want["a.txt"]}}
+	if diff := cmp.Diff(wantMismatches, mismatches); diff != "" {
+		t.Errorf("Case.Compare() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,100 @@
+package job
+
+import (
+	"path"
+	"path/filepath"
+
+	"github.com/friendly-fhir/fhenix/pkg/model"
+	"github.com/friendly-fhir/fhenix/pkg/registry"
+	"github.com/friendly-fhir/fhenix/pkg/transform"
+)
+
+// Context is the data that the templates of a job are executed with. It holds
+// the entities being rendered, along with information about the output, the
+// transform, and the model that they belong to.
+type Context struct {
+	// StructureDefinitions are the types rendered into this output.
+	StructureDefinitions []*model.Type
+
+	// CodeSystems are the code systems rendered into this output.
+	CodeSystems []*model.CodeSystem
+
+	// ValueSets are the value sets rendered into this output.
+	ValueSets []*struct{}
+
+	// Output describes the primary output file of the job.
+	Output *OutputInfo
+
+	// Transform describes the transform that the job belongs to.
+	Transform *TransformInfo
+
+	// Model is the full model, for looking up entities that are not rendered
+	// into this output.
+	Model *model.Model
+
+	// Packages are the packages that are being generated.
+	Packages []registry.PackageRef
+
+	// Vars are the user-defined variables from the config.
+	Vars map[string]any
+
+	root      string
+	transform *transform.Transform
+}
+
+// OutputInfo describes the primary output file of a job.
+type OutputInfo struct {
+	// Path is the absolute path of the output.
+	Path string
+
+	// Rel is the slash-separated path of the output, relative to the output
+	// directory.
+	Rel string
+
+	// Dir is the slash-separated directory of the output, relative to the
+	// output directory.
+	Dir string
+}
+
+// TransformInfo describes the transform that a job belongs to.
+type TransformInfo struct {
+	// Index is the index of the transform in the config.
+	Index int
+
+	// Name is the name of the transform in the config, if it has one.
+	Name string
+}
+
+// Rel returns the slash-separated path to the given path relative to the
+// output directory, from the directory of this output. This enables outputs
+// to reference other generated files, such as with relative imports.
+func (c *Context) Rel(target string) string {
+	rel, err := filepath.Rel(filepath.FromSlash(c.Output.Dir), filepath.FromSlash(target))
+	if err != nil {
+		return target
+	}
+	return filepath.ToSlash(rel)
+}
+
+// PathOf returns the slash-separated path, relative to the output directory,
+// of the output that the given entity is rendered into by this transform.
+func (c *Context) PathOf(entity any) (string, error) {
+	out, err := c.transform.OutputPath(entity)
+	if err != nil {
+		return "", err
+	}
+	return relativeTo(c.root, out), nil
+}
+
+// relativeTo returns the slash-separated path of the output relative to the
+// root, if it is within the root.
+func relativeTo(root, out string) string {
+	if !filepath.IsAbs(out) {
+		return path.Clean(filepath.ToSlash(out))
+	}
+	rel, err := filepath.Rel(root, out)
+	if err != nil {
+		return filepath.ToSlash(out)
+	}
+	return filepath.ToSlash(rel)
+}
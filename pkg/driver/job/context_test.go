@@ -0,0 +1,43 @@
+package job_test
+
+import (
+	"testing"
+
+	"github.com/friendly-fhir/fhenix/pkg/driver/job"
+)
+
+func TestContext_Rel(t *testing.T) {
+	testCases := []struct {
+		name   string
+		dir    string
+		target string
+		want   string
+	}{
+		{
+			name:   "same directory",
+			dir:    "types",
+			target: "types/patient.go",
+			want:   "patient.go",
+		}, {
+			name:   "sibling directory",
+			dir:    "types/resources",
+			target: "codes/gender.go",
+			want:   "../../codes/gender.go",
+		}, {
+			name:   "from output root",
+			dir:    ".",
+			target: "codes/gender.go",
+			want:   "codes/gender.go",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sut := &job.Context{Output: &job.OutputInfo{Dir: tc.dir}}
+
+			if got, want := sut.Rel(tc.target), tc.want; got != want {
+				t.Errorf("Context.Rel(%q) = %q, want %q", tc.target, got, want)
+			}
+		})
+	}
+}
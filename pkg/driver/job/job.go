@@ -5,28 +5,150 @@ jobs that can be executed by the driver in parallel.
 package job
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
+	"sync"
 
+	"github.com/friendly-fhir/fhenix/pkg/driver/manifest"
 	"github.com/friendly-fhir/fhenix/pkg/model"
+	"github.com/friendly-fhir/fhenix/pkg/registry"
 	"github.com/friendly-fhir/fhenix/pkg/transform"
 )
 
+// Status is the outcome of successfully executing a job.
+type Status int
+
+const (
+	// StatusWritten indicates that the output was rendered and written, because
+	// its content differed from the file on disk.
+	StatusWritten Status = iota
+
+	// StatusUnchanged indicates that the output was rendered, but was not
+	// written because its content was identical to the file on disk.
+	StatusUnchanged
+
+	// StatusSkipped indicates that the output was not rendered at all, because
+	// none of its inputs changed since it was last generated.
+	StatusSkipped
+)
+
+var (
+	// ErrInvalidPath is returned when a template emits a file whose path is
+	// absolute, or is outside of the output directory.
+	ErrInvalidPath = errors.New("emitted path is outside of the output directory")
+
+	// ErrCollision is returned when two jobs generate the same file.
+	ErrCollision = errors.New("file is generated by more than one job")
+)
+
+// Option is an option for configuring the jobs created with [New].
+type Option interface {
+	set(*options)
+}
+
+type options struct {
+	previous *manifest.Manifest
+	current  *manifest.Manifest
+	index    int
+	packages []registry.PackageRef
+	claims   *ClaimSet
+}
+
+type option func(*options)
+
+func (o option) set(opts *options) {
+	o(opts)
+}
+
+// Manifests returns an [Option] that enables incremental generation. Jobs
+// whose inputs are unchanged in the previous manifest are skipped, and every
+// successfully executed job is recorded in the current manifest.
+func Manifests(previous, current *manifest.Manifest) Option {
+	return option(func(opts *options) {
+		opts.previous = previous
+		opts.current = current
+	})
+}
+
+// Index returns an [Option] that sets the index of the transform that the jobs
+// belong to, as exposed to templates in the [Context].
+func Index(index int) Option {
+	return option(func(opts *options) {
+		opts.index = index
+	})
+}
+
+// Packages returns an [Option] that sets the packages that are being generated,
+// as exposed to templates in the [Context].
+func Packages(packages ...registry.PackageRef) Option {
+	return option(func(opts *options) {
+		opts.packages = append(opts.packages, packages...)
+	})
+}
+
+// Claims returns an [Option] that makes every job claim the files that it
+// generates before writing them, so that jobs which generate the same file
+// fail with [ErrCollision] rather than racing to write it.
+func Claims(claims *ClaimSet) Option {
+	return option(func(opts *options) {
+		opts.claims = claims
+	})
+}
+
+// ClaimSet records which job generates each file. The zero value is an empty
+// set, which is safe for concurrent use.
+type ClaimSet struct {
+	m      sync.Mutex
+	owners map[string]*Job
+}
+
+// Claim records that the job generates the file at the given path. An error
+// is returned if the file is already claimed by a different job.
+func (c *ClaimSet) Claim(path string, job *Job) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.owners == nil {
+		c.owners = map[string]*Job{}
+	}
+	path = filepath.Clean(path)
+	if owner, ok := c.owners[path]; ok && owner != job {
+		return fmt.Errorf("%w: %s by transform(%d) output %s and transform(%d) output %s",
+			ErrCollision, path, owner.options.index, owner.outputPath, job.options.index, job.outputPath)
+	}
+	c.owners[path] = job
+	return nil
+}
+
 // Job represents a single job that can be executed by the driver.
 type Job struct {
+	root       string
 	outputPath string
-	input      *input
+	data       *Context
 	transform  *transform.Transform
+	options    *options
 }
 
 // New creates a collection of jobs from a given transformation, where each
 // job corresponds to a different output file-path for a set of input
 // matched by the transformation.
-func New(model *model.Model, outputPath string, transform *transform.Transform) ([]*Job, error) {
+func New(model *model.Model, outputPath string, transform *transform.Transform, opts ...Option) ([]*Job, error) {
+	var options options
+	for _, opt := range opts {
+		opt.set(&options)
+	}
+
 	// inputs is a mapping of output file path to the input types that can be
 	// transformed.
-	inputs := map[string]*input{}
+	inputs := map[string]*Context{}
 
 	for _, t := range model.Types().All() {
 		if transform.CanTransform(t) {
@@ -38,7 +160,7 @@ func New(model *model.Model, outputPath string, transform *transform.Transform)
 				out = filepath.Join(filepath.FromSlash(outputPath), out)
 			}
 			if _, ok := inputs[out]; !ok {
-				inputs[out] = &input{}
+				inputs[out] = &Context{}
 			}
 			inputs[out].StructureDefinitions = append(inputs[out].StructureDefinitions, t)
 		}
@@ -49,43 +171,304 @@ func New(model *model.Model, outputPath string, transform *transform.Transform)
 			if err != nil {
 				return nil, err
 			}
+			if !filepath.IsAbs(out) {
+				out = filepath.Join(filepath.FromSlash(outputPath), out)
+			}
 			if _, ok := inputs[out]; !ok {
-				inputs[out] = &input{}
+				inputs[out] = &Context{}
 			}
 			inputs[out].CodeSystems = append(inputs[out].CodeSystems, c)
 		}
 	}
 
+	root := filepath.FromSlash(outputPath)
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	info := &TransformInfo{Index: options.index, Name: transform.Name()}
 	jobs := make([]*Job, 0, len(inputs))
 	for out, in := range inputs {
+		abs, err := filepath.Abs(out)
+		if err != nil {
+			return nil, err
+		}
+		rel := relativeTo(root, abs)
+		in.Output = &OutputInfo{Path: abs, Rel: rel, Dir: path.Dir(rel)}
+		in.Transform = info
+		in.Model = model
+		in.Packages = options.packages
+		in.Vars = transform.Vars()
+		in.root = root
+		in.transform = transform
+
 		jobs = append(jobs, &Job{
+			root:       outputPath,
 			outputPath: out,
-			input:      in,
+			data:       in,
 			transform:  transform,
+			options:    &options,
 		})
 	}
 
 	return jobs, nil
 }
 
-// Execute runs the job and writes the output to the specified file.
-func (j *Job) Execute(ctx context.Context) error {
+// Output is a single file rendered by a job.
+type Output struct {
+	// Path is the path that the output is written to.
+	Path string
+
+	// Content is the rendered content of the output.
+	Content []byte
+}
+
+// rendered is an output along with the content of the file that it replaces.
+type rendered struct {
+	Output
+	existing []byte
+	exists   bool
+}
+
+// Execute runs the job and writes each of its outputs to their files, if the
+// rendered content differs from the existing files.
+func (j *Job) Execute(ctx context.Context) (Status, error) {
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
+		return StatusSkipped, ctx.Err()
 	default:
 		break
 	}
-	if err := os.MkdirAll(filepath.Dir(j.outputPath), 0755); err != nil {
-		return err
+
+	inputs := j.Digest()
+	if j.upToDate(inputs) {
+		// The skipped outputs are still generated by this job, and so may not
+		// be generated by any other.
+		entry, _ := j.options.previous.Lookup(j.outputPath)
+		if err := j.claim(append([]string{j.outputPath}, entry.Emitted...)...); err != nil {
+			return StatusSkipped, err
+		}
+		j.keep()
+		return StatusSkipped, nil
 	}
 
-	file, err := os.Create(j.outputPath)
+	outputs, emitted, err := j.render(ctx)
 	if err != nil {
-		return err
+		return StatusSkipped, err
+	}
+	for _, output := range outputs {
+		if err := j.claim(output.Path); err != nil {
+			return StatusSkipped, err
+		}
+	}
+	status := StatusUnchanged
+	for _, output := range outputs {
+		if output.exists && bytes.Equal(output.existing, output.Content) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(output.Path), 0755); err != nil {
+			return StatusSkipped, err
+		}
+		if err := os.WriteFile(output.Path, output.Content, 0644); err != nil {
+			return StatusSkipped, err
+		}
+		status = StatusWritten
 	}
-	defer file.Close()
-	return j.transform.Execute(file, j.input)
+	j.record(inputs, outputs, emitted)
+	return status, nil
+}
+
+// claim claims every path for the job, if the job has a [ClaimSet].
+func (j *Job) claim(paths ...string) error {
+	if j.options.claims == nil {
+		return nil
+	}
+	for _, path := range paths {
+		if err := j.options.claims.Claim(path, j); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Render renders every output of the job into memory, preserving the
+// protected regions of the existing files and applying the transform's
+// post-processing, without writing them. The primary output is omitted if it
+// is empty and the template emitted other files.
+func (j *Job) Render(ctx context.Context) ([]*Output, error) {
+	outputs, _, err := j.render(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Output, len(outputs))
+	for i, output := range outputs {
+		result[i] = &output.Output
+	}
+	return result, nil
+}
+
+// render renders every output of the job, and also returns the paths of all
+// files that were emitted in addition to the primary output.
+func (j *Job) render(ctx context.Context) ([]*rendered, []string, error) {
+	select {
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	default:
+		break
+	}
+	primary, files, err := j.transform.Render(j.data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: rendering %s: %w", j.outputPath, strings.Join(j.entities(), ", "), err)
+	}
+
+	var outputs []*Output
+	if len(files) == 0 || len(bytes.TrimSpace(primary)) > 0 {
+		outputs = append(outputs, &Output{Path: j.outputPath, Content: primary})
+	}
+	var emitted []string
+	for _, file := range files {
+		// Emitted files are always within the output directory, as with the
+		// archive sinks.
+		name := path.Clean(file.Path)
+		if path.IsAbs(name) || filepath.IsAbs(filepath.FromSlash(file.Path)) || !fs.ValidPath(name) {
+			return nil, nil, fmt.Errorf("%s: %w: %v", j.outputPath, ErrInvalidPath, file.Path)
+		}
+		path := filepath.Join(filepath.FromSlash(j.root), filepath.FromSlash(name))
+		if path == j.outputPath {
+			return nil, nil, fmt.Errorf("%s: file block emits the primary output", j.outputPath)
+		}
+		emitted = append(emitted, path)
+		outputs = append(outputs, &Output{Path: path, Content: file.Content})
+	}
+
+	result := make([]*rendered, 0, len(outputs))
+	for _, output := range outputs {
+		existing, err := os.ReadFile(output.Path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, err
+		}
+		exists := err == nil
+		content, err := j.transform.Protect(output.Path, output.Content, existing)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", output.Path, err)
+		}
+		content, err = j.transform.PostProcess(ctx, output.Path, content)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", output.Path, err)
+		}
+		result = append(result, &rendered{
+			Output:   Output{Path: output.Path, Content: content},
+			existing: existing,
+			exists:   exists,
+		})
+	}
+	return result, emitted, nil
+}
+
+// entities returns the URLs of every input entity of the job, along with the
+// package that defines it if known.
+func (j *Job) entities() []string {
+	result := make([]string, 0, len(j.data.StructureDefinitions)+len(j.data.CodeSystems))
+	for _, t := range j.data.StructureDefinitions {
+		if t.Source == nil || t.Source.Package == "" {
+			result = append(result, t.URL)
+			continue
+		}
+		result = append(result, fmt.Sprintf("%s (%s)", t.URL, t.Source.Package))
+	}
+	for _, c := range j.data.CodeSystems {
+		if c.Package == "" {
+			result = append(result, c.URL)
+			continue
+		}
+		result = append(result, fmt.Sprintf("%s (%s@%s)", c.URL, c.Package, c.Version))
+	}
+	return result
+}
+
+// Digest returns a digest of all the inputs of this job: the transform and its
+// index, the output path, the packages being generated, and the identity and
+// package of every input entity.
+func (j *Job) Digest() string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "transform=%s#%d\noutput=%s\n", j.transform.Digest(), j.options.index, j.outputPath)
+	for _, ref := range j.options.packages {
+		fmt.Fprintf(hash, "package=%s\n", ref)
+	}
+	for _, t := range j.data.StructureDefinitions {
+		if t.Source == nil {
+			fmt.Fprintf(hash, "structure-definition=%s\n", t.URL)
+			continue
+		}
+		fmt.Fprintf(hash, "structure-definition=%s@%s\n", t.URL, t.Source.Package)
+	}
+	for _, c := range j.data.CodeSystems {
+		fmt.Fprintf(hash, "code-system=%s@%s|%s\n", c.URL, c.Package, c.Version)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// upToDate returns true if the previous manifest records that the job was
+// generated from the same inputs, and that none of its outputs have changed
+// on disk since.
+func (j *Job) upToDate(inputs string) bool {
+	if j.options.previous == nil {
+		return false
+	}
+	entry, ok := j.options.previous.Lookup(j.outputPath)
+	if !ok || entry.Inputs != inputs {
+		return false
+	}
+	paths := entry.Emitted
+	if entry.Content != "" {
+		paths = append([]string{j.outputPath}, paths...)
+	}
+	for _, path := range paths {
+		entry, ok := j.options.previous.Lookup(path)
+		if !ok {
+			return false
+		}
+		content, err := os.ReadFile(path)
+		if err != nil || entry.Content != manifest.Digest(content) {
+			return false
+		}
+	}
+	return true
+}
+
+// keep records the previous manifest entries of every output of the job in
+// the current manifest.
+func (j *Job) keep() {
+	if j.options.current == nil {
+		return
+	}
+	entry, _ := j.options.previous.Lookup(j.outputPath)
+	j.options.current.Set(j.outputPath, entry)
+	for _, path := range entry.Emitted {
+		if entry, ok := j.options.previous.Lookup(path); ok {
+			j.options.current.Set(path, entry)
+		}
+	}
+}
+
+// record records every rendered output in the current manifest. The entry of
+// the primary output lists the emitted files, and has no content digest if the
+// primary output itself was not rendered.
+func (j *Job) record(inputs string, outputs []*rendered, emitted []string) {
+	if j.options.current == nil {
+		return
+	}
+	primary := &manifest.Entry{Inputs: inputs, Emitted: emitted}
+	for _, output := range outputs {
+		if output.Path == j.outputPath {
+			primary.Content = manifest.Digest(output.Content)
+			continue
+		}
+		j.options.current.Set(output.Path, &manifest.Entry{
+			Inputs:  inputs,
+			Content: manifest.Digest(output.Content),
+		})
+	}
+	j.options.current.Set(j.outputPath, primary)
 }
 
 // OutputPath returns the output path for the job.
@@ -96,21 +479,15 @@ func (j *Job) OutputPath() string {
 // StructureDefinitions returns the structure definitions that should be
 // transformed by this job.
 func (j *Job) StructureDefinitions() []*model.Type {
-	return j.input.StructureDefinitions
+	return j.data.StructureDefinitions
 }
 
 // CodeSystems returns the code systems that should be transformed by this job.
 func (j *Job) CodeSystems() []*model.CodeSystem {
-	return j.input.CodeSystems
+	return j.data.CodeSystems
 }
 
 // ValueSets returns the value sets that should be transformed by this job.
 func (j *Job) ValueSets() []*struct{} {
-	return j.input.ValueSets
-}
-
-type input struct {
-	StructureDefinitions []*model.Type
-	CodeSystems          []*model.CodeSystem
-	ValueSets            []*struct{}
+	return j.data.ValueSets
 }
@@ -0,0 +1,80 @@
+package job_test
+
+import (
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/friendly-fhir/fhenix/pkg/config"
+	"github.com/friendly-fhir/fhenix/pkg/driver/job"
+	"github.com/friendly-fhir/fhenix/pkg/model"
+	"github.com/friendly-fhir/fhenix/pkg/model/conformance"
+	"github.com/friendly-fhir/fhenix/pkg/registry"
+	"github.com/friendly-fhir/fhenix/pkg/transform"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestJob_Digest_NoSource(t *testing.T) {
+	m := model.NewModel(conformance.NewModule("http://example.com"))
+	m.Types().Add(&model.Type{URL: "http://example.com/StructureDefinition/Synthetic", Name: "Synthetic"})
+	tr, err := transform.New(config.ModeText, &config.Transform{
+		OutputPath: "{{ .Name }}.txt",
+		Templates: map[string]string{
+			"structure-definition": "type.tmpl",
+		},
+	}, transform.WithFS(fstest.MapFS{"type.tmpl": {Data: []byte("{{ .Name }}")}}))
+	if err != nil {
+		t.Fatalf("transform.New() = %v", err)
+	}
+
+	jobs, err := job.New(m, t.TempDir(), tr)
+	if err != nil {
+		t.Fatalf("job.New() = %v", err)
+	}
+
+	if got, want := len(jobs), 1; got != want {
+		t.Fatalf("job.New() = %d jobs, want %d", got, want)
+	}
+	if got := jobs[0].Digest(); got == "" {
+		t.Errorf("Job.Digest() = %q, want non-empty", got)
+	}
+}
+
+func TestNew_CodeSystemsNotTransformed(t *testing.T) {
+	module := conformance.NewModule("http://example.com")
+	err := module.ParseJSON([]byte(`{
+		"resourceType": "CodeSystem",
+		"url": "http://example.com/CodeSystem/colors",
+		"name": "Colors",
+		"status": "active",
+		"content": "complete",
+		"concept": [{"code": "red"}]
+	}`), registry.NewPackageRef(registry.Default, "example", "1.0.0"))
+	if err != nil {
+		t.Fatalf("Module.ParseJSON() = %v", err)
+	}
+	m := model.NewModel(module)
+	m.Types().Add(&model.Type{URL: "http://example.com/StructureDefinition/Synthetic", Name: "Synthetic"})
+	tr, err := transform.New(config.ModeText, &config.Transform{
+		OutputPath: "{{ .Name }}.txt",
+		Templates: map[string]string{
+			"structure-definition": "type.tmpl",
+		},
+	}, transform.WithFS(fstest.MapFS{"type.tmpl": {Data: []byte("{{ .Name }}")}}))
+	if err != nil {
+		t.Fatalf("transform.New() = %v", err)
+	}
+
+	jobs, err := job.New(m, t.TempDir(), tr)
+	if err != nil {
+		t.Fatalf("job.New() = %v", err)
+	}
+
+	var got []string
+	for _, j := range jobs {
+		got = append(got, filepath.Base(j.OutputPath()))
+	}
+	if want := []string{"Synthetic.txt"}; !cmp.Equal(got, want) {
+		t.Errorf("job.New() outputs = %v, want %v", got, want)
+	}
+}
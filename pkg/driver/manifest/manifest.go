@@ -0,0 +1,205 @@
+/*
+Package manifest provides the generation manifest, which records the inputs
+and outputs of a generation run in the output directory. This enables
+subsequent runs to skip jobs whose inputs have not changed.
+*/
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// FileName is the name of the manifest file written to the output directory.
+const FileName = ".fhenix-manifest.json"
+
+// version is the schema version of the manifest file. Manifests of a different
+// version are ignored.
+const version = 1
+
+// Entry is the manifest record of a single generated output file.
+type Entry struct {
+	// Inputs is the digest of all inputs used to render the output.
+	Inputs string `json:"inputs"`
+
+	// Content is the digest of the rendered output content. It is empty if the
+	// output was not written, because its render only emitted other files.
+	Content string `json:"content"`
+
+	// Emitted are the paths of the files that were emitted by the render of
+	// this output, in addition to the output itself.
+	Emitted []string `json:"emitted,omitempty"`
+}
+
+// Manifest is a record of generated outputs, keyed by the output path.
+//
+// Manifest is safe for concurrent use.
+type Manifest struct {
+	m sync.Mutex
+
+	dir     string
+	outputs map[string]*Entry
+}
+
+type manifestJSON struct {
+	Version   int               `json:"version"`
+	Generator string            `json:"generator"`
+	Outputs   map[string]*Entry `json:"outputs"`
+}
+
+// New creates a new empty manifest for the given output directory.
+func New(dir string) *Manifest {
+	return &Manifest{
+		dir:     dir,
+		outputs: map[string]*Entry{},
+	}
+}
+
+// Load reads the manifest from the given output directory. If no manifest
+// exists, or if it has a different schema version, an empty manifest is
+// returned. If it was written by a different version of the generator, the
+// input digests are discarded so that no output is considered up to date, but
+// the output paths are kept so that stale outputs may still be pruned.
+func Load(dir string) (*Manifest, error) {
+	result := New(dir)
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var content manifestJSON
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, err
+	}
+	if content.Version != version {
+		return result, nil
+	}
+	current := content.Generator == generator()
+	for path, entry := range content.Outputs {
+		if entry == nil {
+			continue
+		}
+		if !current {
+			entry.Inputs = ""
+		}
+		result.outputs[path] = entry
+	}
+	return result, nil
+}
+
+// Save writes the manifest to its output directory.
+func (m *Manifest) Save() error {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	data, err := json.MarshalIndent(&manifestJSON{
+		Version:   version,
+		Generator: generator(),
+		Outputs:   m.outputs,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.dir, FileName), data, 0644)
+}
+
+// Lookup returns the entry recorded for the given output path.
+func (m *Manifest) Lookup(path string) (*Entry, bool) {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	entry, ok := m.outputs[m.key(path)]
+	if !ok || len(entry.Emitted) == 0 {
+		return entry, ok
+	}
+	result := *entry
+	result.Emitted = make([]string, len(entry.Emitted))
+	for i, key := range entry.Emitted {
+		result.Emitted[i] = m.path(key)
+	}
+	return &result, true
+}
+
+// Set records the entry for the given output path.
+func (m *Manifest) Set(path string, entry *Entry) {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	if len(entry.Emitted) > 0 {
+		stored := *entry
+		stored.Emitted = make([]string, len(entry.Emitted))
+		for i, path := range entry.Emitted {
+			stored.Emitted[i] = m.key(path)
+		}
+		entry = &stored
+	}
+	m.outputs[m.key(path)] = entry
+}
+
+// Paths returns the sorted output paths recorded in the manifest.
+func (m *Manifest) Paths() []string {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	paths := make([]string, 0, len(m.outputs))
+	for key := range m.outputs {
+		paths = append(paths, m.path(key))
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// key returns the manifest key for the given path. Paths within the output
+// directory are stored relative to it, so that the directory may be moved.
+func (m *Manifest) key(path string) string {
+	rel, err := filepath.Rel(m.dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (m *Manifest) path(key string) string {
+	path := filepath.FromSlash(key)
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(m.dir, path)
+}
+
+// Digest returns the hex-encoded SHA-256 digest of the given content.
+func Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// generator returns an identifier of the running generator build, so that
+// outputs are regenerated when the generator itself changes.
+func generator() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	id := info.Main.Version
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			id += "+" + setting.Value
+		}
+	}
+	return id
+}
@@ -0,0 +1,128 @@
+package manifest_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/friendly-fhir/fhenix/pkg/driver/manifest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoad_NoManifest(t *testing.T) {
+	dir := t.TempDir()
+
+	got, err := manifest.Load(dir)
+
+	if err != nil {
+		t.Fatalf("Load() = %v, want nil", err)
+	}
+	if paths := got.Paths(); len(paths) != 0 {
+		t.Errorf("Load().Paths() = %v, want empty", paths)
+	}
+}
+
+func TestManifest_SaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	inside := filepath.Join(dir, "sub", "file.go")
+	outside := filepath.Join(t.TempDir(), "other.go")
+	entry := &manifest.Entry{Inputs: "inputs", Content: manifest.Digest([]byte("content"))}
+	sut := manifest.New(dir)
+	sut.Set(inside, entry)
+	sut.Set(outside, entry)
+
+	if err := sut.Save(); err != nil {
+		t.Fatalf("Manifest.Save() = %v, want nil", err)
+	}
+	got, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatalf("Load() = %v, want nil", err)
+	}
+
+	for _, path := range []string{inside, outside} {
+		if got, ok := got.Lookup(path); !ok || !cmp.Equal(got, entry) {
+			t.Errorf("Manifest.Lookup(%q) = %v, %v; want %v, true", path, got, ok, entry)
+		}
+	}
+}
+
+func TestManifest_Save_RelativeKeys(t *testing.T) {
+	dir := t.TempDir()
+	sut := manifest.New(dir)
+	sut.Set(filepath.Join(dir, "sub", "file.go"), &manifest.Entry{})
+	if err := sut.Save(); err != nil {
+		t.Fatalf("Manifest.Save() = %v, want nil", err)
+	}
+
+	moved := filepath.Join(t.TempDir(), "moved")
+	if err := os.Rename(dir, moved); err != nil {
+		t.Fatalf("os.Rename() = %v", err)
+	}
+	got, err := manifest.Load(moved)
+	if err != nil {
+		t.Fatalf("Load() = %v, want nil", err)
+	}
+
+	want := []string{filepath.Join(moved, "sub", "file.go")}
+	if got := got.Paths(); !cmp.Equal(got, want) {
+		t.Errorf("Manifest.Paths() = %v, want %v", got, want)
+	}
+}
+
+func TestLoad_DifferentGenerator(t *testing.T) {
+	dir := t.TempDir()
+	data, err := json.Marshal(map[string]any{
+		"version":   1,
+		"generator": "some other generator",
+		"outputs": map[string]any{
+			"file.go": map[string]string{"inputs": "inputs", "content": "content"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("json.Marshal() = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifest.FileName), data, 0644); err != nil {
+		t.Fatalf("os.WriteFile() = %v", err)
+	}
+
+	got, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatalf("Load() = %v, want nil", err)
+	}
+
+	path := filepath.Join(dir, "file.go")
+	want := &manifest.Entry{Inputs: "", Content: "content"}
+	if got, ok := got.Lookup(path); !ok || !cmp.Equal(got, want) {
+		t.Errorf("Manifest.Lookup(%q) = %v, %v; want %v, true", path, got, ok, want)
+	}
+}
+
+func TestManifest_Emitted(t *testing.T) {
+	dir := t.TempDir()
+	primary := filepath.Join(dir, "primary.h")
+	emitted := []string{filepath.Join(dir, "sub", "impl.cc"), filepath.Join(t.TempDir(), "outside.cc")}
+	sut := manifest.New(dir)
+	sut.Set(primary, &manifest.Entry{Inputs: "inputs", Emitted: emitted})
+	if err := sut.Save(); err != nil {
+		t.Fatalf("Manifest.Save() = %v, want nil", err)
+	}
+
+	moved := filepath.Join(t.TempDir(), "moved")
+	if err := os.Rename(dir, moved); err != nil {
+		t.Fatalf("os.Rename() = %v", err)
+	}
+	got, err := manifest.Load(moved)
+	if err != nil {
+		t.Fatalf("Load() = %v, want nil", err)
+	}
+
+	path := filepath.Join(moved, "primary.h")
+	want := &manifest.Entry{
+		Inputs:  "inputs",
+		Emitted: []string{filepath.Join(moved, "sub", "impl.cc"), emitted[1]},
+	}
+	if got, ok := got.Lookup(path); !ok || !cmp.Equal(got, want) {
+		t.Errorf("Manifest.Lookup(%q) = %v, %v; want %v, true", path, got, ok, want)
+	}
+}
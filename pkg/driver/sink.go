@@ -0,0 +1,119 @@
+package driver
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+
+	"github.com/friendly-fhir/fhenix/internal/task"
+	"github.com/friendly-fhir/fhenix/pkg/driver/job"
+	"github.com/friendly-fhir/fhenix/pkg/model"
+	"github.com/friendly-fhir/fhenix/pkg/transform"
+)
+
+// OutputSink receives the files generated by the [Driver] in place of the
+// output directory, such as to keep them in memory or write them to an archive.
+type OutputSink interface {
+	// WriteOutput writes a single generated file. The path is slash-separated
+	// and relative to the output directory of the driver. This is never called
+	// concurrently.
+	WriteOutput(path string, content []byte) error
+}
+
+// OutputSinkFunc is an [OutputSink] implemented by a function.
+type OutputSinkFunc func(path string, content []byte) error
+
+// WriteOutput calls the function.
+func (f OutputSinkFunc) WriteOutput(path string, content []byte) error {
+	return f(path, content)
+}
+
+var _ OutputSink = (*OutputSinkFunc)(nil)
+
+// transformToSink renders every output of the transforms and writes them to
+// the sink. Since the sink does not hold the previous outputs, every output is
+// rendered and written, and there is neither a manifest nor pruning. Protected
+// regions are still preserved from the files in the output directory, if any.
+func (d *Driver) transformToSink(ctx context.Context, model *model.Model, transforms []*transform.Transform) error {
+	for _, listener := range d.listeners {
+		listener.BeforeStage(StageTransform)
+	}
+	plan, err := d.Plan(model, transforms)
+	if err != nil {
+		for _, listener := range d.listeners {
+			listener.AfterStage(StageTransform, err)
+		}
+		return err
+	}
+
+	claims := &job.ClaimSet{}
+	for _, jobs := range plan {
+		for _, j := range jobs {
+			if err := claims.Claim(j.OutputPath(), j); err != nil {
+				for _, listener := range d.listeners {
+					listener.AfterStage(StageTransform, err)
+				}
+				return err
+			}
+		}
+	}
+
+	var m sync.Mutex
+	stats := make([]transformCounters, len(transforms))
+	runner := task.NewRunner(d.parallel)
+	for i, jobs := range plan {
+		for _, listener := range d.listeners {
+			listener.BeforeTransform(i, len(jobs))
+		}
+		for _, j := range jobs {
+			runner.Add(task.Func(func(ctx context.Context) error {
+				for _, listener := range d.listeners {
+					listener.OnTransformOutput(i, j.OutputPath())
+				}
+
+				outputs, err := j.Render(ctx)
+				for _, output := range outputs {
+					if err = claims.Claim(output.Path, j); err != nil {
+						break
+					}
+				}
+				if err == nil {
+					m.Lock()
+					for _, output := range outputs {
+						if err = d.sink.WriteOutput(d.relative(output.Path), output.Content); err != nil {
+							break
+						}
+					}
+					m.Unlock()
+				}
+				stats[i].add(job.StatusWritten, err)
+
+				for _, listener := range d.listeners {
+					listener.AfterTransformOutput(i, j.OutputPath(), err)
+				}
+				return err
+			}))
+		}
+	}
+
+	_, err = runner.Run(ctx)
+	for i := range stats {
+		for _, listener := range d.listeners {
+			listener.AfterTransform(i, stats[i].stats())
+		}
+	}
+	for _, listener := range d.listeners {
+		listener.AfterStage(StageTransform, err)
+	}
+	return err
+}
+
+// relative returns the slash-separated path of the output relative to the
+// output directory.
+func (d *Driver) relative(path string) string {
+	rel, err := filepath.Rel(filepath.Clean(d.outputPath), path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,254 @@
+/*
+Package sink provides implementations of [driver.OutputSink], for writing the
+outputs of the driver to a directory, to memory, to an archive, or to a single
+stream.
+
+Sinks that produce a single artifact buffer every output until they are
+closed, and then write the outputs sorted by path, so that the artifact is
+reproducible regardless of the order in which outputs were generated.
+*/
+package sink
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/friendly-fhir/fhenix/pkg/driver"
+)
+
+// ErrInvalidPath is returned when an output path is absolute, or is outside of
+// the output directory, and so cannot be written to a directory or archive.
+var ErrInvalidPath = errors.New("output path is outside of the output directory")
+
+// modTime is the modification time of every archived file, which is fixed so
+// that archives are reproducible. This is the earliest time a zip supports.
+var modTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Dir is an [driver.OutputSink] that writes outputs to files in a directory.
+// Unlike the output directory of the driver, no generation manifest is kept.
+type Dir string
+
+// WriteOutput writes the content to the path within the directory, creating
+// any parent directories. The path must be relative to the directory.
+func (d Dir) WriteOutput(name string, content []byte) error {
+	name, err := clean(name)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(string(d), filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0644)
+}
+
+// Memory is an [driver.OutputSink] that keeps outputs in memory, which is
+// useful for tests. The zero value is an empty sink, which is safe for
+// concurrent use.
+type Memory struct {
+	m     sync.Mutex
+	files map[string][]byte
+}
+
+// WriteOutput records the content of the output, replacing any previous
+// content at the same path.
+func (m *Memory) WriteOutput(path string, content []byte) error {
+	m.m.Lock()
+	defer m.m.Unlock()
+	if m.files == nil {
+		m.files = map[string][]byte{}
+	}
+	m.files[path] = slices.Clone(content)
+	return nil
+}
+
+// Paths returns the sorted paths of every output.
+func (m *Memory) Paths() []string {
+	m.m.Lock()
+	defer m.m.Unlock()
+	paths := make([]string, 0, len(m.files))
+	for path := range m.files {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// File returns the content of the output at the given path.
+func (m *Memory) File(path string) ([]byte, bool) {
+	m.m.Lock()
+	defer m.m.Unlock()
+	content, ok := m.files[path]
+	return content, ok
+}
+
+// Files returns a copy of every output, by path.
+func (m *Memory) Files() map[string][]byte {
+	m.m.Lock()
+	defer m.m.Unlock()
+	return maps.Clone(m.files)
+}
+
+// archive buffers outputs for sinks that write a single artifact on close.
+type archive struct {
+	Memory
+	w      io.Writer
+	closed bool
+}
+
+// WriteOutput buffers the output, which must be relative to the output
+// directory.
+func (a *archive) WriteOutput(name string, content []byte) error {
+	name, err := clean(name)
+	if err != nil {
+		return err
+	}
+	return a.Memory.WriteOutput(name, content)
+}
+
+// clean returns the cleaned output path, or an error if it is absolute or
+// outside of the output directory.
+func clean(name string) (string, error) {
+	result := path.Clean(name)
+	if path.IsAbs(result) || filepath.IsAbs(filepath.FromSlash(name)) || !fs.ValidPath(result) {
+		return "", fmt.Errorf("%w: %v", ErrInvalidPath, name)
+	}
+	return result, nil
+}
+
+// close calls the function with each output sorted by path, the first time
+// that it is called.
+func (a *archive) close(fn func(path string, content []byte) error) error {
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	for _, path := range a.Paths() {
+		content, _ := a.File(path)
+		if err := fn(path, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tarball is an [driver.OutputSink] that writes outputs to a gzipped tarball.
+// The tarball is only written when the sink is closed.
+type Tarball struct {
+	archive
+}
+
+// NewTarball creates a sink that writes a gzipped tarball to the writer.
+func NewTarball(w io.Writer) *Tarball {
+	return &Tarball{archive: archive{w: w}}
+}
+
+// Close writes the tarball. This does not close the underlying writer.
+func (t *Tarball) Close() error {
+	if t.closed {
+		return nil
+	}
+	gz := gzip.NewWriter(t.w)
+	tw := tar.NewWriter(gz)
+	err := t.close(func(path string, content []byte) error {
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     path,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			ModTime:  modTime,
+			Format:   tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(content)
+		return err
+	})
+	return errors.Join(err, tw.Close(), gz.Close())
+}
+
+// Zip is an [driver.OutputSink] that writes outputs to a zip archive. The
+// archive is only written when the sink is closed.
+type Zip struct {
+	archive
+}
+
+// NewZip creates a sink that writes a zip archive to the writer.
+func NewZip(w io.Writer) *Zip {
+	return &Zip{archive: archive{w: w}}
+}
+
+// Close writes the zip archive. This does not close the underlying writer.
+func (z *Zip) Close() error {
+	if z.closed {
+		return nil
+	}
+	zw := zip.NewWriter(z.w)
+	err := z.close(func(path string, content []byte) error {
+		w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     path,
+			Method:   zip.Deflate,
+			Modified: modTime,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(content)
+		return err
+	})
+	return errors.Join(err, zw.Close())
+}
+
+// Stream is an [driver.OutputSink] that writes every output to a single
+// stream, in the txtar format: each output is preceded by a line containing
+// its path, as "-- path --". The stream is only written when the sink is
+// closed.
+type Stream struct {
+	archive
+}
+
+// NewStream creates a sink that writes every output to the writer.
+func NewStream(w io.Writer) *Stream {
+	return &Stream{archive: archive{w: w}}
+}
+
+// Close writes every output to the stream. This does not close the underlying
+// writer.
+func (s *Stream) Close() error {
+	return s.close(func(path string, content []byte) error {
+		if _, err := fmt.Fprintf(s.w, "-- %s --\n", path); err != nil {
+			return err
+		}
+		if _, err := s.w.Write(content); err != nil {
+			return err
+		}
+		// Each output must end in a newline so that the next header is on a
+		// line of its own.
+		if len(content) > 0 && content[len(content)-1] != '\n' {
+			_, err := io.WriteString(s.w, "\n")
+			return err
+		}
+		return nil
+	})
+}
+
+var (
+	_ driver.OutputSink = (*Dir)(nil)
+	_ driver.OutputSink = (*Memory)(nil)
+	_ driver.OutputSink = (*Tarball)(nil)
+	_ driver.OutputSink = (*Zip)(nil)
+	_ driver.OutputSink = (*Stream)(nil)
+)
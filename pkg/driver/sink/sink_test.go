@@ -0,0 +1,256 @@
+package sink_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/friendly-fhir/fhenix/pkg/driver"
+	"github.com/friendly-fhir/fhenix/pkg/driver/sink"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// outputs are written out of order, as they would be by parallel jobs.
+var outputs = []struct {
+	path    string
+	content string
+}{
+	{"types/patient.go", "package types\n"},
+	{"README.md", "# Generated"},
+	{"codes/gender.go", "package codes\n"},
+}
+
+var want = map[string]string{
+	"README.md":        "# Generated",
+	"codes/gender.go":  "package codes\n",
+	"types/patient.go": "package types\n",
+}
+
+type outputSink interface {
+	WriteOutput(path string, content []byte) error
+}
+
+type closingSink interface {
+	outputSink
+	io.Closer
+}
+
+func write(t *testing.T, sink outputSink) {
+	t.Helper()
+	for _, output := range outputs {
+		if err := sink.WriteOutput(output.path, []byte(output.content)); err != nil {
+			t.Fatalf("WriteOutput(%q) = %v", output.path, err)
+		}
+	}
+}
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+
+	write(t, sink.Dir(dir))
+
+	for path, content := range want {
+		got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil {
+			t.Fatalf("ReadFile(%q) = %v", path, err)
+		}
+		if got, want := string(got), content; got != want {
+			t.Errorf("ReadFile(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	var sut sink.Memory
+
+	write(t, &sut)
+
+	if got, want := sut.Paths(), []string{"README.md", "codes/gender.go", "types/patient.go"}; !cmp.Equal(got, want) {
+		t.Errorf("Memory.Paths() = %v, want %v", got, want)
+	}
+	got := map[string]string{}
+	for path, content := range sut.Files() {
+		got[path] = string(content)
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Memory.Files() (-got +want)\n%s", cmp.Diff(got, want))
+	}
+}
+
+func TestTarball(t *testing.T) {
+	var buf bytes.Buffer
+	sut := sink.NewTarball(&buf)
+
+	write(t, sut)
+	if err := sut.Close(); err != nil {
+		t.Fatalf("Tarball.Close() = %v", err)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("gzip.NewReader() = %v", err)
+	}
+	reader := tar.NewReader(gz)
+	var paths []string
+	got := map[string]string{}
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar.Reader.Next() = %v", err)
+		}
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("ReadAll() = %v", err)
+		}
+		paths = append(paths, header.Name)
+		got[header.Name] = string(content)
+	}
+
+	if want := []string{"README.md", "codes/gender.go", "types/patient.go"}; !cmp.Equal(paths, want) {
+		t.Errorf("Tarball paths = %v, want %v", paths, want)
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Tarball contents (-got +want)\n%s", cmp.Diff(got, want))
+	}
+}
+
+func TestZip(t *testing.T) {
+	var buf bytes.Buffer
+	sut := sink.NewZip(&buf)
+
+	write(t, sut)
+	if err := sut.Close(); err != nil {
+		t.Fatalf("Zip.Close() = %v", err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader() = %v", err)
+	}
+	var paths []string
+	got := map[string]string{}
+	for _, file := range reader.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatalf("zip.File.Open() = %v", err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("ReadAll() = %v", err)
+		}
+		paths = append(paths, file.Name)
+		got[file.Name] = string(content)
+	}
+
+	if want := []string{"README.md", "codes/gender.go", "types/patient.go"}; !cmp.Equal(paths, want) {
+		t.Errorf("Zip paths = %v, want %v", paths, want)
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Zip contents (-got +want)\n%s", cmp.Diff(got, want))
+	}
+}
+
+func TestStream(t *testing.T) {
+	var buf bytes.Buffer
+	sut := sink.NewStream(&buf)
+
+	write(t, sut)
+	if err := sut.Close(); err != nil {
+		t.Fatalf("Stream.Close() = %v", err)
+	}
+
+	want := "-- README.md --\n# Generated\n" +
+		"-- codes/gender.go --\npackage codes\n" +
+		"-- types/patient.go --\npackage types\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Stream = %q, want %q", got, want)
+	}
+}
+
+func TestSink_CloseTwice(t *testing.T) {
+	testCases := []struct {
+		name string
+		sink func(io.Writer) closingSink
+	}{
+		{
+			name: "tarball",
+			sink: func(w io.Writer) closingSink {
+				return sink.NewTarball(w)
+			},
+		}, {
+			name: "zip",
+			sink: func(w io.Writer) closingSink {
+				return sink.NewZip(w)
+			},
+		}, {
+			name: "stream",
+			sink: func(w io.Writer) closingSink {
+				return sink.NewStream(w)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			sut := tc.sink(&buf)
+			write(t, sut)
+			if err := sut.Close(); err != nil {
+				t.Fatalf("Close() = %v", err)
+			}
+			want := bytes.Clone(buf.Bytes())
+
+			if err := sut.Close(); err != nil {
+				t.Fatalf("Close() = %v on second call", err)
+			}
+
+			if got := buf.Bytes(); !bytes.Equal(got, want) {
+				t.Errorf("Close() wrote %d more bytes on second call", len(got)-len(want))
+			}
+		})
+	}
+}
+
+func TestSink_InvalidPath(t *testing.T) {
+	sinks := []struct {
+		name string
+		sink func(t *testing.T) driver.OutputSink
+	}{
+		{"dir", func(t *testing.T) driver.OutputSink { return sink.Dir(t.TempDir()) }},
+		{"tarball", func(*testing.T) driver.OutputSink { return sink.NewTarball(io.Discard) }},
+		{"zip", func(*testing.T) driver.OutputSink { return sink.NewZip(io.Discard) }},
+		{"stream", func(*testing.T) driver.OutputSink { return sink.NewStream(io.Discard) }},
+	}
+	testCases := []struct {
+		name string
+		path string
+	}{
+		{"absolute", "/etc/passwd"},
+		{"outside of output directory", "../sibling/file.go"},
+		{"escapes after cleaning", "types/../../sibling/file.go"},
+	}
+
+	for _, s := range sinks {
+		for _, tc := range testCases {
+			t.Run(s.name+"/"+tc.name, func(t *testing.T) {
+				sut := s.sink(t)
+
+				err := sut.WriteOutput(tc.path, nil)
+
+				if got, want := err, sink.ErrInvalidPath; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+					t.Errorf("WriteOutput(%q) = %v, want %v", tc.path, got, want)
+				}
+			})
+		}
+	}
+}
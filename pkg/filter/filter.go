@@ -6,6 +6,8 @@ base definition.
 package filter
 
 import (
+	"errors"
+	"fmt"
 	"html/template"
 	"path/filepath"
 	"regexp"
@@ -15,18 +17,108 @@ import (
 	"github.com/friendly-fhir/fhenix/internal/templatefuncs"
 	"github.com/friendly-fhir/fhenix/pkg/config"
 	"github.com/friendly-fhir/fhenix/pkg/model"
+	"github.com/friendly-fhir/fhenix/pkg/model/query"
 )
 
+// ErrUnsupported is an error reported by [Filter.Explain] for entities that
+// filters cannot match, such as code systems.
+var ErrUnsupported = errors.New("filters only match structure definitions")
+
 // Filter represents a filter that can be applied to a set of definitions.
 type Filter struct {
-	config *config.TransformFilter
+	config  *config.TransformFilter
+	clauses []*clause
+	query   *query.Query
+	err     error
+	vars    map[string]any
+}
+
+// clause is a single key of a filter, which a type must satisfy for the
+// filter to match.
+type clause struct {
+	key   string
+	value string
+	match func(f *Filter, t *model.Type) (bool, error)
 }
 
-// New creates a new filter from the given configuration.
+// New creates a new filter from the given configuration. The query of the
+// filter is compiled once here; an invalid query matches nothing, although
+// queries are already verified when the config is loaded.
 func newFilter(cfg *config.TransformFilter) *Filter {
-	return &Filter{
+	result := &Filter{
 		config: cfg,
 	}
+	if cfg != nil && strings.TrimSpace(cfg.Query) != "" {
+		result.query, result.err = query.Compile(cfg.Query)
+	}
+	if cfg != nil {
+		result.clauses = clausesOf(cfg)
+	}
+	return result
+}
+
+// clausesOf returns a clause for every key that is set in the config, in the
+// order that they are evaluated.
+func clausesOf(cfg *config.TransformFilter) []*clause {
+	var result []*clause
+	add := func(key, value string, match func(f *Filter, t *model.Type) (bool, error)) {
+		if value != "" {
+			result = append(result, &clause{key: key, value: value, match: match})
+		}
+	}
+	add("type", cfg.Type, func(f *Filter, t *model.Type) (bool, error) {
+		return cfg.Type == "StructureDefinition", nil
+	})
+	add("name", cfg.Name, func(f *Filter, t *model.Type) (bool, error) {
+		return f.match(cfg.Name, t.Name), nil
+	})
+	add("source", cfg.Source, func(f *Filter, t *model.Type) (bool, error) {
+		return t.Source != nil && f.match(cfg.Source, filepath.Base(t.Source.File)), nil
+	})
+	add("package", cfg.Package, func(f *Filter, t *model.Type) (bool, error) {
+		return t.Source != nil && cfg.Package == t.Source.Package.Name(), nil
+	})
+	add("package-version", cfg.PackageVersion, func(f *Filter, t *model.Type) (bool, error) {
+		return t.Source != nil && cfg.PackageVersion == t.Source.Package.Version(), nil
+	})
+	add("url", cfg.URL, func(f *Filter, t *model.Type) (bool, error) {
+		return cfg.URL == t.URL, nil
+	})
+	add("kind", cfg.Kind, func(f *Filter, t *model.Type) (bool, error) {
+		return cfg.Kind == string(t.Kind), nil
+	})
+	add("derivation", cfg.Derivation, func(f *Filter, t *model.Type) (bool, error) {
+		return cfg.Derivation == t.Derivation(), nil
+	})
+	if cfg.Abstract != nil {
+		add("abstract", strconv.FormatBool(*cfg.Abstract), func(f *Filter, t *model.Type) (bool, error) {
+			return *cfg.Abstract == t.IsAbstract, nil
+		})
+	}
+	add("base", cfg.Base, func(f *Filter, t *model.Type) (bool, error) {
+		return t.DerivesFrom(cfg.Base), nil
+	})
+	add("status", cfg.Status, func(f *Filter, t *model.Type) (bool, error) {
+		return cfg.Status == t.Status(), nil
+	})
+	if cfg.Experimental != nil {
+		add("experimental", strconv.FormatBool(*cfg.Experimental), func(f *Filter, t *model.Type) (bool, error) {
+			return *cfg.Experimental == t.IsExperimental(), nil
+		})
+	}
+	add("publisher", cfg.Publisher, func(f *Filter, t *model.Type) (bool, error) {
+		return f.match(cfg.Publisher, t.Publisher()), nil
+	})
+	add("condition", cfg.Condition, func(f *Filter, t *model.Type) (bool, error) {
+		return f.evaluateTemplate(cfg.Condition, t)
+	})
+	add("query", strings.TrimSpace(cfg.Query), func(f *Filter, t *model.Type) (bool, error) {
+		if f.err != nil {
+			return false, f.err
+		}
+		return f.query.Matches(t, f.vars)
+	})
+	return result
 }
 
 // Matches returns true if the given value matches the filter.
@@ -42,28 +134,68 @@ var zero config.TransformFilter
 
 // MatchesType returns true if the given type matches the filter.
 func (f *Filter) MatchesType(t *model.Type) bool {
-	if t == nil || f.config == nil {
-		return false
-	}
-	if tp := f.config.Type; tp != "" && tp != "StructureDefinition" {
-		return false
-	}
-	if name := f.config.Name; name != "" && !f.match(name, t.Name) {
+	if t == nil || f.config == nil || f.err != nil {
 		return false
 	}
-	if source := f.config.Source; source != "" && !f.match(source, filepath.Base(t.Source.File)) {
-		return false
+	for _, c := range f.clauses {
+		if ok, err := c.match(f, t); !ok || err != nil {
+			return false
+		}
 	}
-	if pkg := f.config.Package; pkg != "" && pkg != t.Source.Package.Name() {
-		return false
+	return *f.config != zero
+}
+
+// Clause is the outcome of a single key of a filter.
+type Clause struct {
+	// Key is the name of the key in the config, such as 'name' or 'condition'.
+	Key string
+
+	// Value is the configured value of the key.
+	Value string
+
+	// Matched is true if the entity satisfies the key.
+	Matched bool
+
+	// Err is the error that evaluating the key failed with, if any, such as an
+	// error executing a condition template. A key that fails never matches.
+	Err error
+}
+
+// Explanation explains whether a filter matches an entity.
+type Explanation struct {
+	// Clauses are the outcomes of every key that is set in the filter. Unlike
+	// matching, every key is evaluated, even after one has failed.
+	Clauses []*Clause
+
+	// Matched is true if the filter matches the entity. An empty filter matches
+	// nothing.
+	Matched bool
+}
+
+// Explain evaluates every key of the filter against the given value, and
+// returns an explanation of whether the filter matches it.
+func (f *Filter) Explain(v any) *Explanation {
+	result := &Explanation{}
+	if f.config == nil {
+		return result
 	}
-	if url := f.config.URL; url != "" && url != t.URL {
-		return false
+	t, ok := v.(*model.Type)
+	if !ok || t == nil {
+		result.Clauses = append(result.Clauses, &Clause{
+			Key:   "type",
+			Value: f.config.Type,
+			Err:   fmt.Errorf("%w: got %T", ErrUnsupported, v),
+		})
+		return result
 	}
-	if condition := f.config.Condition; condition != "" && !f.evaluateTemplate(condition, t) {
-		return false
+	result.Matched = *f.config != zero
+	for _, c := range f.clauses {
+		ok, err := c.match(f, t)
+		ok = ok && err == nil
+		result.Clauses = append(result.Clauses, &Clause{Key: c.key, Value: c.value, Matched: ok, Err: err})
+		result.Matched = result.Matched && ok
 	}
-	return *f.config != zero
+	return result
 }
 
 func (f *Filter) match(regex, needle string) bool {
@@ -71,21 +203,23 @@ func (f *Filter) match(regex, needle string) bool {
 	return err == nil && got
 }
 
-func (f *Filter) evaluateTemplate(condition string, v any) bool {
-	tmpl := template.New("").Funcs(templatefuncs.NewFuncs(nil))
+// evaluateTemplate evaluates the condition template against the value. Output
+// that is not a bool is considered to match.
+func (f *Filter) evaluateTemplate(condition string, v any) (bool, error) {
+	tmpl := template.New("condition").Funcs(templatefuncs.NewFuncs(nil)).Funcs(templatefuncs.NewVarsFuncs(nil, f.vars))
 	_, err := tmpl.Parse(strings.TrimSpace(condition))
 	if err != nil {
-		return false
+		return false, err
 	}
 
 	var sb strings.Builder
 	err = tmpl.Execute(&sb, v)
 	if err != nil {
-		return false
+		return false, err
 	}
 
 	b, err := strconv.ParseBool(sb.String())
-	return b || err != nil
+	return b || err != nil, nil
 }
 
 // Filters represents a set of filters.
@@ -100,6 +234,16 @@ func New(cfgs ...*config.TransformFilter) Filters {
 	return filters
 }
 
+// WithVars returns the filters with the given user-defined variables available
+// to their conditions.
+func (f Filters) WithVars(vars map[string]any) Filters {
+	result := make(Filters, len(f))
+	for i, filter := range f {
+		result[i] = &Filter{config: filter.config, clauses: filter.clauses, query: filter.query, err: filter.err, vars: vars}
+	}
+	return result
+}
+
 // Matches returns true if the given value matches any of the filters.
 func (f Filters) Matches(v any) bool {
 	for _, filter := range f {
@@ -110,6 +254,15 @@ func (f Filters) Matches(v any) bool {
 	return false
 }
 
+// Explain explains whether each of the filters matches the given value.
+func (f Filters) Explain(v any) []*Explanation {
+	result := make([]*Explanation, len(f))
+	for i, filter := range f {
+		result[i] = filter.Explain(v)
+	}
+	return result
+}
+
 // MatchesType returns true if the given type matches any of the filters.
 func (f Filters) MatchesType(t *model.Type) bool {
 	for _, filter := range f {
@@ -1,14 +1,36 @@
 package filter_test
 
 import (
+	"encoding/json"
+	"errors"
 	"testing"
 
 	"github.com/friendly-fhir/fhenix/pkg/config"
 	"github.com/friendly-fhir/fhenix/pkg/filter"
 	"github.com/friendly-fhir/fhenix/pkg/model"
+	"github.com/friendly-fhir/fhenix/pkg/model/conformance/definition"
 	"github.com/friendly-fhir/fhenix/pkg/registry"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
+// sourceOf returns a source in the given package version, whose structure
+// definition is parsed from the given JSON.
+func sourceOf(version, content string) *model.TypeSource {
+	var sd definition.StructureDefinition
+	if err := json.Unmarshal([]byte(content), &sd); err != nil {
+		panic(err)
+	}
+	return &model.TypeSource{
+		Package:             registry.NewPackageRef("default", "hl7.fhir.r4.core", version),
+		StructureDefinition: &sd,
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
 func TestFilterMatchesType(t *testing.T) {
 	testCases := []struct {
 		name string
@@ -109,6 +131,116 @@ func TestFilterMatchesType(t *testing.T) {
 			cfg:  &config.TransformFilter{Condition: `{{ eq .Kind "primitive-type" }}`},
 			t:    &model.Type{Kind: "complex-type"},
 			want: false,
+		}, {
+			name: "Filter matches by query",
+			cfg:  &config.TransformFilter{Query: `kind == "resource" && derivesFrom("DomainResource") && !abstract`},
+			t:    &model.Type{Kind: "resource", Base: &model.Type{Name: "DomainResource"}},
+			want: true,
+		}, {
+			name: "Filter does not match by query",
+			cfg:  &config.TransformFilter{Query: `kind == "resource" && derivesFrom("DomainResource") && !abstract`},
+			t:    &model.Type{Kind: "resource", Base: &model.Type{Name: "DomainResource"}, IsAbstract: true},
+			want: false,
+		}, {
+			name: "Filter does not match by invalid query",
+			cfg:  &config.TransformFilter{Query: `kind`},
+			t:    &model.Type{Kind: "resource"},
+			want: false,
+		}, {
+			name: "Filter does not match when query fails",
+			cfg:  &config.TransformFilter{Query: `type.Base.Name == "Resource"`},
+			t:    &model.Type{Kind: "resource"},
+			want: false,
+		}, {
+			name: "Filter matches by kind",
+			cfg:  &config.TransformFilter{Kind: "logical"},
+			t:    &model.Type{Kind: "logical"},
+			want: true,
+		}, {
+			name: "Filter does not match by kind",
+			cfg:  &config.TransformFilter{Kind: "resource"},
+			t:    &model.Type{Kind: "complex-type"},
+			want: false,
+		}, {
+			name: "Filter matches by derivation",
+			cfg:  &config.TransformFilter{Derivation: "constraint"},
+			t:    &model.Type{Source: sourceOf("4.0.1", `{"derivation": "constraint"}`)},
+			want: true,
+		}, {
+			name: "Filter does not match by derivation",
+			cfg:  &config.TransformFilter{Derivation: "constraint"},
+			t:    &model.Type{Source: sourceOf("4.0.1", `{"derivation": "specialization"}`)},
+			want: false,
+		}, {
+			name: "Filter does not match by derivation without a source",
+			cfg:  &config.TransformFilter{Derivation: "constraint"},
+			t:    &model.Type{},
+			want: false,
+		}, {
+			name: "Filter matches concrete types",
+			cfg:  &config.TransformFilter{Abstract: ptr(false)},
+			t:    &model.Type{Name: "Patient"},
+			want: true,
+		}, {
+			name: "Filter does not match abstract types",
+			cfg:  &config.TransformFilter{Abstract: ptr(false)},
+			t:    &model.Type{Name: "DomainResource", IsAbstract: true},
+			want: false,
+		}, {
+			name: "Filter matches by transitive base",
+			cfg:  &config.TransformFilter{Base: "Resource"},
+			t:    &model.Type{Name: "Patient", Base: &model.Type{Name: "DomainResource", Base: &model.Type{Name: "Resource"}}},
+			want: true,
+		}, {
+			name: "Filter matches by base URL",
+			cfg:  &config.TransformFilter{Base: "http://hl7.org/fhir/StructureDefinition/DomainResource"},
+			t:    &model.Type{Name: "Patient", Base: &model.Type{URL: "http://hl7.org/fhir/StructureDefinition/DomainResource"}},
+			want: true,
+		}, {
+			name: "Filter does not match itself by base",
+			cfg:  &config.TransformFilter{Base: "Resource"},
+			t:    &model.Type{Name: "Resource"},
+			want: false,
+		}, {
+			name: "Filter matches by status",
+			cfg:  &config.TransformFilter{Status: "active"},
+			t:    &model.Type{Source: sourceOf("4.0.1", `{"status": "active"}`)},
+			want: true,
+		}, {
+			name: "Filter does not match by status",
+			cfg:  &config.TransformFilter{Status: "active"},
+			t:    &model.Type{Source: sourceOf("4.0.1", `{"status": "draft"}`)},
+			want: false,
+		}, {
+			name: "Filter matches experimental types",
+			cfg:  &config.TransformFilter{Experimental: ptr(true)},
+			t:    &model.Type{Source: sourceOf("4.0.1", `{"experimental": true}`)},
+			want: true,
+		}, {
+			name: "Filter does not match types that are not experimental",
+			cfg:  &config.TransformFilter{Experimental: ptr(true)},
+			t:    &model.Type{Source: sourceOf("4.0.1", `{}`)},
+			want: false,
+		}, {
+			name: "Filter matches by package version",
+			cfg:  &config.TransformFilter{PackageVersion: "4.0.1"},
+			t:    &model.Type{Source: sourceOf("4.0.1", `{}`)},
+			want: true,
+		}, {
+			name: "Filter does not match by package version",
+			cfg:  &config.TransformFilter{PackageVersion: "4.0.1"},
+			t:    &model.Type{Source: sourceOf("5.0.0", `{}`)},
+			want: false,
+		}, {
+			name: "Filter matches by publisher pattern",
+			cfg:  &config.TransformFilter{Publisher: "^HL7"},
+			t:    &model.Type{Source: sourceOf("4.0.1", `{"publisher": "HL7 FHIR Standard"}`)},
+			want: true,
+		}, {
+			name: "Filter does not match by publisher pattern",
+			cfg:  &config.TransformFilter{Publisher: "^HL7"},
+			t:    &model.Type{Source: sourceOf("4.0.1", `{"publisher": "Example"}`)},
+			want: false,
 		},
 	}
 
@@ -124,3 +256,77 @@ func TestFilterMatchesType(t *testing.T) {
 		})
 	}
 }
+
+func TestFilterExplain(t *testing.T) {
+	patient := &model.Type{
+		Name: "Patient",
+		Kind: "resource",
+		Base: &model.Type{Name: "DomainResource"},
+	}
+	testCases := []struct {
+		name        string
+		cfg         *config.TransformFilter
+		v           any
+		want        []*filter.Clause
+		wantMatched bool
+		wantErr     error
+	}{
+		{
+			name: "every clause matches",
+			cfg:  &config.TransformFilter{Name: "^P", Kind: "resource", Abstract: ptr(false)},
+			v:    patient,
+			want: []*filter.Clause{
+				{Key: "name", Value: "^P", Matched: true},
+				{Key: "kind", Value: "resource", Matched: true},
+				{Key: "abstract", Value: "false", Matched: true},
+			},
+			wantMatched: true,
+		}, {
+			name: "every clause is evaluated after one fails",
+			cfg:  &config.TransformFilter{Name: "^O", Base: "DomainResource"},
+			v:    patient,
+			want: []*filter.Clause{
+				{Key: "name", Value: "^O", Matched: false},
+				{Key: "base", Value: "DomainResource", Matched: true},
+			},
+		}, {
+			name: "condition error is reported",
+			cfg:  &config.TransformFilter{Condition: "{{ .Nope }}"},
+			v:    patient,
+			want: []*filter.Clause{
+				{Key: "condition", Value: "{{ .Nope }}", Matched: false},
+			},
+			wantErr: cmpopts.AnyError,
+		}, {
+			name:    "code systems are unsupported",
+			cfg:     &config.TransformFilter{Type: "CodeSystem"},
+			v:       &model.CodeSystem{},
+			want:    []*filter.Clause{{Key: "type", Value: "CodeSystem"}},
+			wantErr: filter.ErrUnsupported,
+		}, {
+			name: "empty filter has no clauses",
+			cfg:  &config.TransformFilter{},
+			v:    patient,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := filter.New(tc.cfg)[0].Explain(tc.v)
+
+			if got.Matched != tc.wantMatched {
+				t.Errorf("Filter.Explain().Matched = %v, want %v", got.Matched, tc.wantMatched)
+			}
+			var err error
+			for _, clause := range got.Clauses {
+				err = errors.Join(err, clause.Err)
+			}
+			if !cmp.Equal(err, tc.wantErr, cmpopts.EquateErrors()) {
+				t.Errorf("Filter.Explain() error = %v, want %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got.Clauses, cmpopts.IgnoreFields(filter.Clause{}, "Err")); diff != "" {
+				t.Errorf("Filter.Explain() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
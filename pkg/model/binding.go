@@ -0,0 +1,108 @@
+package model
+
+import (
+	"strings"
+)
+
+// BindingStrength is the degree of conformance expected of a binding.
+type BindingStrength string
+
+const (
+	BindingStrengthRequired   BindingStrength = "required"
+	BindingStrengthExtensible BindingStrength = "extensible"
+	BindingStrengthPreferred  BindingStrength = "preferred"
+	BindingStrengthExample    BindingStrength = "example"
+)
+
+// Binding represents a terminology binding of a coded field to a value set.
+type Binding struct {
+	// Strength is the degree of conformance expected of the binding.
+	Strength BindingStrength
+
+	// Description is the human readable description of the binding.
+	Description string
+
+	// ValueSet is the canonical URL of the bound value set.
+	ValueSet string
+
+	// Codes are the codes of the value set, as far as they can be resolved from
+	// the loaded conformance module. This will be empty if the value set is
+	// defined in terms of code systems or filters that are not available.
+	Codes []Code
+}
+
+// IsRequired returns true if the binding must be adhered to.
+func (b *Binding) IsRequired() bool {
+	return b.Strength == BindingStrengthRequired
+}
+
+// ValueSetCodes returns all the codes that could be resolved for the value set
+// with the given canonical URL.
+func (m *Model) ValueSetCodes(url string) []Code {
+	return m.valueSetCodes(url, map[string]struct{}{})
+}
+
+func (m *Model) valueSetCodes(url string, seen map[string]struct{}) []Code {
+	url, _, _ = strings.Cut(url, "|")
+	if codes, ok := m.valueSets.Load(url); ok {
+		return codes.([]Code)
+	}
+	if _, ok := seen[url]; ok {
+		return nil
+	}
+	seen[url] = struct{}{}
+
+	vs, ok := m.module.LookupValueSet(url)
+	if !ok {
+		return nil
+	}
+
+	var codes []Code
+	for _, contains := range vs.GetExpansion().GetContains() {
+		codes = append(codes, Code{
+			Value:   contains.GetCode().GetValue(),
+			Display: contains.GetDisplay().GetValue(),
+		})
+	}
+	if len(codes) == 0 {
+		for _, include := range vs.GetCompose().GetInclude() {
+			for _, ref := range include.GetValueSet() {
+				codes = append(codes, m.valueSetCodes(ref.GetValue(), seen)...)
+			}
+			if concepts := include.GetConcept(); len(concepts) > 0 {
+				for _, concept := range concepts {
+					codes = append(codes, Code{
+						Value:   concept.GetCode().GetValue(),
+						Display: concept.GetDisplay().GetValue(),
+					})
+				}
+				continue
+			}
+			if len(include.GetFilter()) > 0 {
+				continue
+			}
+			codes = append(codes, m.codeSystemCodes(include.GetSystem().GetValue())...)
+		}
+	}
+
+	// Templates of parallel jobs may resolve the same value set concurrently;
+	// whichever finishes first is kept, since both resolve the same codes.
+	actual, _ := m.valueSets.LoadOrStore(url, codes)
+	return actual.([]Code)
+}
+
+func (m *Model) codeSystemCodes(url string) []Code {
+	cs, ok := m.module.LookupCodeSystem(url)
+	if !ok {
+		return nil
+	}
+	codes := make([]Code, 0, len(cs.GetConcept()))
+	for _, concept := range cs.GetConcept() {
+		codes = append(codes, Code{
+			Value:      concept.GetCode().GetValue(),
+			Display:    concept.GetDisplay().GetValue(),
+			Definition: concept.GetDefinition().GetValue(),
+		})
+	}
+	return codes
+}
@@ -0,0 +1,56 @@
+package model_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/friendly-fhir/fhenix/pkg/model"
+	"github.com/friendly-fhir/fhenix/pkg/model/conformance"
+	"github.com/friendly-fhir/fhenix/pkg/registry"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestModel_ValueSetCodes_Concurrent(t *testing.T) {
+	module := conformance.DefaultModule()
+	ref := registry.NewPackageRef(registry.Default, "example", "1.0.0")
+	for _, def := range []string{
+		`{
+			"resourceType": "CodeSystem",
+			"url": "http://example.com/CodeSystem/colours",
+			"name": "Colours",
+			"status": "active",
+			"content": "complete",
+			"concept": [{"code": "red", "display": "Red"}, {"code": "blue", "display": "Blue"}]
+		}`,
+		`{
+			"resourceType": "ValueSet",
+			"url": "http://example.com/ValueSet/colours",
+			"name": "Colours",
+			"status": "active",
+			"compose": {"include": [{"system": "http://example.com/CodeSystem/colours"}]}
+		}`,
+	} {
+		if err := module.ParseJSON([]byte(def), ref); err != nil {
+			t.Fatalf("Module.ParseJSON() = %v", err)
+		}
+	}
+	m := model.NewModel(module)
+	want := []model.Code{{Value: "red", Display: "Red"}, {Value: "blue", Display: "Blue"}}
+
+	var wg sync.WaitGroup
+	got := make([][]model.Code, 8)
+	for i := range got {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got[i] = m.ValueSetCodes("http://example.com/ValueSet/colours|1.0.0")
+		}()
+	}
+	wg.Wait()
+
+	for i := range got {
+		if diff := cmp.Diff(want, got[i]); diff != "" {
+			t.Errorf("Model.ValueSetCodes() mismatch (-want +got):\n%s", diff)
+		}
+	}
+}
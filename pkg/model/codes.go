@@ -38,3 +38,27 @@ type Code struct {
 	// Definition is the definition of the code.
 	Definition string
 }
+
+// DefinedCodeSystems returns every code system that is defined in the
+// conformance module of the model, sorted by URL. Transforms are run over
+// [Model.CodeSystems] instead, so that transforms without include filters
+// do not render code systems with templates written for types.
+func (m *Model) DefinedCodeSystems() []*CodeSystem {
+	var result []*CodeSystem
+	for _, cs := range m.module.CodeSystems() {
+		system := &CodeSystem{
+			Description: cs.GetDescription().GetValue(),
+			URL:         cs.GetURL().GetValue(),
+			Name:        cs.GetName().GetValue(),
+			Title:       cs.GetTitle().GetValue(),
+			Status:      cs.GetStatus().GetValue(),
+			Codes:       m.codeSystemCodes(cs.GetURL().GetValue()),
+		}
+		if source := m.module.SourceOf(cs); source != nil {
+			system.Package = source.Package.Name()
+			system.Version = source.Package.Version()
+		}
+		result = append(result, system)
+	}
+	return result
+}
@@ -0,0 +1,291 @@
+/*
+Package export serializes a resolved [model.Model] to JSON or YAML, so that
+it may be consumed by generators that are not written as Go templates.
+
+The exported document is a tree without cycles: every reference from one type
+to another, such as its base, derived types, or the types of its fields, is
+the canonical URL of the referenced type, which is defined once in the list of
+types of the document. Backbone sub-types have no URL, and are instead
+referenced by their name, which is the path of the element that defines them;
+they are defined in the sub-types of the type that owns them.
+
+The document is versioned by [Version], and is described by the JSON Schema
+at [SchemaURL]. Fields are only ever added within a version.
+*/
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/friendly-fhir/fhenix/pkg/model"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Version is the version of the schema of exported documents.
+	Version = 1
+
+	// SchemaURL is the URL of the JSON Schema of exported documents.
+	SchemaURL = "https://friendly-fhir.github.io/fhenix/jsonschema/fhenix-model-v1.schema.json"
+)
+
+// Format is a format that documents may be written in.
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+)
+
+// ErrUnknownFormat is an error returned when writing a document in a format
+// that is not supported.
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// Document is an exported model.
+type Document struct {
+	// Schema is the URL of the JSON Schema of the document.
+	Schema string `json:"$schema" yaml:"$schema"`
+
+	// Version is the version of the schema of the document.
+	Version int `json:"version" yaml:"version"`
+
+	// Types are the types of the model, sorted by URL.
+	Types []*Type `json:"types" yaml:"types"`
+
+	// CodeSystems are the code systems of the model, sorted by URL.
+	CodeSystems []*CodeSystem `json:"code-systems" yaml:"code-systems"`
+}
+
+// Type is an exported [model.Type].
+type Type struct {
+	URL         string `json:"url,omitempty" yaml:"url,omitempty"`
+	Name        string `json:"name" yaml:"name"`
+	Kind        string `json:"kind" yaml:"kind"`
+	Abstract    bool   `json:"abstract" yaml:"abstract"`
+	Package     string `json:"package,omitempty" yaml:"package,omitempty"`
+	Version     string `json:"version,omitempty" yaml:"version,omitempty"`
+	Source      string `json:"source,omitempty" yaml:"source,omitempty"`
+	Derivation  string `json:"derivation,omitempty" yaml:"derivation,omitempty"`
+	Status      string `json:"status,omitempty" yaml:"status,omitempty"`
+	Short       string `json:"short,omitempty" yaml:"short,omitempty"`
+	Comment     string `json:"comment,omitempty" yaml:"comment,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+
+	// Base is a reference to the base type, if any.
+	Base string `json:"base,omitempty" yaml:"base,omitempty"`
+
+	// Derived are references to the types that derive from this type, sorted.
+	Derived []string `json:"derived,omitempty" yaml:"derived,omitempty"`
+
+	Fields   []*Field `json:"fields,omitempty" yaml:"fields,omitempty"`
+	SubTypes []*Type  `json:"sub-types,omitempty" yaml:"sub-types,omitempty"`
+}
+
+// Field is an exported [model.Field].
+type Field struct {
+	Name       string `json:"name" yaml:"name"`
+	Path       string `json:"path" yaml:"path"`
+	Short      string `json:"short,omitempty" yaml:"short,omitempty"`
+	Comment    string `json:"comment,omitempty" yaml:"comment,omitempty"`
+	Definition string `json:"definition,omitempty" yaml:"definition,omitempty"`
+
+	// Type is a reference to the type of the field. This is empty for fields
+	// that are builtins, or that have alternatives.
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+
+	// Alternatives are references to the possible types of a choice field.
+	Alternatives []string `json:"alternatives,omitempty" yaml:"alternatives,omitempty"`
+
+	Builtin         *Builtin    `json:"builtin,omitempty" yaml:"builtin,omitempty"`
+	Binding         *Binding    `json:"binding,omitempty" yaml:"binding,omitempty"`
+	Cardinality     Cardinality `json:"cardinality" yaml:"cardinality"`
+	BaseCardinality Cardinality `json:"base-cardinality" yaml:"base-cardinality"`
+}
+
+// Builtin is an exported [model.Builtin].
+type Builtin struct {
+	Name  string `json:"name" yaml:"name"`
+	Regex string `json:"regex,omitempty" yaml:"regex,omitempty"`
+}
+
+// Binding is an exported [model.Binding].
+type Binding struct {
+	Strength    string `json:"strength" yaml:"strength"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	ValueSet    string `json:"value-set" yaml:"value-set"`
+	Codes       []Code `json:"codes,omitempty" yaml:"codes,omitempty"`
+}
+
+// Cardinality is an exported [model.Cardinality]. The maximum is a number, or
+// '*' if it is unbounded, as in FHIR.
+type Cardinality struct {
+	Min int    `json:"min" yaml:"min"`
+	Max string `json:"max" yaml:"max"`
+}
+
+// CodeSystem is an exported [model.CodeSystem].
+type CodeSystem struct {
+	URL         string `json:"url" yaml:"url"`
+	Name        string `json:"name" yaml:"name"`
+	Title       string `json:"title,omitempty" yaml:"title,omitempty"`
+	Package     string `json:"package,omitempty" yaml:"package,omitempty"`
+	Version     string `json:"version,omitempty" yaml:"version,omitempty"`
+	Status      string `json:"status,omitempty" yaml:"status,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Codes       []Code `json:"codes,omitempty" yaml:"codes,omitempty"`
+}
+
+// Code is an exported [model.Code].
+type Code struct {
+	Code       string `json:"code" yaml:"code"`
+	Display    string `json:"display,omitempty" yaml:"display,omitempty"`
+	Definition string `json:"definition,omitempty" yaml:"definition,omitempty"`
+}
+
+// New exports every type and code system of the model into a document.
+func New(m *model.Model) *Document {
+	result := &Document{
+		Schema:      SchemaURL,
+		Version:     Version,
+		Types:       []*Type{},
+		CodeSystems: []*CodeSystem{},
+	}
+	for _, t := range m.Types().All() {
+		result.Types = append(result.Types, newType(t))
+	}
+	slices.SortFunc(result.Types, func(lhs, rhs *Type) int {
+		return strings.Compare(lhs.URL, rhs.URL)
+	})
+	for _, cs := range m.DefinedCodeSystems() {
+		result.CodeSystems = append(result.CodeSystems, &CodeSystem{
+			URL:         cs.URL,
+			Name:        cs.Name,
+			Title:       cs.Title,
+			Package:     cs.Package,
+			Version:     cs.Version,
+			Status:      cs.Status,
+			Description: cs.Description,
+			Codes:       newCodes(cs.Codes),
+		})
+	}
+	return result
+}
+
+func newType(t *model.Type) *Type {
+	result := &Type{
+		URL:         t.URL,
+		Name:        t.Name,
+		Kind:        string(t.Kind),
+		Abstract:    t.IsAbstract,
+		Short:       t.Short,
+		Comment:     t.Comment,
+		Description: t.Description,
+		Base:        ref(t.Base),
+	}
+	// Backbone sub-types share the definition of the type that owns them, so
+	// only the owning type reports it.
+	if t.Kind != model.TypeKindBackbone {
+		result.Derivation = t.Derivation()
+		result.Status = t.Status()
+		if t.Source != nil {
+			result.Package = t.Source.Package.Name()
+			result.Version = t.Source.Package.Version()
+			if t.Source.File != "" {
+				result.Source = filepath.Base(t.Source.File)
+			}
+		}
+	}
+	for _, derived := range t.Derived {
+		result.Derived = append(result.Derived, ref(derived))
+	}
+	slices.Sort(result.Derived)
+	for _, f := range t.Fields {
+		result.Fields = append(result.Fields, newField(f))
+	}
+	for _, sub := range t.SubTypes {
+		result.SubTypes = append(result.SubTypes, newType(sub))
+	}
+	return result
+}
+
+func newField(f *model.Field) *Field {
+	result := &Field{
+		Name:            f.Name,
+		Path:            f.Path,
+		Short:           f.Short,
+		Comment:         f.Comment,
+		Definition:      f.Definition,
+		Type:            ref(f.Type),
+		Cardinality:     newCardinality(f.Cardinality),
+		BaseCardinality: newCardinality(f.BaseCardinality),
+	}
+	for _, alt := range f.Alternatives {
+		result.Alternatives = append(result.Alternatives, ref(alt))
+	}
+	if b := f.Builtin; b != nil {
+		result.Builtin = &Builtin{Name: b.Name}
+		if b.Regex != nil {
+			result.Builtin.Regex = b.Regex.String()
+		}
+	}
+	if b := f.Binding; b != nil {
+		result.Binding = &Binding{
+			Strength:    string(b.Strength),
+			Description: b.Description,
+			ValueSet:    b.ValueSet,
+			Codes:       newCodes(b.Codes),
+		}
+	}
+	return result
+}
+
+func newCardinality(c model.Cardinality) Cardinality {
+	if c.Max == model.Unbound {
+		return Cardinality{Min: c.Min, Max: "*"}
+	}
+	return Cardinality{Min: c.Min, Max: fmt.Sprint(c.Max)}
+}
+
+func newCodes(codes []model.Code) []Code {
+	var result []Code
+	for _, code := range codes {
+		result = append(result, Code{Code: code.Value, Display: code.Display, Definition: code.Definition})
+	}
+	return result
+}
+
+// ref returns the reference to the type, which is its URL, or its name for
+// backbone sub-types that have none.
+func ref(t *model.Type) string {
+	if t == nil {
+		return ""
+	}
+	if t.URL == "" {
+		return t.Name
+	}
+	return t.URL
+}
+
+// Write writes the document to the writer in the given format.
+func (d *Document) Write(w io.Writer, format Format) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(d)
+	case FormatYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(d); err != nil {
+			return err
+		}
+		return encoder.Close()
+	}
+	return fmt.Errorf("%w: '%v'", ErrUnknownFormat, format)
+}
@@ -0,0 +1,228 @@
+package export_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/friendly-fhir/fhenix/pkg/model"
+	"github.com/friendly-fhir/fhenix/pkg/model/conformance"
+	"github.com/friendly-fhir/fhenix/pkg/model/export"
+	"github.com/friendly-fhir/fhenix/pkg/registry"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	elementURL  = "http://hl7.org/fhir/StructureDefinition/Element"
+	backboneURL = "http://hl7.org/fhir/StructureDefinition/BackboneElement"
+	widgetURL   = "http://example.com/StructureDefinition/Widget"
+	coloursURL  = "http://example.com/CodeSystem/colours"
+)
+
+var definitions = []string{
+	`{
+		"resourceType": "StructureDefinition",
+		"url": "` + elementURL + `",
+		"name": "Element",
+		"status": "active",
+		"kind": "complex-type",
+		"abstract": true,
+		"type": "Element",
+		"snapshot": {"element": [
+			{"id": "Element", "path": "Element"},
+			{"id": "Element.id", "path": "Element.id", "min": 0, "max": "1", "type": [{"code": "http://hl7.org/fhirpath/System.String"}]}
+		]}
+	}`,
+	`{
+		"resourceType": "StructureDefinition",
+		"url": "` + backboneURL + `",
+		"name": "BackboneElement",
+		"status": "active",
+		"kind": "complex-type",
+		"abstract": true,
+		"type": "BackboneElement",
+		"baseDefinition": "` + elementURL + `",
+		"derivation": "specialization",
+		"snapshot": {"element": [{"id": "BackboneElement", "path": "BackboneElement"}]}
+	}`,
+	`{
+		"resourceType": "StructureDefinition",
+		"url": "` + widgetURL + `",
+		"name": "Widget",
+		"status": "draft",
+		"kind": "resource",
+		"abstract": false,
+		"type": "Widget",
+		"baseDefinition": "` + elementURL + `",
+		"derivation": "specialization",
+		"snapshot": {"element": [
+			{"id": "Widget", "path": "Widget"},
+			{"id": "Widget.part", "path": "Widget.part", "min": 0, "max": "*", "type": [{"code": "BackboneElement"}]},
+			{"id": "Widget.part.label", "path": "Widget.part.label", "min": 1, "max": "1", "type": [{"code": "http://hl7.org/fhirpath/System.String"}]},
+			{"id": "Widget.colour", "path": "Widget.colour", "min": 0, "max": "1", "type": [{"code": "http://hl7.org/fhirpath/System.String"}],
+			 "binding": {"strength": "required", "valueSet": "http://example.com/ValueSet/colours"}}
+		]}
+	}`,
+	`{
+		"resourceType": "CodeSystem",
+		"url": "` + coloursURL + `",
+		"name": "Colours",
+		"status": "active",
+		"content": "complete",
+		"concept": [{"code": "red", "display": "Red"}, {"code": "blue", "display": "Blue"}]
+	}`,
+}
+
+func newModel(t *testing.T) *model.Model {
+	t.Helper()
+	module := conformance.DefaultModule()
+	ref := registry.NewPackageRef(registry.Default, "example", "1.0.0")
+	for _, def := range definitions {
+		if err := module.ParseJSON([]byte(def), ref); err != nil {
+			t.Fatalf("Module.ParseJSON() = %v", err)
+		}
+	}
+	m := model.NewModel(module)
+	if err := m.DefineAllTypes(); err != nil {
+		t.Fatalf("Model.DefineAllTypes() = %v", err)
+	}
+	return m
+}
+
+func TestNew(t *testing.T) {
+	doc := export.New(newModel(t))
+
+	str := &export.Builtin{Name: "string"}
+	want := &export.Document{
+		Schema:  export.SchemaURL,
+		Version: export.Version,
+		Types: []*export.Type{
+			{
+				URL:        widgetURL,
+				Name:       "Widget",
+				Kind:       "resource",
+				Package:    "example",
+				Version:    "1.0.0",
+				Status:     "draft",
+				Base:       elementURL,
+				Derivation: "specialization",
+				Fields: []*export.Field{
+					{
+						Name:            "colour",
+						Path:            "Widget.colour",
+						Builtin:         str,
+						Binding:         &export.Binding{Strength: "required", ValueSet: "http://example.com/ValueSet/colours"},
+						Cardinality:     export.Cardinality{Min: 0, Max: "1"},
+						BaseCardinality: export.Cardinality{Min: 0, Max: "1"},
+					}, {
+						Name:            "part",
+						Path:            "Widget.part",
+						Type:            "Widget.part",
+						Cardinality:     export.Cardinality{Min: 0, Max: "*"},
+						BaseCardinality: export.Cardinality{Min: 0, Max: "*"},
+					},
+				},
+				SubTypes: []*export.Type{{
+					Name:     "Widget.part",
+					Kind:     "backbone",
+					Abstract: false,
+					Base:     backboneURL,
+					Fields: []*export.Field{{
+						Name:            "label",
+						Path:            "Widget.part.label",
+						Builtin:         str,
+						Cardinality:     export.Cardinality{Min: 1, Max: "1"},
+						BaseCardinality: export.Cardinality{Min: 1, Max: "1"},
+					}},
+				}},
+			},
+			{
+				URL:        backboneURL,
+				Name:       "BackboneElement",
+				Kind:       "complex-type",
+				Abstract:   true,
+				Package:    "example",
+				Version:    "1.0.0",
+				Status:     "active",
+				Derivation: "specialization",
+				Base:       elementURL,
+			},
+			{
+				URL:      elementURL,
+				Name:     "Element",
+				Kind:     "complex-type",
+				Abstract: true,
+				Package:  "example",
+				Version:  "1.0.0",
+				Status:   "active",
+				Derived:  []string{widgetURL, backboneURL},
+				Fields: []*export.Field{{
+					Name:            "id",
+					Path:            "Element.id",
+					Builtin:         str,
+					Cardinality:     export.Cardinality{Min: 0, Max: "1"},
+					BaseCardinality: export.Cardinality{Min: 0, Max: "1"},
+				}},
+			},
+		},
+		CodeSystems: []*export.CodeSystem{{
+			URL:     coloursURL,
+			Name:    "Colours",
+			Package: "example",
+			Version: "1.0.0",
+			Status:  "active",
+			Codes:   []export.Code{{Code: "red", Display: "Red"}, {Code: "blue", Display: "Blue"}},
+		}},
+	}
+	if diff := cmp.Diff(want, doc); diff != "" {
+		t.Errorf("New() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDocument_Write(t *testing.T) {
+	doc := export.New(newModel(t))
+
+	testCases := []struct {
+		name      string
+		format    export.Format
+		unmarshal func([]byte, any) error
+		wantErr   error
+	}{
+		{
+			name:      "json",
+			format:    export.FormatJSON,
+			unmarshal: json.Unmarshal,
+		}, {
+			name:      "yaml",
+			format:    export.FormatYAML,
+			unmarshal: yaml.Unmarshal,
+		}, {
+			name:    "unknown format",
+			format:  "xml",
+			wantErr: export.ErrUnknownFormat,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := doc.Write(&buf, tc.format)
+
+			if got, want := err, tc.wantErr; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+				t.Fatalf("Document.Write() = %v, want %v", got, want)
+			}
+			if err != nil {
+				return
+			}
+			var got export.Document
+			if err := tc.unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("unmarshal() = %v", err)
+			}
+			if diff := cmp.Diff(doc, &got); diff != "" {
+				t.Errorf("Document.Write() round trip mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
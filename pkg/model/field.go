@@ -11,6 +11,7 @@ type Field struct {
 	Type         *Type
 	Alternatives []*Type
 	Builtin      *Builtin
+	Binding      *Binding
 
 	Cardinality     Cardinality
 	BaseCardinality Cardinality
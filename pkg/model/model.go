@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"slices"
 	"strings"
+	"sync"
 
 	"github.com/friendly-fhir/fhenix/pkg/model/conformance"
 	"github.com/friendly-fhir/fhenix/pkg/model/conformance/definition"
@@ -14,9 +15,10 @@ import (
 )
 
 type Model struct {
-	module  *conformance.Module
-	types   *TypeSet
-	defined bool
+	module    *conformance.Module
+	types     *TypeSet
+	valueSets sync.Map
+	defined   bool
 }
 
 func NewModel(module *conformance.Module) *Model {
@@ -217,6 +219,14 @@ func (m *Model) typeFromElements(t *Type, elems []*fhir.ElementDefinition) error
 		if err := m.fieldFromElement(t, field, elem); err != nil {
 			return err
 		}
+		if binding := elem.GetBinding(); binding != nil {
+			field.Binding = &Binding{
+				Strength:    BindingStrength(binding.GetStrength().GetValue()),
+				Description: binding.GetDescription().GetValue(),
+				ValueSet:    binding.GetValueSet().GetValue(),
+				Codes:       m.ValueSetCodes(binding.GetValueSet().GetValue()),
+			}
+		}
 		if t.Package() == "hl7.fhir.r4.core" && elem.GetPath().GetValue() == "unsignedInt.value" || elem.GetPath().GetValue() == "positiveInt.value" {
 			field.Builtin = &Builtin{
 				Name: m.fieldpath(elem.GetPath().GetValue()),
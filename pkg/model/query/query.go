@@ -0,0 +1,129 @@
+/*
+Package query provides a concise expression language for selecting the types
+of a [model.Model], such as in the filters of a transformation:
+
+	kind == "resource" && derivesFrom("DomainResource") && !abstract
+
+Queries are written in the expr language (https://expr-lang.org), and are
+compiled and type-checked once, so that they may be evaluated quickly against
+every type of the model. A query must evaluate to a bool.
+
+The following are available to a query:
+
+  - name: the name of the type
+  - url: the canonical URL of the type
+  - kind: the kind of the type, such as "resource" or "complex-type"
+  - abstract: whether the type is abstract
+  - package: the name of the package that defines the type
+  - version: the version of the package that defines the type
+  - source: the name of the file that defines the type
+  - base: the name of the base type, or "" if there is none
+  - derivation: the derivation of the type, "specialization" or "constraint"
+  - status: the publication status of the type, such as "draft" or "active"
+  - experimental: whether the type is marked as experimental
+  - publisher: the publisher of the type
+  - type: the [model.Type] itself, for anything else
+  - vars: the user-defined variables of the transformation
+  - derivesFrom(name): whether the type derives from the type with the given
+    name or URL, directly or transitively
+*/
+package query
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/friendly-fhir/fhenix/pkg/model"
+)
+
+// ErrQuery is an error returned when a query is invalid.
+var ErrQuery = errors.New("invalid query")
+
+// env is the environment that queries are evaluated in.
+type env struct {
+	Name         string            `expr:"name"`
+	URL          string            `expr:"url"`
+	Kind         string            `expr:"kind"`
+	Abstract     bool              `expr:"abstract"`
+	Package      string            `expr:"package"`
+	Version      string            `expr:"version"`
+	Source       string            `expr:"source"`
+	Base         string            `expr:"base"`
+	Derivation   string            `expr:"derivation"`
+	Status       string            `expr:"status"`
+	Experimental bool              `expr:"experimental"`
+	Publisher    string            `expr:"publisher"`
+	Type         *model.Type       `expr:"type"`
+	Vars         map[string]any    `expr:"vars"`
+	DerivesFrom  func(string) bool `expr:"derivesFrom"`
+}
+
+// newEnv returns the environment of the given type.
+func newEnv(t *model.Type, vars map[string]any) *env {
+	result := &env{
+		Name:         t.Name,
+		URL:          t.URL,
+		Kind:         string(t.Kind),
+		Abstract:     t.IsAbstract,
+		Derivation:   t.Derivation(),
+		Status:       t.Status(),
+		Experimental: t.IsExperimental(),
+		Publisher:    t.Publisher(),
+		Type:         t,
+		Vars:         vars,
+		DerivesFrom:  t.DerivesFrom,
+	}
+	if t.Source != nil {
+		result.Package = t.Source.Package.Name()
+		result.Version = t.Source.Package.Version()
+		if t.Source.File != "" {
+			result.Source = filepath.Base(t.Source.File)
+		}
+	}
+	if t.Base != nil {
+		result.Base = t.Base.Name
+	}
+	return result
+}
+
+// Query is a compiled query.
+type Query struct {
+	source  string
+	program *vm.Program
+}
+
+// Compile compiles and type-checks the query, which must evaluate to a bool.
+func Compile(source string) (*Query, error) {
+	source = strings.TrimSpace(source)
+	if source == "" {
+		return nil, fmt.Errorf("%w: empty query", ErrQuery)
+	}
+	program, err := expr.Compile(source, expr.Env(&env{}), expr.AsBool())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrQuery, err)
+	}
+	return &Query{source: source, program: program}, nil
+}
+
+// String returns the source of the query.
+func (q *Query) String() string {
+	return q.source
+}
+
+// Matches evaluates the query against the type, with the given user-defined
+// variables. An error is returned if evaluating the query fails, such as when
+// it dereferences a nil field of the type.
+func (q *Query) Matches(t *model.Type, vars map[string]any) (bool, error) {
+	if t == nil {
+		return false, nil
+	}
+	result, err := expr.Run(q.program, newEnv(t, vars))
+	if err != nil {
+		return false, err
+	}
+	return result.(bool), nil
+}
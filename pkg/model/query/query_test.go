@@ -0,0 +1,156 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/friendly-fhir/fhenix/pkg/model"
+	"github.com/friendly-fhir/fhenix/pkg/model/query"
+	"github.com/friendly-fhir/fhenix/pkg/registry"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestCompile(t *testing.T) {
+	testCases := []struct {
+		name    string
+		query   string
+		wantErr error
+	}{
+		{
+			name:  "valid query",
+			query: `kind == "resource" && derivesFrom("DomainResource") && !abstract`,
+		}, {
+			name:  "query of the type",
+			query: `len(type.Fields) > 0 && type.Base.Name == "Resource"`,
+		}, {
+			name:  "query of vars",
+			query: `name in vars.types`,
+		}, {
+			name:  "query of the definition",
+			query: `derivation == "specialization" && status == "active" && !experimental && publisher startsWith "HL7"`,
+		}, {
+			name:    "empty query",
+			query:   " ",
+			wantErr: query.ErrQuery,
+		}, {
+			name:    "syntax error",
+			query:   `kind ==`,
+			wantErr: query.ErrQuery,
+		}, {
+			name:    "unknown identifier",
+			query:   `kynd == "resource"`,
+			wantErr: query.ErrQuery,
+		}, {
+			name:    "unknown field of the type",
+			query:   `type.Kynd == "resource"`,
+			wantErr: query.ErrQuery,
+		}, {
+			name:    "mismatched types",
+			query:   `abstract == "true"`,
+			wantErr: query.ErrQuery,
+		}, {
+			name:    "not a bool",
+			query:   `name`,
+			wantErr: query.ErrQuery,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := query.Compile(tc.query)
+
+			if got, want := err, tc.wantErr; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+				t.Errorf("Compile(%q) = %v, want %v", tc.query, got, want)
+			}
+		})
+	}
+}
+
+func TestQuery_Matches(t *testing.T) {
+	resource := &model.Type{Name: "Resource", URL: "http://hl7.org/fhir/StructureDefinition/Resource", Kind: model.TypeKindResource, IsAbstract: true}
+	domain := &model.Type{Name: "DomainResource", URL: "http://hl7.org/fhir/StructureDefinition/DomainResource", Kind: model.TypeKindResource, IsAbstract: true, Base: resource}
+	patient := &model.Type{
+		Name: "Patient",
+		URL:  "http://hl7.org/fhir/StructureDefinition/Patient",
+		Kind: model.TypeKindResource,
+		Base: domain,
+		Source: &model.TypeSource{
+			Package: registry.NewPackageRef("default", "hl7.fhir.r4.core", "4.0.1"),
+			File:    "/cache/hl7.fhir.r4.core/StructureDefinition-Patient.json",
+		},
+	}
+	vars := map[string]any{"types": []any{"Patient"}}
+
+	testCases := []struct {
+		name    string
+		query   string
+		t       *model.Type
+		want    bool
+		wantErr error
+	}{
+		{
+			name:  "matches transitive base by name",
+			query: `kind == "resource" && derivesFrom("Resource") && !abstract`,
+			t:     patient,
+			want:  true,
+		}, {
+			name:  "matches base by URL",
+			query: `derivesFrom("http://hl7.org/fhir/StructureDefinition/DomainResource")`,
+			t:     patient,
+			want:  true,
+		}, {
+			name:  "does not derive from itself",
+			query: `derivesFrom("DomainResource")`,
+			t:     domain,
+			want:  false,
+		}, {
+			name:  "abstract does not match",
+			query: `kind == "resource" && !abstract`,
+			t:     domain,
+			want:  false,
+		}, {
+			name:  "matches package and source",
+			query: `package == "hl7.fhir.r4.core" && version startsWith "4." && source == "StructureDefinition-Patient.json"`,
+			t:     patient,
+			want:  true,
+		}, {
+			name:  "matches base name",
+			query: `base == "DomainResource"`,
+			t:     patient,
+			want:  true,
+		}, {
+			name:  "matches vars",
+			query: `name in vars.types`,
+			t:     patient,
+			want:  true,
+		}, {
+			name:  "matches name pattern",
+			query: `name matches "^P"`,
+			t:     patient,
+			want:  true,
+		}, {
+			name:    "runtime error",
+			query:   `type.Source.File != ""`,
+			t:       resource,
+			wantErr: cmpopts.AnyError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := query.Compile(tc.query)
+			if err != nil {
+				t.Fatalf("Compile(%q) = %v", tc.query, err)
+			}
+
+			got, err := q.Matches(tc.t, vars)
+
+			if got, want := err, tc.wantErr; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+				t.Fatalf("Query.Matches() = %v, want %v", got, want)
+			}
+			if got != tc.want {
+				t.Errorf("Query.Matches() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
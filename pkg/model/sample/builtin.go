@@ -0,0 +1,129 @@
+package sample
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/friendly-fhir/fhenix/pkg/model"
+)
+
+// maxRepeat is the upper bound used for unbounded regex repetitions.
+const maxRepeat = 8
+
+// builtin returns a scalar value for the builtin type. Values are generated
+// from a sensible default for the builtin name first, and are generated from
+// the builtin's regular expression if the default does not satisfy it.
+func (g *Generator) builtin(b *model.Builtin) any {
+	var value any
+	switch strings.ToLower(b.Name) {
+	case "boolean":
+		return g.rand.Intn(2) == 1
+	case "integer", "unsignedint", "positiveint":
+		value = 1 + g.rand.Intn(100)
+	case "decimal":
+		value = float64(g.rand.Intn(10000)) / 100
+	case "date":
+		value = g.time().Format(time.DateOnly)
+	case "datetime":
+		value = g.time().Format(time.RFC3339)
+	case "time":
+		value = g.time().Format(time.TimeOnly)
+	default:
+		value = g.word() + " " + g.word()
+	}
+	if b.Regex == nil || anchored(b.Regex).MatchString(fmt.Sprint(value)) {
+		return value
+	}
+	str := g.regex(b.Regex.String())
+	if _, ok := value.(int); ok {
+		if i, err := strconv.Atoi(str); err == nil {
+			return i
+		}
+	}
+	return str
+}
+
+func (g *Generator) time() time.Time {
+	start := time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)
+	return start.Add(time.Duration(g.rand.Int63n(60*365*24)) * time.Hour).Add(time.Duration(g.rand.Intn(3600)) * time.Second)
+}
+
+// regex generates a string that matches the given regular expression.
+func (g *Generator) regex(expr string) string {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return ""
+	}
+	var sb strings.Builder
+	g.writeRegex(&sb, re)
+	return sb.String()
+}
+
+func (g *Generator) writeRegex(sb *strings.Builder, re *syntax.Regexp) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		sb.WriteString(string(re.Rune))
+	case syntax.OpCharClass:
+		sb.WriteRune(g.charClass(re.Rune))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		sb.WriteString(g.word()[:1])
+	case syntax.OpCapture:
+		g.writeRegex(sb, re.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			g.writeRegex(sb, sub)
+		}
+	case syntax.OpAlternate:
+		g.writeRegex(sb, re.Sub[g.rand.Intn(len(re.Sub))])
+	case syntax.OpStar:
+		g.repeat(sb, re.Sub[0], 0, maxRepeat)
+	case syntax.OpPlus:
+		g.repeat(sb, re.Sub[0], 1, maxRepeat)
+	case syntax.OpQuest:
+		g.repeat(sb, re.Sub[0], 0, 1)
+	case syntax.OpRepeat:
+		upper := re.Max
+		if upper < 0 {
+			upper = re.Min + maxRepeat
+		}
+		g.repeat(sb, re.Sub[0], re.Min, min(upper, re.Min+maxRepeat))
+	}
+}
+
+func (g *Generator) repeat(sb *strings.Builder, re *syntax.Regexp, lower, upper int) {
+	n := lower
+	if upper > lower {
+		n += g.rand.Intn(upper - lower + 1)
+	}
+	for range n {
+		g.writeRegex(sb, re)
+	}
+}
+
+// charClass picks a rune from the character class, which is encoded as pairs
+// of inclusive ranges. Alphanumeric runes are preferred so that the output
+// stays readable, falling back to any printable ASCII rune, and then to the
+// first rune of the class.
+func (g *Generator) charClass(ranges []rune) rune {
+	preferences := [][2]rune{{'a', 'z'}, {'A', 'Z'}, {'0', '9'}}
+	for _, candidates := range [][][2]rune{preferences, {{0x21, 0x7e}}} {
+		var runes []rune
+		for i := 0; i+1 < len(ranges); i += 2 {
+			for _, pref := range candidates {
+				for r := max(ranges[i], pref[0]); r <= min(ranges[i+1], pref[1]); r++ {
+					runes = append(runes, r)
+				}
+			}
+		}
+		if len(runes) > 0 {
+			return runes[g.rand.Intn(len(runes))]
+		}
+	}
+	if len(ranges) > 0 {
+		return ranges[0]
+	}
+	return ' '
+}
@@ -0,0 +1,245 @@
+/*
+Package sample generates synthetic example instances of [model.Type] entries.
+
+Instances are produced as JSON-compatible values (maps, slices, strings,
+numbers and booleans) that follow the FHIR JSON representation of the type.
+Generation respects field cardinalities, choice types, bindings and the regular
+expression constraints of builtin types, and is driven by a seeded random
+number generator so that the same seed always produces the same output.
+*/
+package sample
+
+import (
+	"math/rand"
+	"regexp"
+	"strings"
+
+	"github.com/friendly-fhir/fhenix/pkg/model"
+)
+
+// Option is an option for configuring a [Generator].
+type Option interface {
+	set(*Generator)
+}
+
+type option func(*Generator)
+
+func (o option) set(g *Generator) {
+	o(g)
+}
+
+var _ Option = (*option)(nil)
+
+// Seed returns an [Option] that sets the seed of the random number generator.
+func Seed(seed int64) Option {
+	return option(func(g *Generator) {
+		g.rand = rand.New(rand.NewSource(seed))
+	})
+}
+
+// MaxDepth returns an [Option] that sets the depth after which only required
+// fields will be generated. This bounds the size of recursive types.
+func MaxDepth(depth int) Option {
+	return option(func(g *Generator) {
+		g.maxDepth = depth
+	})
+}
+
+// MaxItems returns an [Option] that sets the maximum number of items that will
+// be generated for unbounded list fields.
+func MaxItems(items int) Option {
+	return option(func(g *Generator) {
+		g.maxItems = max(items, 1)
+	})
+}
+
+// OptionalRate returns an [Option] that sets the probability, between 0 and 1,
+// that an optional field will be populated.
+func OptionalRate(rate float64) Option {
+	return option(func(g *Generator) {
+		g.optionalRate = rate
+	})
+}
+
+// Generator produces example instances of model types.
+//
+// A Generator is not safe for concurrent use.
+type Generator struct {
+	rand         *rand.Rand
+	maxDepth     int
+	maxItems     int
+	optionalRate float64
+}
+
+const (
+	// DefaultMaxDepth is the default depth after which only required fields are
+	// generated.
+	DefaultMaxDepth = 3
+
+	// DefaultMaxItems is the default maximum number of items generated for
+	// unbounded lists.
+	DefaultMaxItems = 2
+
+	// DefaultOptionalRate is the default probability of populating an optional
+	// field.
+	DefaultOptionalRate = 0.5
+)
+
+// New constructs a new [Generator] with the given options. Without a [Seed]
+// option, the generator is seeded with 0.
+func New(opts ...Option) *Generator {
+	g := &Generator{
+		rand:         rand.New(rand.NewSource(0)),
+		maxDepth:     DefaultMaxDepth,
+		maxItems:     DefaultMaxItems,
+		optionalRate: DefaultOptionalRate,
+	}
+	for _, opt := range opts {
+		opt.set(g)
+	}
+	return g
+}
+
+// Generate returns an example instance of the given type. Resources produce a
+// map containing the 'resourceType' key, complex and backbone types produce a
+// map of field names to values, and primitive types produce a scalar value.
+//
+// Abstract types cannot be instantiated, and produce nil.
+func (g *Generator) Generate(t *model.Type) any {
+	return g.value(t, 0)
+}
+
+func (g *Generator) value(t *model.Type, depth int) any {
+	if t == nil || (t.IsAbstract && t.Kind != model.TypeKindBackbone) {
+		return nil
+	}
+	if t.Kind == model.TypeKindPrimitive {
+		for _, field := range t.Fields {
+			if field.Name == "value" && field.Builtin != nil {
+				return g.builtin(field.Builtin)
+			}
+		}
+		return g.word()
+	}
+
+	result := map[string]any{}
+	if t.Kind == model.TypeKindResource {
+		result["resourceType"] = t.Name
+	}
+	for _, field := range t.Fields {
+		g.field(result, field, depth)
+	}
+	return result
+}
+
+func (g *Generator) field(result map[string]any, field *model.Field, depth int) {
+	count := g.count(field, depth)
+	if count == 0 {
+		return
+	}
+
+	name := field.Name
+	var generate func() any
+	switch {
+	case len(field.Alternatives) > 0:
+		// Choice types only ever have a single value, and the name of the field
+		// carries the chosen type, e.g. 'value[x]' becomes 'valueString'.
+		alt := field.Alternatives[g.rand.Intn(len(field.Alternatives))]
+		name += upperFirst(alt.Name)
+		generate = func() any { return g.value(alt, depth+1) }
+	case field.Builtin != nil:
+		generate = func() any { return g.builtin(field.Builtin) }
+	case field.Binding != nil && len(field.Binding.Codes) > 0 && field.Type != nil:
+		generate = func() any { return g.coded(field.Type, field.Binding, depth) }
+	default:
+		generate = func() any { return g.value(field.Type, depth+1) }
+	}
+
+	if !field.IsList() {
+		if v := generate(); v != nil {
+			result[name] = v
+		}
+		return
+	}
+	var values []any
+	for range count {
+		if v := generate(); v != nil {
+			values = append(values, v)
+		}
+	}
+	if len(values) > 0 {
+		result[name] = values
+	}
+}
+
+// count returns the number of values to generate for the field, based on its
+// cardinality and the current depth.
+func (g *Generator) count(field *model.Field, depth int) int {
+	card := field.Cardinality
+	if card.IsDisabled() {
+		return 0
+	}
+	if card.Min == 0 && (depth >= g.maxDepth || g.rand.Float64() >= g.optionalRate) {
+		return 0
+	}
+	if !card.IsList() {
+		return 1
+	}
+	upper := g.maxItems
+	if card.Max != model.Unbound {
+		upper = min(upper, card.Max)
+	}
+	lower := max(card.Min, 1)
+	if upper <= lower {
+		return lower
+	}
+	return lower + g.rand.Intn(upper-lower+1)
+}
+
+// coded returns a value for a field with a terminology binding, selecting one
+// of the codes of the bound value set.
+func (g *Generator) coded(t *model.Type, binding *model.Binding, depth int) any {
+	code := binding.Codes[g.rand.Intn(len(binding.Codes))]
+	coding := func() map[string]any {
+		result := map[string]any{"code": code.Value}
+		if code.Display != "" {
+			result["display"] = code.Display
+		}
+		return result
+	}
+	switch t.Name {
+	case "code", "string", "uri":
+		return code.Value
+	case "Coding":
+		return coding()
+	case "CodeableConcept":
+		return map[string]any{"coding": []any{coding()}}
+	}
+	return g.value(t, depth+1)
+}
+
+var words = []string{
+	"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel",
+	"india", "juliet", "kilo", "lima", "mike", "november", "oscar", "papa",
+}
+
+func (g *Generator) word() string {
+	return words[g.rand.Intn(len(words))]
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// anchored returns a version of the regular expression that must match the
+// whole input, since FHIR regexes are defined as full-string matches.
+func anchored(re *regexp.Regexp) *regexp.Regexp {
+	result, err := regexp.Compile(`^(?:` + re.String() + `)$`)
+	if err != nil {
+		return re
+	}
+	return result
+}
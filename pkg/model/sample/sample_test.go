@@ -0,0 +1,92 @@
+package sample_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/friendly-fhir/fhenix/pkg/model"
+	"github.com/friendly-fhir/fhenix/pkg/model/sample"
+	"github.com/google/go-cmp/cmp"
+)
+
+func primitive(name string, builtin *model.Builtin) *model.Type {
+	return &model.Type{
+		Name: name,
+		Kind: model.TypeKindPrimitive,
+		Fields: []*model.Field{
+			{Name: "value", Builtin: builtin, Cardinality: model.Cardinality{Min: 0, Max: 1}},
+		},
+	}
+}
+
+var (
+	stringType  = primitive("string", &model.Builtin{Name: "string"})
+	booleanType = primitive("boolean", &model.Builtin{Name: "boolean"})
+	codeType    = primitive("code", &model.Builtin{Name: "string", Regex: regexp.MustCompile(`[^\s]+(\s[^\s]+)*`)})
+	idType      = primitive("id", &model.Builtin{Name: "string", Regex: regexp.MustCompile(`[A-Za-z0-9\-\.]{1,64}`)})
+
+	patientType = &model.Type{
+		Name: "Patient",
+		Kind: model.TypeKindResource,
+		Fields: []*model.Field{
+			{Name: "id", Type: idType, Cardinality: model.Cardinality{Min: 1, Max: 1}},
+			{Name: "name", Type: stringType, Cardinality: model.Cardinality{Min: 2, Max: model.Unbound}},
+			{Name: "deceased", Alternatives: []*model.Type{booleanType, stringType}, Cardinality: model.Cardinality{Min: 1, Max: 1}},
+			{Name: "gender", Type: codeType, Cardinality: model.Cardinality{Min: 1, Max: 1}, Binding: &model.Binding{
+				Strength: model.BindingStrengthRequired,
+				Codes:    []model.Code{{Value: "male"}, {Value: "female"}},
+			}},
+			{Name: "disabled", Type: stringType, Cardinality: model.Cardinality{Min: 0, Max: 0}},
+		},
+	}
+)
+
+func TestGenerator_Generate_Reproducible(t *testing.T) {
+	want := sample.New(sample.Seed(42)).Generate(patientType)
+
+	got := sample.New(sample.Seed(42)).Generate(patientType)
+
+	if !cmp.Equal(got, want) {
+		t.Errorf("Generator.Generate() mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestGenerator_Generate(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		got, ok := sample.New(sample.Seed(seed)).Generate(patientType).(map[string]any)
+		if !ok {
+			t.Fatalf("Generator.Generate() = %T, want map[string]any", got)
+		}
+
+		if got, want := got["resourceType"], "Patient"; got != want {
+			t.Errorf("Generator.Generate(): resourceType = %v, want %v", got, want)
+		}
+		if id, _ := got["id"].(string); !regexp.MustCompile(`^[A-Za-z0-9\-\.]{1,64}$`).MatchString(id) {
+			t.Errorf("Generator.Generate(): id = %q, want regex match", id)
+		}
+		if names, _ := got["name"].([]any); len(names) < 2 {
+			t.Errorf("Generator.Generate(): name = %v, want at least 2 entries", got["name"])
+		}
+		_, hasBool := got["deceasedBoolean"]
+		_, hasString := got["deceasedString"]
+		if hasBool == hasString {
+			t.Errorf("Generator.Generate(): want exactly one of deceasedBoolean or deceasedString, got %v", got)
+		}
+		if gender := got["gender"]; gender != "male" && gender != "female" {
+			t.Errorf("Generator.Generate(): gender = %v, want bound code", gender)
+		}
+		if _, ok := got["disabled"]; ok {
+			t.Errorf("Generator.Generate(): disabled field was generated")
+		}
+	}
+}
+
+func TestGenerator_Generate_AbstractType(t *testing.T) {
+	ty := &model.Type{Name: "Resource", Kind: model.TypeKindResource, IsAbstract: true}
+
+	got := sample.New().Generate(ty)
+
+	if got != nil {
+		t.Errorf("Generator.Generate() = %v, want nil", got)
+	}
+}
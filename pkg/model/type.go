@@ -20,6 +20,7 @@ const (
 	TypeKindPrimitive   TypeKind = "primitive-type"
 	TypeKindComplexType TypeKind = "complex-type"
 	TypeKindBackbone    TypeKind = "backbone"
+	TypeKindLogical     TypeKind = "logical"
 )
 
 type Type struct {
@@ -88,16 +89,57 @@ func (t *Type) Version() string {
 	return t.Source.Package.Version()
 }
 
+// DerivesFrom returns true if any base of the type, transitively, has the
+// given name or URL. A type does not derive from itself.
+func (t *Type) DerivesFrom(name string) bool {
+	for base := t.Base; base != nil; base = base.Base {
+		if base.Name == name || base.URL == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Type) structureDefinition() *definition.StructureDefinition {
+	if t.Source == nil {
+		return nil
+	}
+	return t.Source.StructureDefinition
+}
+
+// Derivation returns the derivation of the type, which is either
+// "specialization" or "constraint", or "" if the type has no definition.
+func (t *Type) Derivation() string {
+	return t.structureDefinition().GetDerivation().GetValue()
+}
+
+// Status returns the publication status of the definition of the type, such
+// as "draft" or "active".
+func (t *Type) Status() string {
+	return t.structureDefinition().GetStatus().GetValue()
+}
+
+// IsExperimental returns true if the definition of the type is marked as
+// experimental.
+func (t *Type) IsExperimental() bool {
+	return t.structureDefinition().GetExperimental().GetValue()
+}
+
+// Publisher returns the publisher of the definition of the type.
+func (t *Type) Publisher() string {
+	return t.structureDefinition().GetPublisher().GetValue()
+}
+
 func (t *Type) HasDerived() bool {
 	return len(t.Derived) > 0
 }
 
 func (t *Type) IsConstraint() bool {
-	return t.Source.StructureDefinition.GetDerivation().GetValue() == "constraint"
+	return t.Derivation() == "constraint"
 }
 
 func (t *Type) IsSpecialization() bool {
-	return t.Source.StructureDefinition.GetDerivation().GetValue() == "specialization"
+	return t.Derivation() == "specialization"
 }
 
 func (t *Type) IsResource() bool {
@@ -152,6 +152,16 @@ func (c *Cache) ForceFetch(ctx context.Context, registry, pkg, version string) e
 			},
 		},
 	}
+	return unpack(r, unpackers)
+}
+
+// Unpack unpacks a FHIR package archive, as an uncompressed tarball, into the
+// directory in the same way that fetched packages are unpacked into the cache.
+func Unpack(r io.Reader, dir string) error {
+	return unpack(r, &archive.DiskUnpacker{Root: dir})
+}
+
+func unpack(r io.Reader, unpacker archive.Unpacker) error {
 	var opts []archive.Option
 
 	// Only unpack JSON files that are not the .index.json
@@ -167,7 +177,7 @@ func (c *Cache) ForceFetch(ctx context.Context, registry, pkg, version string) e
 	}))
 
 	tar := archive.New(r, opts...)
-	return tar.Unpack(unpackers)
+	return tar.Unpack(unpacker)
 }
 
 // CacheDir returns the directory where the specified package is cached.
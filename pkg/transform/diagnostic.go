@@ -0,0 +1,130 @@
+package transform
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/friendly-fhir/fhenix/internal/ansi"
+	"github.com/friendly-fhir/fhenix/pkg/config"
+	"github.com/friendly-fhir/fhenix/pkg/transform/internal/transformer"
+)
+
+// TemplateError is an error from parsing or executing a template, which is
+// mapped back to the location in the template file that caused it.
+type TemplateError struct {
+	// File is the path of the template file, as given in the config.
+	File string
+
+	// Line is the 1-based line number in the file.
+	Line int
+
+	// Column is the 1-based column in the line, or 0 if it is not known. Columns
+	// are not known in indent mode, where lines are dedented before parsing.
+	Column int
+
+	// Message is the error message, without its location.
+	Message string
+
+	// Source is the content of the line that caused the error, or empty if the
+	// file could not be read.
+	Source string
+
+	// Err is the underlying error from the template.
+	Err error
+}
+
+// Error returns the error message prefixed with its location.
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Location(), e.Message)
+}
+
+// Location returns the location of the error, as 'file:line:column', or as
+// 'file:line' if the column is not known.
+func (e *TemplateError) Location() string {
+	if e.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d", e.File, e.Line, e.Column)
+	}
+	return fmt.Sprintf("%s:%d", e.File, e.Line)
+}
+
+// Unwrap returns the underlying error from the template.
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// WriteExcerpt writes an excerpt of the source line that caused the error to
+// the writer, with a caret pointing at the column. Colors are only written if
+// the writer supports them. Nothing is written if the source is not known.
+func (e *TemplateError) WriteExcerpt(w io.Writer) error {
+	if e.Source == "" {
+		return nil
+	}
+	line := strconv.Itoa(e.Line)
+	gutter := strings.Repeat(" ", len(line))
+	bar := ansi.FGGray.Format("%s |", gutter)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %s\n", ansi.FGGray.Format("%s-->", gutter), e.Location())
+	fmt.Fprintf(&buf, "%s\n", bar)
+	fmt.Fprintf(&buf, "%s %s\n", ansi.FGGray.Format("%s |", line), e.Source)
+	if column := min(e.Column, len(e.Source)+1); column > 0 {
+		// Tabs are kept so that the caret lines up with the source line.
+		padding := strings.Map(func(r rune) rune {
+			if r == '\t' {
+				return r
+			}
+			return ' '
+		}, e.Source[:column-1])
+		fmt.Fprintf(&buf, "%s %s%s\n", bar, padding, ansi.Format(ansi.Bold, ansi.FGRed).Format("^"))
+	}
+	_, err := ansi.Fprint(w, buf.String())
+	return err
+}
+
+// templateErrorRegex matches the location that text/template and
+// html/template prefix to the errors from parsing, escaping, and executing
+// templates, such as 'template: path/to/file.tmpl:12:3: executing ...'.
+var templateErrorRegex = regexp.MustCompile(`(?s)^(?:template: |html/template:)(.+?):(\d+):(?:(\d+):)? (.*)$`)
+
+// newTemplateError maps an error from a template to a [TemplateError], reading
+// the source line from the template file in the file system. If the error wraps
+// errors from other template files, such as from a func template called by the
+// failing template, the innermost of them is located. Errors that are not from
+// a template file are returned unchanged.
+func newTemplateError(fsys fs.FS, mode config.Mode, files []string, err error) error {
+	var match []string
+	for cause := err; cause != nil; cause = errors.Unwrap(cause) {
+		if m := templateErrorRegex.FindStringSubmatch(cause.Error()); m != nil && slices.Contains(files, m[1]) {
+			match = m
+		}
+	}
+	if match == nil {
+		return err
+	}
+	file := match[1]
+	result := &TemplateError{
+		File:    file,
+		Message: match[4],
+		Err:     err,
+	}
+	result.Line, _ = strconv.Atoi(match[2])
+	if match[3] != "" && mode != config.ModeIndent {
+		// Templates report 0-based byte offsets into the line.
+		column, _ := strconv.Atoi(match[3])
+		result.Column = column + 1
+	}
+	if content, err := transformer.ReadFile(fsys, file); err == nil {
+		lines := strings.Split(string(content), "\n")
+		if result.Line > 0 && result.Line <= len(lines) {
+			result.Source = strings.TrimRight(lines[result.Line-1], "\r")
+		}
+	}
+	return result
+}
@@ -0,0 +1,132 @@
+/*
+Package emit enables a single template render to produce several files.
+
+Templates delimit the content of each additional file with the 'file' and
+'endfile' functions:
+
+	{{ file "types.h" }}
+	...
+	{{ endfile }}
+
+These functions write markers into the rendered output, which [Split] then
+uses to separate the files from the primary output.
+*/
+package emit
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrUnterminated is returned when a 'file' block has no 'endfile'.
+	ErrUnterminated = errors.New("file block is not terminated by 'endfile'")
+
+	// ErrNested is returned when a 'file' block is opened within another.
+	ErrNested = errors.New("file blocks may not be nested")
+
+	// ErrUnexpectedEnd is returned when 'endfile' does not close a file block.
+	ErrUnexpectedEnd = errors.New("'endfile' outside of a file block")
+
+	// ErrEmptyPath is returned when a file block has an empty path.
+	ErrEmptyPath = errors.New("file block has an empty path")
+
+	// ErrDuplicate is returned when two file blocks have the same path.
+	ErrDuplicate = errors.New("file emitted more than once")
+)
+
+// The markers use a character from the Unicode private use area, which is
+// neither expected in templates nor escaped by html/template; the path is
+// hex-encoded for the same reason.
+const (
+	delimiter = "\uE000"
+	beginTag  = delimiter + "fhenix:file:"
+	endTag    = delimiter + "fhenix:endfile" + delimiter
+)
+
+// File is a file emitted by a template render.
+type File struct {
+	// Path is the path of the file, as given to the 'file' function.
+	Path string
+
+	// Content is the rendered content of the file.
+	Content []byte
+}
+
+// Funcs returns the 'file' and 'endfile' template functions.
+func Funcs() map[string]any {
+	return map[string]any{
+		"file":    Begin,
+		"endfile": End,
+	}
+}
+
+// Begin returns the marker that begins a file block for the given path.
+func Begin(path string) string {
+	return beginTag + hex.EncodeToString([]byte(path)) + delimiter
+}
+
+// End returns the marker that ends a file block.
+func End() string {
+	return endTag
+}
+
+// Split separates the rendered content into the primary content, which is all
+// content outside of file blocks, and the emitted files in the order that they
+// were rendered.
+func Split(content []byte) ([]byte, []*File, error) {
+	rest := string(content)
+	if !strings.Contains(rest, delimiter) {
+		return content, nil, nil
+	}
+
+	var primary strings.Builder
+	var files []*File
+	seen := map[string]struct{}{}
+	for {
+		begin := strings.Index(rest, beginTag)
+		end := strings.Index(rest, endTag)
+		if begin < 0 {
+			if end >= 0 {
+				return nil, nil, ErrUnexpectedEnd
+			}
+			primary.WriteString(rest)
+			return []byte(primary.String()), files, nil
+		}
+		if end >= 0 && end < begin {
+			return nil, nil, ErrUnexpectedEnd
+		}
+		primary.WriteString(rest[:begin])
+		rest = rest[begin+len(beginTag):]
+
+		index := strings.Index(rest, delimiter)
+		if index < 0 {
+			return nil, nil, ErrUnterminated
+		}
+		decoded, err := hex.DecodeString(rest[:index])
+		if err != nil {
+			return nil, nil, err
+		}
+		path := strings.TrimSpace(string(decoded))
+		if path == "" {
+			return nil, nil, ErrEmptyPath
+		}
+		if _, ok := seen[path]; ok {
+			return nil, nil, fmt.Errorf("%w: '%v'", ErrDuplicate, path)
+		}
+		seen[path] = struct{}{}
+		rest = rest[index+len(delimiter):]
+
+		end = strings.Index(rest, endTag)
+		if end < 0 {
+			return nil, nil, fmt.Errorf("%w: '%v'", ErrUnterminated, path)
+		}
+		if nested := strings.Index(rest, beginTag); nested >= 0 && nested < end {
+			return nil, nil, fmt.Errorf("%w: '%v'", ErrNested, path)
+		}
+		files = append(files, &File{Path: path, Content: []byte(rest[:end])})
+		rest = rest[end+len(endTag):]
+	}
+}
@@ -0,0 +1,76 @@
+package emit_test
+
+import (
+	"testing"
+
+	"github.com/friendly-fhir/fhenix/pkg/transform/internal/emit"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestSplit(t *testing.T) {
+	testCases := []struct {
+		name        string
+		input       string
+		wantPrimary string
+		wantFiles   []*emit.File
+		wantErr     error
+	}{
+		{
+			name:        "no file blocks",
+			input:       "primary",
+			wantPrimary: "primary",
+		}, {
+			name:        "file blocks are separated from primary content",
+			input:       "a" + emit.Begin("x.h") + "header" + emit.End() + "b" + emit.Begin("dir/x.cc") + "impl" + emit.End() + "c",
+			wantPrimary: "abc",
+			wantFiles: []*emit.File{
+				{Path: "x.h", Content: []byte("header")},
+				{Path: "dir/x.cc", Content: []byte("impl")},
+			},
+		}, {
+			name:        "path with special characters",
+			input:       emit.Begin("a <b> & 'c'.txt") + "content" + emit.End(),
+			wantPrimary: "",
+			wantFiles: []*emit.File{
+				{Path: "a <b> & 'c'.txt", Content: []byte("content")},
+			},
+		}, {
+			name:    "unterminated",
+			input:   emit.Begin("x.h") + "header",
+			wantErr: emit.ErrUnterminated,
+		}, {
+			name:    "nested",
+			input:   emit.Begin("x.h") + emit.Begin("y.h") + emit.End() + emit.End(),
+			wantErr: emit.ErrNested,
+		}, {
+			name:    "unexpected end",
+			input:   "primary" + emit.End(),
+			wantErr: emit.ErrUnexpectedEnd,
+		}, {
+			name:    "empty path",
+			input:   emit.Begin(" ") + emit.End(),
+			wantErr: emit.ErrEmptyPath,
+		}, {
+			name:    "duplicate path",
+			input:   emit.Begin("x.h") + emit.End() + emit.Begin("x.h") + emit.End(),
+			wantErr: emit.ErrDuplicate,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			primary, files, err := emit.Split([]byte(tc.input))
+
+			if got, want := err, tc.wantErr; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+				t.Fatalf("Split() = %v, want %v", got, want)
+			}
+			if got, want := string(primary), tc.wantPrimary; got != want {
+				t.Errorf("Split() primary = %q, want %q", got, want)
+			}
+			if got, want := files, tc.wantFiles; !cmp.Equal(got, want) {
+				t.Errorf("Split() files = %v, want %v", got, want)
+			}
+		})
+	}
+}
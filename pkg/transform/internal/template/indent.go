@@ -0,0 +1,264 @@
+package template
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Indent returns a new template engine for a dialect of text templates that is
+// aware of indentation, which makes it easier to generate deeply indented
+// languages.
+//
+// Lines that contain only control actions, such as 'if', 'range', 'end', or
+// variable assignments, produce no output at all -- including their
+// indentation and newline. The body of a block is dedented by how much further
+// its first line is indented than the line that opened the block, so that
+// control flow may be indented independently of the output:
+//
+//	func (x *{{ .Name }}) Validate() error {
+//		{{ range .Fields }}
+//			{{ if .Required }}
+//				if x.{{ .Name }} == nil {
+//					return errMissing
+//				}
+//			{{ end }}
+//		{{ end }}
+//		return nil
+//	}
+//
+// Actions that span multiple lines are treated as text.
+func Indent() Engine {
+	return indentTemplateEngine{}
+}
+
+type indentTemplate struct {
+	*texttemplate.Template
+}
+
+func (it *indentTemplate) New(name string) Template {
+	return &indentTemplate{it.Template.New(name)}
+}
+
+func (it *indentTemplate) Parse(content string) (Template, error) {
+	tmpl, err := it.Template.Parse(dedent(content))
+	if err != nil {
+		return nil, err
+	}
+	return &indentTemplate{tmpl}, nil
+}
+
+func (it *indentTemplate) ParseFile(name, file, content string) (Template, error) {
+	tmpl, err := it.Template.New(file).Parse(dedent(content))
+	if err != nil {
+		return nil, err
+	}
+	if tmpl, err = it.Template.AddParseTree(name, tmpl.Tree); err != nil {
+		return nil, err
+	}
+	return &indentTemplate{tmpl}, nil
+}
+
+func (it *indentTemplate) Execute(w io.Writer, v any) error {
+	return it.Template.Execute(w, v)
+}
+
+func (it *indentTemplate) Funcs(funcs map[string]any) Template {
+	return &indentTemplate{it.Template.Funcs(funcs)}
+}
+
+func (it *indentTemplate) Option(opt ...string) Template {
+	return &indentTemplate{it.Template.Option(opt...)}
+}
+
+var _ Template = (*indentTemplate)(nil)
+
+type indentTemplateEngine struct{}
+
+func (indentTemplateEngine) New(name string) Template {
+	return &indentTemplate{texttemplate.New(name)}
+}
+
+var _ Engine = (*indentTemplateEngine)(nil)
+
+// block is a block of an indented template that is currently open, such as
+// the body of an 'if' or 'range' action.
+type block struct {
+	// indent is the indentation of the line that opened the block.
+	indent int
+
+	// dedent is the indentation removed from each line of the body, or -1 if
+	// the first line of the body has not been seen yet.
+	dedent int
+}
+
+// dedent rewrites the source of an indented template into a text template.
+// The newline of each line of control actions is kept within a comment, so
+// that line numbers in errors still refer to the original source.
+func dedent(source string) string {
+	var sb strings.Builder
+	var blocks []*block
+	for _, line := range strings.SplitAfter(source, "\n") {
+		content := strings.TrimRight(line, " \t\r\n")
+		trimmed := strings.TrimLeft(content, " \t")
+		if trimmed == "" {
+			sb.WriteString(trimIndent(line, totalDedent(blocks)))
+			continue
+		}
+		indent := len(content) - len(trimmed)
+		if n := len(blocks); n > 0 && blocks[n-1].dedent < 0 {
+			blocks[n-1].dedent = max(0, indent-blocks[n-1].indent)
+		}
+
+		actions, ok := controlActions(trimmed)
+		if !ok {
+			sb.WriteString(trimIndent(line, totalDedent(blocks)))
+			continue
+		}
+		for _, action := range actions {
+			sb.WriteString(action.text)
+			switch action.kind {
+			case actionOpen:
+				blocks = append(blocks, &block{indent: indent, dedent: -1})
+			case actionElse:
+				if n := len(blocks); n > 0 {
+					blocks[n-1] = &block{indent: indent, dedent: -1}
+				}
+			case actionEnd:
+				if n := len(blocks); n > 0 {
+					blocks = blocks[:n-1]
+				}
+			}
+		}
+		if strings.HasSuffix(line, "\n") {
+			if strings.HasSuffix(actions[len(actions)-1].text, " -}}") {
+				sb.WriteString("{{/*\n*/ -}}")
+			} else {
+				sb.WriteString("{{/*\n*/}}")
+			}
+		}
+	}
+	return sb.String()
+}
+
+func totalDedent(blocks []*block) int {
+	var result int
+	for _, block := range blocks {
+		result += max(0, block.dedent)
+	}
+	return result
+}
+
+// trimIndent removes up to n characters of indentation from the line.
+func trimIndent(line string, n int) string {
+	for i := 0; i < n && len(line) > 0 && (line[0] == ' ' || line[0] == '\t'); i++ {
+		line = line[1:]
+	}
+	return line
+}
+
+type actionKind int
+
+const (
+	actionNone actionKind = iota
+	actionOpen
+	actionElse
+	actionEnd
+)
+
+type action struct {
+	text string
+	kind actionKind
+}
+
+// assignRegex matches actions that declare or assign a variable.
+var assignRegex = regexp.MustCompile(`^\$\w*\s*:?=`)
+
+// controlActions splits a line into its actions, if the line consists only of
+// actions that produce no output, optionally separated by whitespace.
+func controlActions(line string) ([]*action, bool) {
+	var result []*action
+	for line != "" {
+		if !strings.HasPrefix(line, "{{") {
+			return nil, false
+		}
+		end := closingDelim(line)
+		if end < 0 {
+			return nil, false
+		}
+		text := line[:end]
+		kind, ok := classify(text)
+		if !ok {
+			return nil, false
+		}
+		result = append(result, &action{text: text, kind: kind})
+		line = strings.TrimLeft(line[end:], " \t")
+	}
+	return result, true
+}
+
+// closingDelim returns the index just past the closing delimiter of the action
+// at the start of the line, or -1 if the action is not closed on this line.
+func closingDelim(line string) int {
+	inner := strings.TrimPrefix(line[2:], "- ")
+	if strings.HasPrefix(inner, "/*") {
+		i := strings.Index(line, "*/")
+		if i < 0 {
+			return -1
+		}
+		switch rest := line[i+2:]; {
+		case strings.HasPrefix(rest, "}}"):
+			return i + 4
+		case strings.HasPrefix(rest, " -}}"):
+			return i + 6
+		}
+		return -1
+	}
+	var quote byte
+	for i := 2; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"', c == '`', c == '\'':
+			quote = c
+		case strings.HasPrefix(line[i:], "}}"):
+			return i + 2
+		}
+	}
+	return -1
+}
+
+// classify returns the kind of the action, or false if the action may produce
+// output.
+func classify(text string) (actionKind, bool) {
+	inner := strings.TrimSuffix(strings.TrimPrefix(text, "{{"), "}}")
+	inner = strings.TrimPrefix(inner, "- ")
+	inner = strings.TrimSuffix(inner, " -")
+	inner = strings.TrimSpace(inner)
+	if strings.HasPrefix(inner, "/*") || assignRegex.MatchString(inner) {
+		return actionNone, true
+	}
+	keyword := inner
+	if i := strings.IndexFunc(inner, func(r rune) bool {
+		return !(r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9')
+	}); i >= 0 {
+		keyword = inner[:i]
+	}
+	switch keyword {
+	case "if", "range", "with", "define", "block":
+		return actionOpen, true
+	case "else":
+		return actionElse, true
+	case "end":
+		return actionEnd, true
+	case "break", "continue":
+		return actionNone, true
+	}
+	return actionNone, false
+}
@@ -0,0 +1,174 @@
+package template_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/friendly-fhir/fhenix/pkg/transform/internal/template"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIndent(t *testing.T) {
+	type field struct {
+		Name     string
+		Required bool
+	}
+	data := map[string]any{
+		"Name": "Patient",
+		"Fields": []field{
+			{Name: "ID", Required: true},
+			{Name: "Gender"},
+		},
+	}
+	testCases := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name: "control lines are removed",
+			content: strings.Join([]string{
+				"fields:",
+				"{{ range .Fields }}",
+				"- {{ .Name }}",
+				"{{ end }}",
+			}, "\n"),
+			want: strings.Join([]string{
+				"fields:",
+				"- ID",
+				"- Gender",
+				"",
+			}, "\n"),
+		}, {
+			name: "nested blocks are dedented",
+			content: strings.Join([]string{
+				"func (x *{{ .Name }}) Validate() error {",
+				"\t{{ range .Fields }}",
+				"\t\t{{ if .Required }}",
+				"\t\t\tif x.{{ .Name }} == nil {",
+				"\t\t\t\treturn errMissing",
+				"\t\t\t}",
+				"\t\t{{ end }}",
+				"\t{{ end }}",
+				"\treturn nil",
+				"}",
+			}, "\n"),
+			want: strings.Join([]string{
+				"func (x *Patient) Validate() error {",
+				"\tif x.ID == nil {",
+				"\t\treturn errMissing",
+				"\t}",
+				"\treturn nil",
+				"}",
+			}, "\n"),
+		}, {
+			name: "else branches are dedented",
+			content: strings.Join([]string{
+				"{{ range .Fields }}",
+				"  {{ if .Required }}",
+				"    required {{ .Name }}",
+				"  {{ else }}",
+				"    optional {{ .Name }}",
+				"  {{ end }}",
+				"{{ end }}",
+			}, "\n"),
+			want: strings.Join([]string{
+				"required ID",
+				"optional Gender",
+				"",
+			}, "\n"),
+		}, {
+			name: "body indented less than block is kept",
+			content: strings.Join([]string{
+				"  {{ with .Name }}",
+				"{{ . }}",
+				"  {{ end }}",
+			}, "\n"),
+			want: strings.Join([]string{
+				"Patient",
+				"",
+			}, "\n"),
+		}, {
+			name: "lines with output are kept",
+			content: strings.Join([]string{
+				"{{ range .Fields }}{{ .Name }} {{ end }}",
+				"  {{ template \"name\" . }}",
+				"{{ define \"name\" }}",
+				"  {{ .Name }}",
+				"{{ end }}",
+			}, "\n"),
+			want: strings.Join([]string{
+				"ID Gender ",
+				"  Patient",
+				"",
+				"",
+			}, "\n"),
+		}, {
+			name: "assignments and comments are removed",
+			content: strings.Join([]string{
+				"{{ $name := .Name }}",
+				"{{/* a comment */}}",
+				"name: {{ $name }}",
+			}, "\n"),
+			want: "name: Patient",
+		}, {
+			name: "strings containing delimiters",
+			content: strings.Join([]string{
+				"{{ if eq .Name \"}}\" }}",
+				"  unreachable",
+				"{{ end }}",
+				"done",
+			}, "\n"),
+			want: "done",
+		}, {
+			name: "trim markers",
+			content: strings.Join([]string{
+				"{{ range .Fields -}}",
+				"  {{ .Name }}",
+				"{{ end }}",
+			}, "\n"),
+			want: strings.Join([]string{
+				"ID",
+				"Gender",
+				"",
+			}, "\n"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl, err := template.Indent().New("test").Parse(tc.content)
+			if err != nil {
+				t.Fatalf("Parse() = %v", err)
+			}
+
+			var sb strings.Builder
+			if err := tmpl.Execute(&sb, data); err != nil {
+				t.Fatalf("Execute() = %v", err)
+			}
+
+			if got, want := sb.String(), tc.want; !cmp.Equal(got, want) {
+				t.Errorf("Execute() mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestIndent_ErrorLine(t *testing.T) {
+	content := strings.Join([]string{
+		"{{ range .Fields }}",
+		"  {{ if .Required }}",
+		"    {{ .Name | undefined }}",
+		"  {{ end }}",
+		"{{ end }}",
+	}, "\n")
+
+	_, err := template.Indent().New("test").Parse(content)
+
+	if err == nil {
+		t.Fatalf("Parse() = nil, want error")
+	}
+	if got, want := err.Error(), "test:3:"; !strings.Contains(got, want) {
+		t.Errorf("Parse() = %v, want error containing %q", got, want)
+	}
+}
@@ -1,6 +1,7 @@
 /*
 Package template is an abstraction over the builtin 'template' package by
-allowing either HTML or Text to be selected as the basic mechanism.
+allowing either HTML, Text, or indentation-aware Text to be selected as the
+basic mechanism.
 */
 package template
 
@@ -17,9 +18,16 @@ type Template interface {
 
 	Parse(string) (Template, error)
 
+	// ParseFile parses the content of a file as the template with the given
+	// name. The template is also registered under the path of the file, which
+	// errors from parsing and executing it refer to.
+	ParseFile(name, file, content string) (Template, error)
+
 	Execute(w io.Writer, v any) error
 
 	Funcs(funcs map[string]any) Template
+
+	Option(opt ...string) Template
 }
 
 // FromString returns a new TemplateEngine based on the given string.
@@ -29,6 +37,8 @@ func FromString(str string) (Engine, error) {
 		return Text(), nil
 	case "html":
 		return HTML(), nil
+	case "indent":
+		return Indent(), nil
 	}
 	return nil, fmt.Errorf("unknown template mode: %s", str)
 }
@@ -69,6 +79,17 @@ func (tt *textTemplate) Parse(content string) (Template, error) {
 	return &textTemplate{tmpl}, nil
 }
 
+func (tt *textTemplate) ParseFile(name, file, content string) (Template, error) {
+	tmpl, err := tt.Template.New(file).Parse(content)
+	if err != nil {
+		return nil, err
+	}
+	if tmpl, err = tt.Template.AddParseTree(name, tmpl.Tree); err != nil {
+		return nil, err
+	}
+	return &textTemplate{tmpl}, nil
+}
+
 func (tt *textTemplate) Execute(w io.Writer, v any) error {
 	return tt.Template.Execute(w, v)
 }
@@ -77,6 +98,10 @@ func (tt *textTemplate) Funcs(funcs map[string]any) Template {
 	return &textTemplate{tt.Template.Funcs(funcs)}
 }
 
+func (tt *textTemplate) Option(opt ...string) Template {
+	return &textTemplate{tt.Template.Option(opt...)}
+}
+
 type htmlTemplate struct {
 	*htmltemplate.Template
 }
@@ -93,6 +118,17 @@ func (ht *htmlTemplate) Parse(content string) (Template, error) {
 	return &htmlTemplate{tmpl}, nil
 }
 
+func (ht *htmlTemplate) ParseFile(name, file, content string) (Template, error) {
+	tmpl, err := ht.Template.New(file).Parse(content)
+	if err != nil {
+		return nil, err
+	}
+	if tmpl, err = ht.Template.AddParseTree(name, tmpl.Tree); err != nil {
+		return nil, err
+	}
+	return &htmlTemplate{tmpl}, nil
+}
+
 func (ht *htmlTemplate) Execute(w io.Writer, v any) error {
 	return ht.Template.Execute(w, v)
 }
@@ -101,6 +137,10 @@ func (ht *htmlTemplate) Funcs(funcs map[string]any) Template {
 	return &htmlTemplate{ht.Template.Funcs(funcs)}
 }
 
+func (ht *htmlTemplate) Option(opt ...string) Template {
+	return &htmlTemplate{ht.Template.Option(opt...)}
+}
+
 var _ Template = (*htmlTemplate)(nil)
 
 type textTemplateEngine struct{}
@@ -1,6 +1,8 @@
 package template_test
 
 import (
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/friendly-fhir/fhenix/pkg/transform/internal/template"
@@ -28,6 +30,10 @@ func TestFromString(t *testing.T) {
 			name: "html template",
 			in:   "html",
 			want: template.HTML(),
+		}, {
+			name: "indent template",
+			in:   "indent",
+			want: template.Indent(),
 		},
 	}
 
@@ -44,3 +50,47 @@ func TestFromString(t *testing.T) {
 		})
 	}
 }
+
+func TestParseFile(t *testing.T) {
+	testCases := []struct {
+		name    string
+		engine  template.Engine
+		content string
+		wantErr string
+	}{
+		{
+			name:    "text template",
+			engine:  template.Text(),
+			content: "Hello\n{{ .Missing.Field }}",
+			wantErr: "template: templates/main.tmpl:2:11: ",
+		}, {
+			name:    "html template",
+			engine:  template.HTML(),
+			content: "Hello\n{{ .Missing.Field }}",
+			wantErr: "template: templates/main.tmpl:2:11: ",
+		}, {
+			name:    "indent template",
+			engine:  template.Indent(),
+			content: "{{ if true }}\n  {{ .Missing.Field }}\n{{ end }}",
+			wantErr: "template: templates/main.tmpl:2:",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl := tc.engine.New("entry").Option("missingkey=error")
+			if _, err := tmpl.Parse(`{{ template "main" . }}`); err != nil {
+				t.Fatalf("Parse() = %v", err)
+			}
+			if _, err := tmpl.ParseFile("main", "templates/main.tmpl", tc.content); err != nil {
+				t.Fatalf("ParseFile() = %v", err)
+			}
+
+			err := tmpl.Execute(io.Discard, map[string]any{})
+
+			if err == nil || !strings.HasPrefix(err.Error(), tc.wantErr) {
+				t.Errorf("Execute() = %v, want error prefixed by %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package transformer
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ReadFile reads the named template file. Relative names are read from the
+// file system, if one is given; all other names are read from the local file
+// system.
+func ReadFile(fsys fs.FS, name string) ([]byte, error) {
+	if fsys != nil && !filepath.IsAbs(name) {
+		return fs.ReadFile(fsys, name)
+	}
+	return os.ReadFile(name)
+}
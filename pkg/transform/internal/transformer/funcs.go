@@ -1,40 +1,130 @@
 package transformer
 
 import (
-	"os"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	texttemplate "text/template"
 
 	"github.com/friendly-fhir/fhenix/internal/templatefuncs"
 )
 
-func NewFunc(path string, reporter templatefuncs.Reporter) (func(...any) string, error) {
-	fntmpl := texttemplate.New("").Funcs(templatefuncs.NewFuncs(reporter))
+// ErrFuncReturns is an error returned when the return type declared by a func
+// template is invalid.
+var ErrFuncReturns = errors.New("invalid func returns")
+
+// returnsRegex matches the comment at the start of a func template that
+// declares its return type, such as '{{/* returns: bool */}}'.
+var returnsRegex = regexp.MustCompile(`^\{\{-?\s*/\*\s*returns:\s*(\S*)\s*\*/\s*-?\}\}`)
+
+// returnTypes are the types that func templates may declare that they return,
+// along with how the type is parsed from the rendered output.
+var returnTypes = map[string]func(string) (any, error){
+	"string": func(s string) (any, error) {
+		return s, nil
+	},
+	"bool": func(s string) (any, error) {
+		return strconv.ParseBool(s)
+	},
+	"int": func(s string) (any, error) {
+		return strconv.Atoi(s)
+	},
+	"float": func(s string) (any, error) {
+		return strconv.ParseFloat(s, 64)
+	},
+	"list": func(s string) (any, error) {
+		result := []string{}
+		for _, line := range strings.Split(s, "\n") {
+			if line := strings.TrimSpace(line); line != "" {
+				result = append(result, line)
+			}
+		}
+		return result, nil
+	},
+	"json": func(s string) (any, error) {
+		var result any
+		err := json.Unmarshal([]byte(s), &result)
+		return result, err
+	},
+}
+
+// NewFunc creates a template function from the template file at the given
+// path, which is read from the file system if it is relative and a file system
+// is given. Additional template functions may be made available to the
+// template.
+//
+// The rendered output is trimmed of surrounding whitespace. A func template may
+// declare that it returns a type other than string in a comment at its start,
+// such as '{{/* returns: bool */}}', in which case the output is parsed as that
+// type. Types may be string, bool, int, float, list (of non-empty lines), or
+// json.
+//
+// Errors from executing the template or parsing its output are reported to the
+// reporter, in which case the zero value of the type is returned; if the func
+// is strict, they are returned instead, which fails the calling template.
+func NewFunc(path string, opts ...Option) (func(...any) (any, error), error) {
+	var cfg config
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	fntmpl := texttemplate.New(path).Funcs(templatefuncs.NewFuncs(cfg.reporter)).Funcs(cfg.funcs)
+	if cfg.strict {
+		fntmpl = fntmpl.Option("missingkey=error")
+	}
 
 	var err error
-	bytes, err := os.ReadFile(path)
+	bytes, err := ReadFile(cfg.fsys, path)
 	if err != nil {
 		return nil, err
 	}
+	returns := "string"
+	if match := returnsRegex.FindSubmatch(bytes); match != nil {
+		returns = string(match[1])
+	}
+	parse, ok := returnTypes[returns]
+	if !ok {
+		return nil, fmt.Errorf("%v: %w: unknown type '%v'", path, ErrFuncReturns, returns)
+	}
+	zero, _ := parse("")
 	if fntmpl, err = fntmpl.Parse(string(bytes)); err != nil {
 		return nil, err
 	}
-	return func(data ...any) string {
+	return func(data ...any) (any, error) {
 		var in any
 		if len(data) == 1 {
 			in = data[0]
 		}
 		var sb strings.Builder
-		_ = fntmpl.Execute(&sb, in)
-		result := sb.String()
-		return strings.TrimSpace(result)
+		err := fntmpl.Execute(&sb, in)
+		var result any
+		if err == nil {
+			result, err = parse(strings.TrimSpace(sb.String()))
+		}
+		if err != nil {
+			return zero, cfg.fail(fmt.Errorf("%v: %w", path, err))
+		}
+		return result, nil
 	}, nil
 }
 
-func FuncsFromConfig(funcs map[string]string, reporter templatefuncs.Reporter) (map[string]any, error) {
+// FuncsFromConfig creates the template functions from a mapping of function
+// names to template files, which are read as with [NewFunc], or to script
+// files with the [ScriptExt] extension, which are read as with
+// [NewScriptFunc].
+func FuncsFromConfig(funcs map[string]string, opts ...Option) (map[string]any, error) {
 	result := make(map[string]any, len(funcs))
 	for name, path := range funcs {
-		fn, err := NewFunc(path, reporter)
+		var fn any
+		var err error
+		if filepath.Ext(path) == ScriptExt {
+			fn, err = NewScriptFunc(path, opts...)
+		} else {
+			fn, err = NewFunc(path, opts...)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -2,8 +2,10 @@ package transformer_test
 
 import (
 	"io/fs"
+	"strconv"
 	"testing"
 
+	"github.com/friendly-fhir/fhenix/internal/templatefuncs"
 	"github.com/friendly-fhir/fhenix/pkg/transform/internal/transformer"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -14,7 +16,7 @@ func TestNewFunc(t *testing.T) {
 		name    string
 		path    string
 		input   any
-		want    string
+		want    any
 		wantErr error
 	}{
 		{
@@ -30,27 +32,114 @@ func TestNewFunc(t *testing.T) {
 			name:    "file does not exist",
 			path:    "testdata/does-not-exist.tmpl",
 			wantErr: fs.ErrNotExist,
+		}, {
+			name:    "unknown return type",
+			path:    "testdata/bad-returns.tmpl",
+			wantErr: transformer.ErrFuncReturns,
+		}, {
+			name:  "returns bool",
+			path:  "testdata/required.tmpl",
+			input: map[string]any{"Required": true},
+			want:  true,
+		}, {
+			name:  "returns int",
+			path:  "testdata/count.tmpl",
+			input: []string{"a", "b", "c"},
+			want:  3,
+		}, {
+			name:  "returns list",
+			path:  "testdata/snake-list.tmpl",
+			input: []string{"FooBar", "BazQux"},
+			want:  []string{"foo_bar", "baz_qux"},
+		}, {
+			name:  "returns json",
+			path:  "testdata/roundtrip.tmpl",
+			input: map[string]any{"a": []int{1}},
+			want:  map[string]any{"a": []any{1.0}},
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			fn, err := transformer.NewFunc(tc.path, nil)
+			fn, err := transformer.NewFunc(tc.path)
 			if got, want := err, tc.wantErr; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
 				t.Fatalf("NewFunc() = %v, want %v", got, want)
 			}
+			if err != nil {
+				return
+			}
 
-			got := zeroFn(fn)(tc.input)
+			got, err := fn(tc.input)
+			if err != nil {
+				t.Fatalf("NewFunc()(...) = %v, want nil", err)
+			}
 			if want := tc.want; !cmp.Equal(got, want) {
-				t.Errorf("NewFunc() = %v, want %v", got, want)
+				t.Errorf("NewFunc()(...) = %v, want %v", got, want)
 			}
 		})
 	}
 }
 
-func zeroFn(fn func(...any) string) func(...any) string {
-	if fn == nil {
-		return func(...any) string { return "" }
+func TestNewFunc_Errors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		path       string
+		input      any
+		strict     bool
+		want       any
+		wantErr    error
+		wantReport error
+	}{
+		{
+			name:       "execution error is reported",
+			path:       "testdata/exec-error.tmpl",
+			want:       "",
+			wantReport: cmpopts.AnyError,
+		}, {
+			name:       "parse error is reported with zero value",
+			path:       "testdata/required.tmpl",
+			input:      map[string]any{"Required": "maybe"},
+			want:       false,
+			wantReport: strconv.ErrSyntax,
+		}, {
+			name:    "execution error fails when strict",
+			path:    "testdata/exec-error.tmpl",
+			strict:  true,
+			want:    "",
+			wantErr: cmpopts.AnyError,
+		}, {
+			name:    "parse error fails when strict",
+			path:    "testdata/required.tmpl",
+			input:   map[string]any{"Required": "maybe"},
+			strict:  true,
+			want:    false,
+			wantErr: strconv.ErrSyntax,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var report error
+			reporter := templatefuncs.ReporterFunc(func(err error) { report = err })
+			fn, err := transformer.NewFunc(tc.path,
+				transformer.WithReporter(reporter),
+				transformer.WithStrict(tc.strict),
+			)
+			if err != nil {
+				t.Fatalf("NewFunc() = %v", err)
+			}
+
+			got, err := fn(tc.input)
+
+			if got, want := err, tc.wantErr; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+				t.Errorf("NewFunc()(...) = error %v, want %v", got, want)
+			}
+			if got, want := report, tc.wantReport; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+				t.Errorf("NewFunc()(...) reported %v, want %v", got, want)
+			}
+			if want := tc.want; !cmp.Equal(got, want) {
+				t.Errorf("NewFunc()(...) = %v, want %v", got, want)
+			}
+		})
 	}
-	return fn
 }
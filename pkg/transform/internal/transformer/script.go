@@ -0,0 +1,231 @@
+package transformer
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/friendly-fhir/fhenix/internal/templatefuncs"
+)
+
+// ScriptExt is the file extension of funcs that are written as scripts in the
+// expr language (https://expr-lang.org), rather than as templates.
+const ScriptExt = ".expr"
+
+var (
+	// ErrScriptParams is an error returned when the parameters declared by a
+	// script are invalid.
+	ErrScriptParams = errors.New("invalid script params")
+
+	// ErrScriptArgs is an error reported when a script is called with arguments
+	// that do not match its declared parameters.
+	ErrScriptArgs = errors.New("invalid script arguments")
+)
+
+// paramsRegex matches the comment at the start of a script that declares its
+// parameters, such as '// params: type any, prefix string'.
+var paramsRegex = regexp.MustCompile(`^\s*//\s*params:(.*)`)
+
+// paramTypes are the types that script parameters may be declared with.
+var paramTypes = map[string]reflect.Type{
+	"any":    reflect.TypeOf((*any)(nil)).Elem(),
+	"string": reflect.TypeOf(""),
+	"int":    reflect.TypeOf(0),
+	"float":  reflect.TypeOf(0.0),
+	"bool":   reflect.TypeOf(false),
+	"list":   reflect.TypeOf([]any(nil)),
+	"map":    reflect.TypeOf(map[string]any(nil)),
+}
+
+type param struct {
+	name string
+	kind string
+}
+
+// NewScriptFunc creates a template function from the expr script at the given
+// path, which is read from the file system if it is relative and a file system
+// is given. Additional template functions may be made available to the script,
+// where template modules such as 'string' are available by name.
+//
+// The arguments of the function are available to the script as 'args'. A
+// script may also declare named and typed parameters in a comment on its first
+// line, such as '// params: type any, prefix string', which are type-checked
+// when the script is compiled and when it is called. Parameters may be of type
+// any, string, int, float, bool, list, or map.
+//
+// The value of the script is returned as-is, so it may be any type. Errors are
+// handled as with [NewFunc], in which case the function returns nil.
+func NewScriptFunc(path string, opts ...Option) (func(...any) (any, error), error) {
+	var cfg config
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	bytes, err := ReadFile(cfg.fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	source := string(bytes)
+	params, err := parseParams(source)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+
+	globals := map[string]any{}
+	for _, funcs := range []map[string]any{templatefuncs.NewFuncs(cfg.reporter), cfg.funcs} {
+		for name, fn := range funcs {
+			if fn != nil {
+				globals[name] = scriptGlobal(fn)
+			}
+		}
+	}
+
+	fields := []reflect.StructField{{Name: "Args", Type: paramTypes["list"], Tag: `expr:"args"`}}
+	for i, param := range params {
+		if _, ok := globals[param.name]; ok || param.name == "args" {
+			return nil, fmt.Errorf("%v: %w: param '%v' shadows a func", path, ErrScriptParams, param.name)
+		}
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("Param%d", i),
+			Type: paramTypes[param.kind],
+			Tag:  reflect.StructTag(fmt.Sprintf("expr:%q", param.name)),
+		})
+	}
+	names := make([]string, 0, len(globals))
+	for name, global := range globals {
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("Global%d", len(names)),
+			Type: reflect.TypeOf(global),
+			Tag:  reflect.StructTag(fmt.Sprintf("expr:%q", name)),
+		})
+		names = append(names, name)
+	}
+	envType := reflect.StructOf(fields)
+
+	program, err := expr.Compile(source, expr.Env(reflect.New(envType).Elem().Interface()))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+
+	fail := func(err error) (any, error) {
+		return nil, cfg.fail(fmt.Errorf("%v: %w", path, err))
+	}
+	return func(args ...any) (any, error) {
+		env := reflect.New(envType).Elem()
+		env.Field(0).Set(reflect.ValueOf(args))
+		if len(params) > 0 && len(args) != len(params) {
+			return fail(fmt.Errorf("%w: got %d, want %d", ErrScriptArgs, len(args), len(params)))
+		}
+		for i, param := range params {
+			value, err := convertArg(args[i], param.kind)
+			if err != nil {
+				return fail(fmt.Errorf("%w: param '%v': %w", ErrScriptArgs, param.name, err))
+			}
+			if value.IsValid() {
+				env.Field(1 + i).Set(value)
+			}
+		}
+		for i, name := range names {
+			env.Field(1 + len(params) + i).Set(reflect.ValueOf(globals[name]))
+		}
+		result, err := vm.Run(program, env.Interface())
+		if err != nil {
+			return fail(err)
+		}
+		return result, nil
+	}, nil
+}
+
+// parseParams parses the parameters declared on the first line of the script,
+// if any.
+func parseParams(source string) ([]*param, error) {
+	line, _, _ := strings.Cut(source, "\n")
+	match := paramsRegex.FindStringSubmatch(line)
+	if match == nil {
+		return nil, nil
+	}
+	var result []*param
+	seen := map[string]struct{}{}
+	for _, decl := range strings.Split(match[1], ",") {
+		parts := strings.Fields(decl)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%w: '%v', expected '<name> <type>'", ErrScriptParams, strings.TrimSpace(decl))
+		}
+		if _, ok := paramTypes[parts[1]]; !ok {
+			return nil, fmt.Errorf("%w: unknown type '%v' of param '%v'", ErrScriptParams, parts[1], parts[0])
+		}
+		if _, ok := seen[parts[0]]; ok {
+			return nil, fmt.Errorf("%w: duplicate param '%v'", ErrScriptParams, parts[0])
+		}
+		seen[parts[0]] = struct{}{}
+		result = append(result, &param{name: parts[0], kind: parts[1]})
+	}
+	return result, nil
+}
+
+// scriptGlobal returns the value of a template function within a script.
+// Template modules, which are functions that return the module, are replaced
+// by the module itself so that their methods may be called directly.
+func scriptGlobal(fn any) any {
+	value := reflect.ValueOf(fn)
+	if value.Kind() == reflect.Func && value.Type().NumIn() == 0 && value.Type().NumOut() == 1 {
+		return value.Call(nil)[0].Interface()
+	}
+	return fn
+}
+
+// convertArg converts the argument to the declared type of a parameter. An
+// invalid value is returned for a nil argument of any type.
+func convertArg(arg any, kind string) (reflect.Value, error) {
+	value := reflect.ValueOf(arg)
+	if kind == "any" {
+		return value, nil
+	}
+	switch kind {
+	case "string":
+		if value.Kind() == reflect.String {
+			return reflect.ValueOf(value.String()), nil
+		}
+	case "int":
+		switch {
+		case value.CanInt():
+			return reflect.ValueOf(int(value.Int())), nil
+		case value.CanUint():
+			return reflect.ValueOf(int(value.Uint())), nil
+		}
+	case "float":
+		switch {
+		case value.CanFloat():
+			return reflect.ValueOf(value.Float()), nil
+		case value.CanInt():
+			return reflect.ValueOf(float64(value.Int())), nil
+		case value.CanUint():
+			return reflect.ValueOf(float64(value.Uint())), nil
+		}
+	case "bool":
+		if value.Kind() == reflect.Bool {
+			return reflect.ValueOf(value.Bool()), nil
+		}
+	case "list":
+		if value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+			result := make([]any, value.Len())
+			for i := range result {
+				result[i] = value.Index(i).Interface()
+			}
+			return reflect.ValueOf(result), nil
+		}
+	case "map":
+		if value.Kind() == reflect.Map && value.Type().Key().Kind() == reflect.String {
+			result := make(map[string]any, value.Len())
+			iter := value.MapRange()
+			for iter.Next() {
+				result[iter.Key().String()] = iter.Value().Interface()
+			}
+			return reflect.ValueOf(result), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("got %T, want %v", arg, kind)
+}
@@ -0,0 +1,112 @@
+package transformer_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/friendly-fhir/fhenix/internal/templatefuncs"
+	"github.com/friendly-fhir/fhenix/pkg/transform/internal/transformer"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestNewScriptFunc(t *testing.T) {
+	type field struct {
+		Required bool
+	}
+	testCases := []struct {
+		name       string
+		path       string
+		input      []any
+		want       any
+		wantErr    error
+		wantReport error
+	}{
+		{
+			name:    "file does not exist",
+			path:    "testdata/does-not-exist.expr",
+			wantErr: fs.ErrNotExist,
+		}, {
+			name:    "invalid params",
+			path:    "testdata/bad-params.expr",
+			wantErr: transformer.ErrScriptParams,
+		}, {
+			name:    "params are type-checked",
+			path:    "testdata/bad.expr",
+			wantErr: cmpopts.AnyError,
+		}, {
+			name:  "returns string",
+			path:  "testdata/join.expr",
+			input: []any{[]string{"a", "b"}, ", "},
+			want:  "A, B",
+		}, {
+			name:  "returns list",
+			path:  "testdata/plural.expr",
+			input: []any{"FooBar", 2},
+			want:  []any{"foo_bar", "foo_bars"},
+		}, {
+			name:  "returns bool",
+			path:  "testdata/required.expr",
+			input: []any{&field{Required: true}},
+			want:  true,
+		}, {
+			name:       "wrong argument count is reported",
+			path:       "testdata/plural.expr",
+			input:      []any{"FooBar"},
+			wantReport: transformer.ErrScriptArgs,
+		}, {
+			name:       "wrong argument type is reported",
+			path:       "testdata/plural.expr",
+			input:      []any{"FooBar", "2"},
+			wantReport: transformer.ErrScriptArgs,
+		}, {
+			name:       "runtime error is reported",
+			path:       "testdata/required.expr",
+			input:      []any{"not a field"},
+			wantReport: cmpopts.AnyError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var report error
+			reporter := templatefuncs.ReporterFunc(func(err error) { report = err })
+
+			fn, err := transformer.NewScriptFunc(tc.path, transformer.WithReporter(reporter))
+			if got, want := err, tc.wantErr; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+				t.Fatalf("NewScriptFunc() = %v, want %v", got, want)
+			}
+			if err != nil {
+				return
+			}
+
+			got, err := fn(tc.input...)
+			if err != nil {
+				t.Fatalf("NewScriptFunc()(...) = %v, want nil", err)
+			}
+			if got, want := report, tc.wantReport; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+				t.Fatalf("NewScriptFunc()(...) reported %v, want %v", got, want)
+			}
+			if want := tc.want; !cmp.Equal(got, want) {
+				t.Errorf("NewScriptFunc()(...) = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestFuncsFromConfig_Script(t *testing.T) {
+	funcs, err := transformer.FuncsFromConfig(map[string]string{
+		"join": "testdata/join.expr",
+	})
+	if err != nil {
+		t.Fatalf("FuncsFromConfig() = %v", err)
+	}
+
+	fn, ok := funcs["join"].(func(...any) (any, error))
+	if !ok {
+		t.Fatalf("FuncsFromConfig() = %T, want script func", funcs["join"])
+	}
+	if got, _ := fn([]any{"x"}, "-"); got != "X" {
+		t.Errorf("join() = %v, want %v", got, "X")
+	}
+}
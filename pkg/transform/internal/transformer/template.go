@@ -1,9 +1,10 @@
 package transformer
 
 import (
-	"os"
+	"io/fs"
 
 	"github.com/friendly-fhir/fhenix/internal/templatefuncs"
+	"github.com/friendly-fhir/fhenix/pkg/transform/internal/emit"
 	"github.com/friendly-fhir/fhenix/pkg/transform/internal/template"
 )
 
@@ -19,11 +20,29 @@ const (
 	DefaultEntryTemplate string = `
 {{- template "header" . }}{{ template "main" . }}{{ template "footer" . -}}
 `
+
+	// EntryTemplateName is the name of the template that is executed, which
+	// renders the [DefaultEntryTemplate].
+	EntryTemplateName string = "entry"
 )
 
 type config struct {
 	funcs    map[string]any
 	reporter templatefuncs.Reporter
+	fsys     fs.FS
+	strict   bool
+}
+
+// fail handles an error from a func, which is returned if strict, and is
+// otherwise reported.
+func (c *config) fail(err error) error {
+	if c.strict {
+		return err
+	}
+	if c.reporter != nil {
+		c.reporter.Report(err)
+	}
+	return nil
 }
 
 type Option interface {
@@ -48,13 +67,33 @@ func WithReporter(r templatefuncs.Reporter) Option {
 	})
 }
 
+// WithFS returns an [Option] that reads relative template paths from the given
+// file system.
+func WithFS(fsys fs.FS) Option {
+	return option(func(c *config) {
+		c.fsys = fsys
+	})
+}
+
+// WithStrict returns an [Option] that fails the calling template when a func
+// has an error, rather than reporting the error, and that fails templates that
+// index a map with a missing key.
+func WithStrict(strict bool) Option {
+	return option(func(c *config) {
+		c.strict = strict
+	})
+}
+
 // NewTemplate creates a new template using the underlying template engine.
 func NewTemplate(engine template.Engine, templates map[string]string, opts ...Option) (template.Template, error) {
 	var cfg config
 	for _, opt := range opts {
 		opt.apply(&cfg)
 	}
-	tmpl := engine.New("").Funcs(templatefuncs.NewFuncs(cfg.reporter)).Funcs(cfg.funcs)
+	tmpl := engine.New(EntryTemplateName).Funcs(templatefuncs.NewFuncs(cfg.reporter)).Funcs(emit.Funcs()).Funcs(cfg.funcs)
+	if cfg.strict {
+		tmpl = tmpl.Option("missingkey=error")
+	}
 
 	defaults := map[string]string{
 		"main":                 DefaultMainTemplate,
@@ -66,7 +105,7 @@ func NewTemplate(engine template.Engine, templates map[string]string, opts ...Op
 	}
 
 	for name, path := range templates {
-		if err := parse(tmpl, name, path); err != nil {
+		if err := parse(cfg.fsys, tmpl, name, path); err != nil {
 			return nil, err
 		}
 	}
@@ -83,11 +122,24 @@ func NewTemplate(engine template.Engine, templates map[string]string, opts ...Op
 	return tmpl, nil
 }
 
-func parse(tmpl template.Template, name string, path string) error {
-	bytes, err := os.ReadFile(path)
+// StrictReporter is a [templatefuncs.Reporter] that fails the template that is
+// executing when an error is reported, such as by a template module. The error
+// is raised as a panic, which the template engine recovers and returns as an
+// execution error of the calling template.
+type StrictReporter struct{}
+
+// Report fails the executing template with the error.
+func (StrictReporter) Report(err error) {
+	panic(err)
+}
+
+var _ templatefuncs.Reporter = StrictReporter{}
+
+func parse(fsys fs.FS, tmpl template.Template, name string, path string) error {
+	bytes, err := ReadFile(fsys, path)
 	if err != nil {
 		return err
 	}
-	_, err = tmpl.New(name).Parse(string(bytes))
+	_, err = tmpl.ParseFile(name, path, string(bytes))
 	return err
 }
@@ -0,0 +1,158 @@
+/*
+Package postprocess provides processors that are applied to the rendered output
+of a transform before it is written, such as source formatters.
+*/
+package postprocess
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/format"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/friendly-fhir/fhenix/pkg/config"
+)
+
+// Processor transforms rendered output content.
+type Processor interface {
+	// Process returns the processed content of the output at the given path.
+	Process(ctx context.Context, path string, content []byte) ([]byte, error)
+}
+
+// ProcessorFunc is a function that implements [Processor].
+type ProcessorFunc func(ctx context.Context, path string, content []byte) ([]byte, error)
+
+func (f ProcessorFunc) Process(ctx context.Context, path string, content []byte) ([]byte, error) {
+	return f(ctx, path, content)
+}
+
+// Step is a named [Processor] of a [Pipeline].
+type Step struct {
+	Name string
+	Processor
+}
+
+// Pipeline is a sequence of steps, where the output of each step is the input
+// to the next.
+type Pipeline []*Step
+
+// Process runs each step of the pipeline in order. Errors are annotated with
+// the name of the step that failed.
+func (p Pipeline) Process(ctx context.Context, path string, content []byte) ([]byte, error) {
+	for _, step := range p {
+		var err error
+		content, err = step.Process(ctx, path, content)
+		if err != nil {
+			return nil, fmt.Errorf("post-process %s: %w", step.Name, err)
+		}
+	}
+	return content, nil
+}
+
+var _ Processor = (Pipeline)(nil)
+
+// ErrUnknownFormat is returned by [FromConfig] when a step names a format that
+// is not builtin.
+var ErrUnknownFormat = errors.New("unknown post-process format")
+
+var formats = map[string]Processor{
+	"gofmt":                    ProcessorFunc(Gofmt),
+	"json":                     ProcessorFunc(JSON),
+	"trim-trailing-whitespace": ProcessorFunc(TrimTrailingWhitespace),
+	"collapse-blank-lines":     ProcessorFunc(CollapseBlankLines),
+}
+
+// FromConfig creates the pipeline described by the given config steps.
+func FromConfig(steps []*config.PostProcess) (Pipeline, error) {
+	result := make(Pipeline, 0, len(steps))
+	for _, step := range steps {
+		if len(step.Command) > 0 {
+			result = append(result, &Step{
+				Name:      step.Command[0],
+				Processor: Command(step.Command[0], step.Command[1:]...),
+			})
+			continue
+		}
+		processor, ok := formats[step.Format]
+		if !ok {
+			return nil, fmt.Errorf("%w: '%v'", ErrUnknownFormat, step.Format)
+		}
+		result = append(result, &Step{Name: step.Format, Processor: processor})
+	}
+	return result, nil
+}
+
+// Gofmt formats the content as Go source code.
+func Gofmt(_ context.Context, _ string, content []byte) ([]byte, error) {
+	return format.Source(content)
+}
+
+// JSON pretty-prints the content as JSON, indented with two spaces.
+func JSON(_ context.Context, _ string, content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, bytes.TrimSpace(content), "", "  "); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// TrimTrailingWhitespace removes the trailing whitespace of every line, and
+// ensures that non-empty content ends with exactly one newline.
+func TrimTrailingWhitespace(_ context.Context, _ string, content []byte) ([]byte, error) {
+	lines := strings.Split(string(content), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	result := strings.TrimRight(strings.Join(lines, "\n"), "\n")
+	if result == "" {
+		return nil, nil
+	}
+	return []byte(result + "\n"), nil
+}
+
+// CollapseBlankLines replaces consecutive blank lines with a single blank line,
+// and removes blank lines at the start and end of the content.
+func CollapseBlankLines(_ context.Context, _ string, content []byte) ([]byte, error) {
+	var sb strings.Builder
+	blank := false
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		if strings.TrimSpace(line) == "" {
+			blank = sb.Len() > 0
+			continue
+		}
+		if blank {
+			sb.WriteString("\n")
+			blank = false
+		}
+		sb.WriteString(line)
+	}
+	return []byte(sb.String()), nil
+}
+
+// Command returns a [Processor] that runs an external command, which reads the
+// content from stdin and writes the processed content to stdout. The output
+// path is provided to the command in the FHENIX_OUTPUT_PATH environment
+// variable.
+func Command(name string, args ...string) Processor {
+	return ProcessorFunc(func(ctx context.Context, path string, content []byte) ([]byte, error) {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Env = append(os.Environ(), "FHENIX_OUTPUT_PATH="+path)
+		cmd.Stdin = bytes.NewReader(content)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return nil, fmt.Errorf("%w: %s", err, msg)
+			}
+			return nil, err
+		}
+		return stdout.Bytes(), nil
+	})
+}
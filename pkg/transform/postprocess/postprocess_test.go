@@ -0,0 +1,152 @@
+package postprocess_test
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/friendly-fhir/fhenix/pkg/config"
+	"github.com/friendly-fhir/fhenix/pkg/transform/postprocess"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestFromConfig(t *testing.T) {
+	testCases := []struct {
+		name    string
+		steps   []*config.PostProcess
+		input   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "no steps",
+			input: "unchanged  \n",
+			want:  "unchanged  \n",
+		}, {
+			name: "gofmt",
+			steps: []*config.PostProcess{
+				{Format: "gofmt"},
+			},
+			input: strings.Join([]string{
+				"package foo",
+				"type Foo struct{",
+				"A int",
+				"LongName string",
+				"}",
+			}, "\n") + "\n",
+			want: strings.Join([]string{
+				"package foo",
+				"",
+				"type Foo struct {",
+				"\tA        int",
+				"\tLongName string",
+				"}",
+			}, "\n") + "\n",
+		}, {
+			name: "gofmt invalid source",
+			steps: []*config.PostProcess{
+				{Format: "gofmt"},
+			},
+			input:   "package foo\nfunc {",
+			wantErr: cmpopts.AnyError,
+		}, {
+			name: "json",
+			steps: []*config.PostProcess{
+				{Format: "json"},
+			},
+			input: `  {"a":[1,2],"b":{}}  `,
+			want: strings.Join([]string{
+				`{`,
+				`  "a": [`,
+				`    1,`,
+				`    2`,
+				`  ],`,
+				`  "b": {}`,
+				`}`,
+			}, "\n") + "\n",
+		}, {
+			name: "json invalid",
+			steps: []*config.PostProcess{
+				{Format: "json"},
+			},
+			input:   `{"a":`,
+			wantErr: cmpopts.AnyError,
+		}, {
+			name: "trim trailing whitespace",
+			steps: []*config.PostProcess{
+				{Format: "trim-trailing-whitespace"},
+			},
+			input: "a  \nb\t\r\n\n\n",
+			want:  "a\nb\n",
+		}, {
+			name: "collapse blank lines",
+			steps: []*config.PostProcess{
+				{Format: "collapse-blank-lines"},
+			},
+			input: "\n\na\n\n\n  \nb\n\n",
+			want:  "a\n\nb\n",
+		}, {
+			name: "steps run in order",
+			steps: []*config.PostProcess{
+				{Format: "trim-trailing-whitespace"},
+				{Format: "collapse-blank-lines"},
+			},
+			input: "a\n \n\t\nb \n",
+			want:  "a\n\nb\n",
+		}, {
+			name: "unknown format",
+			steps: []*config.PostProcess{
+				{Format: "unknown"},
+			},
+			wantErr: postprocess.ErrUnknownFormat,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pipeline, err := postprocess.FromConfig(tc.steps)
+			if err == nil {
+				var got []byte
+				got, err = pipeline.Process(context.Background(), "out.txt", []byte(tc.input))
+				if err == nil && string(got) != tc.want {
+					t.Errorf("Pipeline.Process() = %q, want %q", got, tc.want)
+				}
+			}
+
+			if got, want := err, tc.wantErr; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+				t.Errorf("Pipeline.Process() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	sut := postprocess.Command("sh", "-c", `tr a-z A-Z; printf "%s" "$FHENIX_OUTPUT_PATH"`)
+
+	got, err := sut.Process(context.Background(), "out.txt", []byte("hello\n"))
+
+	if err != nil {
+		t.Fatalf("Command().Process() = %v, want nil", err)
+	}
+	if want := "HELLO\nout.txt"; string(got) != want {
+		t.Errorf("Command().Process() = %q, want %q", got, want)
+	}
+}
+
+func TestCommand_Fails(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	sut := postprocess.Command("sh", "-c", "echo bad input >&2; exit 1")
+
+	_, err := sut.Process(context.Background(), "out.txt", nil)
+
+	if err == nil || !strings.Contains(err.Error(), "bad input") {
+		t.Errorf("Command().Process() = %v, want error containing stderr", err)
+	}
+}
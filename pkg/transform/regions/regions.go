@@ -0,0 +1,159 @@
+/*
+Package regions implements protected regions: marker-delimited blocks of an
+output file that hold hand-written content, which is preserved when the file
+is regenerated.
+
+A region begins on a line containing the begin marker followed by the region
+name, and ends on the next line containing the end marker. For example, with
+the markers "fhenix:begin" and "fhenix:end":
+
+	// fhenix:begin imports
+	import "fmt"
+	// fhenix:end
+*/
+package regions
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrUnterminated is returned when a region is not closed by an end marker.
+	ErrUnterminated = errors.New("protected region is not terminated")
+
+	// ErrUnexpectedEnd is returned when an end marker does not close a region.
+	ErrUnexpectedEnd = errors.New("end marker outside of a protected region")
+
+	// ErrNested is returned when a region begins inside of another region.
+	ErrNested = errors.New("protected regions may not be nested")
+
+	// ErrMissingName is returned when a begin marker is not followed by a name.
+	ErrMissingName = errors.New("protected region has no name")
+
+	// ErrDuplicate is returned when two regions have the same name.
+	ErrDuplicate = errors.New("duplicate protected region")
+)
+
+// OrphanedError reports a protected region of an existing file that is no
+// longer rendered, and whose content was therefore discarded.
+type OrphanedError struct {
+	// Path is the path of the output file.
+	Path string
+
+	// Name is the name of the orphaned region.
+	Name string
+}
+
+func (e *OrphanedError) Error() string {
+	return fmt.Sprintf("%s: protected region '%s' is no longer generated; its content was discarded", e.Path, e.Name)
+}
+
+var _ error = (*OrphanedError)(nil)
+
+// Markers are the markers that delimit protected regions.
+type Markers struct {
+	Begin string
+	End   string
+}
+
+// Region is a single protected region of a file.
+type Region struct {
+	// Name is the name of the region, following the begin marker.
+	Name string
+
+	// Content is the content between the begin and end marker lines.
+	Content string
+
+	// begin and end are the indices of the marker lines.
+	begin, end int
+}
+
+// Parse returns the protected regions of the content, in order.
+func (m Markers) Parse(content []byte) ([]*Region, error) {
+	_, regions, err := m.parse(content)
+	return regions, err
+}
+
+func (m Markers) parse(content []byte) ([]string, []*Region, error) {
+	lines := strings.SplitAfter(string(content), "\n")
+	var regions []*Region
+	var current *Region
+	seen := map[string]struct{}{}
+	for i, line := range lines {
+		if index := strings.Index(line, m.Begin); index >= 0 {
+			if current != nil {
+				return nil, nil, fmt.Errorf("line %d: %w", i+1, ErrNested)
+			}
+			fields := strings.Fields(line[index+len(m.Begin):])
+			if len(fields) == 0 {
+				return nil, nil, fmt.Errorf("line %d: %w", i+1, ErrMissingName)
+			}
+			name := fields[0]
+			if _, ok := seen[name]; ok {
+				return nil, nil, fmt.Errorf("line %d: %w '%v'", i+1, ErrDuplicate, name)
+			}
+			seen[name] = struct{}{}
+			current = &Region{Name: name, begin: i}
+			continue
+		}
+		if strings.Contains(line, m.End) {
+			if current == nil {
+				return nil, nil, fmt.Errorf("line %d: %w", i+1, ErrUnexpectedEnd)
+			}
+			current.end = i
+			current.Content = strings.Join(lines[current.begin+1:i], "")
+			regions = append(regions, current)
+			current = nil
+		}
+	}
+	if current != nil {
+		return nil, nil, fmt.Errorf("line %d: %w '%v'", current.begin+1, ErrUnterminated, current.Name)
+	}
+	return lines, regions, nil
+}
+
+// Splice returns the rendered content, with the content of each of its regions
+// replaced by the content of the same-named region in the existing content.
+// The names of regions in the existing content that no longer appear in the
+// rendered content are returned as orphaned; their content is discarded.
+func (m Markers) Splice(rendered, existing []byte) (result []byte, orphaned []string, err error) {
+	lines, regions, err := m.parse(rendered)
+	if err != nil {
+		return nil, nil, fmt.Errorf("rendered output: %w", err)
+	}
+	previous, err := m.Parse(existing)
+	if err != nil {
+		return nil, nil, fmt.Errorf("existing file: %w", err)
+	}
+	if len(previous) == 0 {
+		return rendered, nil, nil
+	}
+
+	kept := make(map[string]string, len(previous))
+	for _, region := range previous {
+		kept[region.Name] = region.Content
+	}
+
+	var sb strings.Builder
+	next := 0
+	for _, region := range regions {
+		content, ok := kept[region.Name]
+		if !ok {
+			continue
+		}
+		delete(kept, region.Name)
+		sb.WriteString(strings.Join(lines[next:region.begin+1], ""))
+		sb.WriteString(content)
+		next = region.end
+	}
+	sb.WriteString(strings.Join(lines[next:], ""))
+
+	for _, region := range previous {
+		if _, ok := kept[region.Name]; ok {
+			orphaned = append(orphaned, region.Name)
+		}
+	}
+	return []byte(sb.String()), orphaned, nil
+}
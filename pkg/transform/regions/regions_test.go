@@ -0,0 +1,113 @@
+package regions_test
+
+import (
+	"testing"
+
+	"github.com/friendly-fhir/fhenix/pkg/transform/regions"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+var markers = regions.Markers{Begin: "BEGIN", End: "END"}
+
+func TestMarkers_Splice(t *testing.T) {
+	testCases := []struct {
+		name         string
+		rendered     string
+		existing     string
+		want         string
+		wantOrphaned []string
+		wantErr      error
+	}{
+		{
+			name:     "no existing file",
+			rendered: "// BEGIN a\ndefault\n// END\n",
+			want:     "// BEGIN a\ndefault\n// END\n",
+		}, {
+			name:     "existing content is preserved",
+			rendered: "header\n// BEGIN a\ndefault\n// END\nfooter v2\n",
+			existing: "header\n// BEGIN a\nhand\nwritten\n// END\nfooter\n",
+			want:     "header\n// BEGIN a\nhand\nwritten\n// END\nfooter v2\n",
+		}, {
+			name:     "empty existing region is preserved",
+			rendered: "// BEGIN a\ndefault\n// END\n",
+			existing: "// BEGIN a\n// END\n",
+			want:     "// BEGIN a\n// END\n",
+		}, {
+			name:     "regions are matched by name",
+			rendered: "// BEGIN b\ndefault b\n// END\n// BEGIN a\ndefault a\n// END\n// BEGIN c\ndefault c\n// END\n",
+			existing: "// BEGIN a\nkept a\n// END\n// BEGIN b\nkept b\n// END\n",
+			want:     "// BEGIN b\nkept b\n// END\n// BEGIN a\nkept a\n// END\n// BEGIN c\ndefault c\n// END\n",
+		}, {
+			name:         "orphaned regions are reported",
+			rendered:     "// BEGIN a\ndefault\n// END\n",
+			existing:     "// BEGIN a\nkept\n// END\n// BEGIN gone\nlost\n// END\n",
+			want:         "// BEGIN a\nkept\n// END\n",
+			wantOrphaned: []string{"gone"},
+		}, {
+			name:     "region name ends at whitespace",
+			rendered: "<!-- BEGIN a -->\ndefault\n<!-- END -->\n",
+			existing: "<!-- BEGIN a -->\nkept\n<!-- END -->\n",
+			want:     "<!-- BEGIN a -->\nkept\n<!-- END -->\n",
+		}, {
+			name:     "unterminated rendered region",
+			rendered: "// BEGIN a\ndefault\n",
+			existing: "// BEGIN a\nkept\n// END\n",
+			wantErr:  regions.ErrUnterminated,
+		}, {
+			name:     "unterminated existing region",
+			rendered: "// BEGIN a\ndefault\n// END\n",
+			existing: "// BEGIN a\nkept\n",
+			wantErr:  regions.ErrUnterminated,
+		}, {
+			name:     "nested regions",
+			rendered: "// BEGIN a\n// BEGIN b\n// END\n// END\n",
+			wantErr:  regions.ErrNested,
+		}, {
+			name:     "unexpected end",
+			rendered: "// END\n",
+			wantErr:  regions.ErrUnexpectedEnd,
+		}, {
+			name:     "missing name",
+			rendered: "// BEGIN\n// END\n",
+			wantErr:  regions.ErrMissingName,
+		}, {
+			name:     "duplicate name",
+			rendered: "// BEGIN a\n// END\n// BEGIN a\n// END\n",
+			wantErr:  regions.ErrDuplicate,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, orphaned, err := markers.Splice([]byte(tc.rendered), []byte(tc.existing))
+
+			if got, want := err, tc.wantErr; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+				t.Fatalf("Markers.Splice() = %v, want %v", got, want)
+			}
+			if got, want := string(got), tc.want; got != want {
+				t.Errorf("Markers.Splice() = %q, want %q", got, want)
+			}
+			if got, want := orphaned, tc.wantOrphaned; !cmp.Equal(got, want) {
+				t.Errorf("Markers.Splice() orphaned = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestMarkers_Parse(t *testing.T) {
+	content := "// BEGIN a\none\ntwo\n// END\n// BEGIN b\n// END\n"
+
+	got, err := markers.Parse([]byte(content))
+	if err != nil {
+		t.Fatalf("Markers.Parse() = %v, want nil", err)
+	}
+
+	want := []*regions.Region{
+		{Name: "a", Content: "one\ntwo\n"},
+		{Name: "b", Content: ""},
+	}
+	if !cmp.Equal(got, want, cmpopts.IgnoreUnexported(regions.Region{})) {
+		t.Errorf("Markers.Parse() = %v, want %v", got, want)
+	}
+}
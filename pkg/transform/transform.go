@@ -1,28 +1,54 @@
 package transform
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
 	"io"
+	"io/fs"
+	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	texttemplate "text/template"
 
 	"github.com/friendly-fhir/fhenix/internal/templatefuncs"
 	"github.com/friendly-fhir/fhenix/pkg/config"
 	"github.com/friendly-fhir/fhenix/pkg/filter"
+	"github.com/friendly-fhir/fhenix/pkg/transform/internal/emit"
 	"github.com/friendly-fhir/fhenix/pkg/transform/internal/template"
 	"github.com/friendly-fhir/fhenix/pkg/transform/internal/transformer"
+	"github.com/friendly-fhir/fhenix/pkg/transform/postprocess"
+	"github.com/friendly-fhir/fhenix/pkg/transform/regions"
 )
 
 // Transform represents a transformation to be applied to the input definitions.
 type Transform struct {
+	name     string
+	vars     map[string]any
 	include  filter.Filters
 	exclude  filter.Filters
 	output   template.Template
 	template template.Template
+	files    []string
+	fsys     fs.FS
+	mode     config.Mode
+	pipeline postprocess.Pipeline
+	regions  *regions.Markers
+	reporter templatefuncs.Reporter
+	digest   string
 }
 
 type transformConfig struct {
 	funcs    Funcs
 	reporter templatefuncs.Reporter
+	fsys     fs.FS
+	strict   bool
 }
 
 type Option interface {
@@ -47,6 +73,24 @@ func WithReporter(r templatefuncs.Reporter) Option {
 	})
 }
 
+// WithFS returns an [Option] that reads the relative template and func paths
+// of the transform from the given file system, such as a template pack that is
+// embedded into a Go program. This takes precedence over the FS of the config.
+func WithFS(fsys fs.FS) Option {
+	return option(func(c *transformConfig) {
+		c.fsys = fsys
+	})
+}
+
+// WithStrict returns an [Option] that makes the transform strict, even if it
+// is not strict in the config. Errors reported by template funcs and modules
+// fail the template, as do missing map keys.
+func WithStrict(strict bool) Option {
+	return option(func(c *transformConfig) {
+		c.strict = strict
+	})
+}
+
 type Funcs map[string]any
 
 func New(mode config.Mode, transform *config.Transform, opts ...Option) (*Transform, error) {
@@ -57,42 +101,183 @@ func New(mode config.Mode, transform *config.Transform, opts ...Option) (*Transf
 	for _, opt := range opts {
 		opt.set(&cfg)
 	}
+	if cfg.fsys == nil {
+		cfg.fsys = transform.FS
+	}
+	if transform.Mode != "" {
+		mode = transform.Mode
+	}
 	engine, err := template.FromString(string(mode))
 	if err != nil {
 		return nil, err
 	}
 
-	funcs, err := transformer.FuncsFromConfig(transform.Funcs, cfg.reporter)
-	if err != nil {
-		return nil, err
+	strict := cfg.strict || transform.Strict
+	reporter := cfg.reporter
+	if strict {
+		reporter = transformer.StrictReporter{}
+	}
+
+	files := templateFiles(transform)
+	vars := templatefuncs.NewVarsFuncs(reporter, transform.Vars)
+
+	// The funcs of the program are available to func templates and scripts,
+	// as well as to the templates themselves, and take precedence over both.
+	base := make(map[string]any, len(vars)+len(cfg.funcs))
+	for name, fn := range vars {
+		base[name] = fn
 	}
 	for name, fn := range cfg.funcs {
+		base[name] = fn
+	}
+	funcs, err := transformer.FuncsFromConfig(transform.Funcs,
+		transformer.WithFuncs(base),
+		transformer.WithReporter(reporter),
+		transformer.WithFS(cfg.fsys),
+		transformer.WithStrict(strict),
+	)
+	if err != nil {
+		return nil, newTemplateError(cfg.fsys, mode, files, err)
+	}
+	for name, fn := range base {
 		funcs[name] = fn
 	}
 
 	tmpl, err := transformer.NewTemplate(engine, transform.Templates,
 		transformer.WithFuncs(funcs),
-		transformer.WithReporter(cfg.reporter),
+		transformer.WithReporter(reporter),
+		transformer.WithFS(cfg.fsys),
+		transformer.WithStrict(strict),
 	)
+	if err != nil {
+		return nil, newTemplateError(cfg.fsys, mode, files, err)
+	}
+
+	output := engine.New("").Funcs(templatefuncs.NewFuncs(reporter)).Funcs(vars)
+	if strict {
+		output = output.Option("missingkey=error")
+	}
+	output, err = output.Parse(transform.OutputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline, err := postprocess.FromConfig(transform.PostProcess)
 	if err != nil {
 		return nil, err
 	}
 
-	output, err := engine.New("").Funcs(templatefuncs.NewFuncs(cfg.reporter)).Parse(transform.OutputPath)
+	digest, err := digestOf(cfg.fsys, mode, strict, transform)
 	if err != nil {
 		return nil, err
 	}
 
 	result := &Transform{
+		name:     transform.Name,
 		template: tmpl,
-		include:  filter.New(transform.Include...),
-		exclude:  filter.New(transform.Exclude...),
+		files:    files,
+		fsys:     cfg.fsys,
+		mode:     mode,
+		vars:     transform.Vars,
+		include:  filter.New(transform.Include...).WithVars(transform.Vars),
+		exclude:  filter.New(transform.Exclude...).WithVars(transform.Vars),
 		output:   output,
+		pipeline: pipeline,
+		reporter: cfg.reporter,
+		digest:   digest,
+	}
+	if pr := transform.ProtectedRegions; pr != nil {
+		result.regions = &regions.Markers{Begin: pr.Begin, End: pr.End}
 	}
 
 	return result, nil
 }
 
+// digestOf computes a digest of everything that affects the output of the
+// transform: the mode, strictness, output path, filters, and the content of
+// every template and func file. Strictness is included so that outputs that
+// were generated with errors are regenerated when the transform becomes strict.
+func digestOf(fsys fs.FS, mode config.Mode, strict bool, transform *config.Transform) (string, error) {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "name=%s\nmode=%s\nstrict=%t\noutput-path=%s\nvars=%v\n", transform.Name, mode, strict, transform.OutputPath, transform.Vars)
+	// Filters are hashed as JSON, since formatting them would hash the
+	// addresses of their optional fields rather than the values.
+	for _, filter := range transform.Include {
+		content, err := json.Marshal(filter)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "include=%s\n", content)
+	}
+	for _, filter := range transform.Exclude {
+		content, err := json.Marshal(filter)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "exclude=%s\n", content)
+	}
+	if pr := transform.ProtectedRegions; pr != nil {
+		fmt.Fprintf(hash, "protected-regions=%q,%q\n", pr.Begin, pr.End)
+	}
+	for _, step := range transform.PostProcess {
+		fmt.Fprintf(hash, "post-process=%s%q\n", step.Format, step.Command)
+		// Commands given by path are usually project scripts, which may change
+		// between runs; commands looked up in the PATH are assumed to be stable.
+		if len(step.Command) > 0 && filepath.IsAbs(step.Command[0]) {
+			if content, err := os.ReadFile(step.Command[0]); err == nil {
+				hash.Write(content)
+			}
+		}
+	}
+	for _, files := range []struct {
+		kind  string
+		files map[string]string
+	}{
+		{"template", transform.Templates},
+		{"func", transform.Funcs},
+	} {
+		names := make([]string, 0, len(files.files))
+		for name := range files.files {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			content, err := transformer.ReadFile(fsys, files.files[name])
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(hash, "%s=%s:%d\n", files.kind, name, len(content))
+			hash.Write(content)
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Name returns the name of the transform from the config, which may be empty.
+func (t *Transform) Name() string {
+	if t == nil {
+		return ""
+	}
+	return t.name
+}
+
+// Vars returns the user-defined variables of the transform.
+func (t *Transform) Vars() map[string]any {
+	if t == nil {
+		return nil
+	}
+	return t.vars
+}
+
+// Digest returns a digest of the configuration and template content of this
+// transform, which changes whenever the rendered output may change.
+func (t *Transform) Digest() string {
+	if t == nil {
+		return ""
+	}
+	return t.digest
+}
+
 // CanTransform returns true if the given value should be included in the
 // output transformation.
 func (t *Transform) CanTransform(v any) bool {
@@ -107,6 +292,33 @@ func (t *Transform) CanTransform(v any) bool {
 	return !t.exclude.Matches(v)
 }
 
+// Explanation explains whether a transform would transform an entity.
+type Explanation struct {
+	// Include explains each of the include filters of the transform.
+	Include []*filter.Explanation
+
+	// Exclude explains each of the exclude filters of the transform.
+	Exclude []*filter.Explanation
+
+	// Matched is true if the transform would transform the entity, which is
+	// when any include filter matches, or there are none, and no exclude
+	// filter matches.
+	Matched bool
+}
+
+// Explain explains whether the given value would be included in the output
+// transformation, filter by filter.
+func (t *Transform) Explain(v any) *Explanation {
+	if t == nil {
+		return &Explanation{}
+	}
+	return &Explanation{
+		Include: t.include.Explain(v),
+		Exclude: t.exclude.Explain(v),
+		Matched: t.CanTransform(v),
+	}
+}
+
 // OutputPath returns the output path for the given value.
 // The output path is always specified as an absolute path.
 func (t *Transform) OutputPath(v any) (string, error) {
@@ -121,10 +333,74 @@ func (t *Transform) OutputPath(v any) (string, error) {
 	return filepath.FromSlash(strings.TrimSpace(sb.String())), nil
 }
 
-// Execute the transformation with the given data.
+// File is a file emitted by a transformation, in addition to its primary
+// output, with a '{{ file "path" }}...{{ endfile }}' block.
+type File = emit.File
+
+// Render executes the transformation with the given data. The content outside
+// of any file block is returned as the primary output, along with every file
+// emitted by a file block. Emitted paths are returned as given in the template.
+func (t *Transform) Render(data any) ([]byte, []*File, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, nil, err
+	}
+	return emit.Split(buf.Bytes())
+}
+
+// Execute the transformation with the given data. Errors from executing a
+// template file are returned as a [TemplateError] that locates the error in
+// the file.
 func (t *Transform) Execute(w io.Writer, data any) error {
 	if t == nil || t.template == nil {
 		return nil
 	}
-	return t.template.Execute(w, data)
+	err := t.template.Execute(w, data)
+	var execErr texttemplate.ExecError
+	var escapeErr *htmltemplate.Error
+	if errors.As(err, &execErr) || errors.As(err, &escapeErr) {
+		return newTemplateError(t.fsys, t.mode, t.files, err)
+	}
+	return err
+}
+
+// templateFiles returns the paths of every template and func file of the
+// transform, which errors from templates may refer to.
+func templateFiles(transform *config.Transform) []string {
+	result := make([]string, 0, len(transform.Templates)+len(transform.Funcs))
+	for _, file := range transform.Templates {
+		result = append(result, file)
+	}
+	for _, file := range transform.Funcs {
+		result = append(result, file)
+	}
+	return result
+}
+
+// Protect splices the protected regions of the existing content of the output
+// at the given path into the rendered content. Regions of the existing content
+// that are no longer rendered are reported as orphaned to the reporter.
+func (t *Transform) Protect(path string, rendered, existing []byte) ([]byte, error) {
+	if t == nil || t.regions == nil || len(existing) == 0 {
+		return rendered, nil
+	}
+	result, orphaned, err := t.regions.Splice(rendered, existing)
+	if err != nil {
+		return nil, err
+	}
+	if t.reporter != nil {
+		for _, name := range orphaned {
+			t.reporter.Report(&regions.OrphanedError{Path: path, Name: name})
+		}
+	}
+	return result, nil
+}
+
+// PostProcess passes the rendered content of the output at the given path
+// through the post-process pipeline of this transform.
+func (t *Transform) PostProcess(ctx context.Context, path string, content []byte) ([]byte, error) {
+	if t == nil {
+		return content, nil
+	}
+	return t.pipeline.Process(ctx, path, content)
 }
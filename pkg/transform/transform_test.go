@@ -1,10 +1,14 @@
 package transform_test
 
 import (
+	"errors"
 	"io/fs"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
 
+	"github.com/friendly-fhir/fhenix/internal/templatefuncs"
 	"github.com/friendly-fhir/fhenix/pkg/config"
 	"github.com/friendly-fhir/fhenix/pkg/model"
 	"github.com/friendly-fhir/fhenix/pkg/transform"
@@ -25,6 +29,11 @@ func TestNew(t *testing.T) {
 			mode:    config.Mode("invalid"),
 			input:   &config.Transform{},
 			wantErr: cmpopts.AnyError,
+		}, {
+			name:    "bad transform mode returns error",
+			mode:    config.Mode("text"),
+			input:   &config.Transform{Mode: config.Mode("invalid")},
+			wantErr: cmpopts.AnyError,
 		}, {
 			name: "bad function path",
 			mode: config.Mode("text"),
@@ -233,3 +242,413 @@ func TestTransformOutputPath(t *testing.T) {
 		})
 	}
 }
+
+func TestTransformRender(t *testing.T) {
+	for _, mode := range []config.Mode{"text", "html"} {
+		t.Run(string(mode), func(t *testing.T) {
+			transform, err := transform.New(mode, &config.Transform{
+				Templates: map[string]string{
+					"main": "testdata/emit.tmpl",
+				},
+			})
+			if err != nil {
+				t.Fatalf("New() = %v", err)
+			}
+
+			primary, files, err := transform.Render("value")
+			if err != nil {
+				t.Fatalf("Render() = %v", err)
+			}
+
+			if got, want := string(primary), "primary\n"; got != want {
+				t.Errorf("Render() primary = %q, want %q", got, want)
+			}
+			var paths []string
+			for _, file := range files {
+				paths = append(paths, file.Path)
+			}
+			if got, want := paths, []string{"types.h", "types.cc"}; !cmp.Equal(got, want) {
+				t.Errorf("Render() files = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestTransformRender_FS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"pack/main.tmpl":  {Data: []byte(`{{ shout . }}`)},
+		"pack/shout.tmpl": {Data: []byte(`{{ . | string.Upper }}!`)},
+	}
+	cfg := &config.Transform{
+		Funcs: map[string]string{
+			"shout": "pack/shout.tmpl",
+		},
+		Templates: map[string]string{
+			"main": "pack/main.tmpl",
+		},
+	}
+
+	testCases := []struct {
+		name    string
+		cfg     *config.Transform
+		opts    []transform.Option
+		want    string
+		wantErr error
+	}{
+		{
+			name: "file system from option",
+			cfg:  cfg,
+			opts: []transform.Option{transform.WithFS(fsys)},
+			want: "VALUE!",
+		}, {
+			name: "file system from config",
+			cfg: func() *config.Transform {
+				cfg := *cfg
+				cfg.FS = fsys
+				return &cfg
+			}(),
+			want: "VALUE!",
+		}, {
+			name:    "file does not exist in file system",
+			cfg:     cfg,
+			opts:    []transform.Option{transform.WithFS(fstest.MapFS{})},
+			wantErr: fs.ErrNotExist,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			transform, err := transform.New(config.Mode("text"), tc.cfg, tc.opts...)
+			if got, want := err, tc.wantErr; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+				t.Fatalf("New() = %v, want %v", got, want)
+			}
+			if err != nil {
+				return
+			}
+
+			got, _, err := transform.Render("value")
+			if err != nil {
+				t.Fatalf("Render() = %v", err)
+			}
+			if got, want := string(got), tc.want; got != want {
+				t.Errorf("Render() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestTransformRender_Strict(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.tmpl":     {Data: []byte(`{{ if required . }}required{{ else }}optional{{ end }}`)},
+		"required.tmpl": {Data: []byte(`{{/* returns: bool */}}{{ . }}`)},
+	}
+
+	testCases := []struct {
+		name       string
+		strict     bool
+		input      any
+		want       string
+		wantErr    error
+		wantReport bool
+	}{
+		{
+			name:  "typed return is used in condition",
+			input: true,
+			want:  "required",
+		}, {
+			name:       "func error is reported",
+			input:      "maybe",
+			want:       "optional",
+			wantReport: true,
+		}, {
+			name:    "func error fails when strict",
+			strict:  true,
+			input:   "maybe",
+			wantErr: cmpopts.AnyError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var reported bool
+			cfg := &config.Transform{
+				Strict: tc.strict,
+				Funcs: map[string]string{
+					"required": "required.tmpl",
+				},
+				Templates: map[string]string{
+					"main": "main.tmpl",
+				},
+			}
+			transform, err := transform.New(config.Mode("text"), cfg,
+				transform.WithFS(fsys),
+				transform.WithReporter(templatefuncs.ReporterFunc(func(error) { reported = true })),
+			)
+			if err != nil {
+				t.Fatalf("New() = %v", err)
+			}
+
+			got, _, err := transform.Render(tc.input)
+
+			if got, want := err, tc.wantErr; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+				t.Fatalf("Render() = %v, want %v", got, want)
+			}
+			if got, want := reported, tc.wantReport; got != want {
+				t.Errorf("Render() reported = %v, want %v", got, want)
+			}
+			if got, want := string(got), tc.want; err == nil && got != want {
+				t.Errorf("Render() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestTransformRender_StrictTemplates(t *testing.T) {
+	fsys := fstest.MapFS{
+		"missing-key.tmpl":   {Data: []byte(`{{ .missing }}`)},
+		"undefined-var.tmpl": {Data: []byte(`{{ vars.Get "missing" }}`)},
+		"script.tmpl":        {Data: []byte(`{{ lookup }}`)},
+		"lookup.expr":        {Data: []byte(`vars.Get("missing")`)},
+	}
+
+	testCases := []struct {
+		name       string
+		template   string
+		cfgStrict  bool
+		optStrict  bool
+		want       string
+		wantErr    error
+		wantReport bool
+	}{
+		{
+			name:     "missing key renders no value",
+			template: "missing-key.tmpl",
+			want:     "<no value>",
+		}, {
+			name:      "missing key fails when strict",
+			template:  "missing-key.tmpl",
+			cfgStrict: true,
+			wantErr:   cmpopts.AnyError,
+		}, {
+			name:       "module error is reported",
+			template:   "undefined-var.tmpl",
+			want:       "<no value>",
+			wantReport: true,
+		}, {
+			name:      "module error fails when strict",
+			template:  "undefined-var.tmpl",
+			optStrict: true,
+			wantErr:   templatefuncs.ErrUndefinedVar,
+		}, {
+			name:      "module error in script fails when strict",
+			template:  "script.tmpl",
+			cfgStrict: true,
+			wantErr:   templatefuncs.ErrUndefinedVar,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var reported bool
+			cfg := &config.Transform{
+				Strict: tc.cfgStrict,
+				Funcs: map[string]string{
+					"lookup": "lookup.expr",
+				},
+				Templates: map[string]string{
+					"main": tc.template,
+				},
+			}
+			transform, err := transform.New(config.Mode("text"), cfg,
+				transform.WithFS(fsys),
+				transform.WithStrict(tc.optStrict),
+				transform.WithReporter(templatefuncs.ReporterFunc(func(error) { reported = true })),
+			)
+			if err != nil {
+				t.Fatalf("New() = %v", err)
+			}
+
+			got, _, err := transform.Render(map[string]any{})
+
+			if got, want := err, tc.wantErr; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+				t.Fatalf("Render() = %v, want %v", got, want)
+			}
+			if err != nil && !strings.HasPrefix(err.Error(), tc.template+":1:") {
+				t.Errorf("Render() = %v, want error prefixed by template file", err)
+			}
+			if got, want := reported, tc.wantReport; got != want {
+				t.Errorf("Render() reported = %v, want %v", got, want)
+			}
+			if got, want := string(got), tc.want; err == nil && got != want {
+				t.Errorf("Render() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestTransformRender_ProgramFuncs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.tmpl":   {Data: []byte(`{{ script "widget" }} {{ tmpl "gadget" }}`)},
+		"script.expr": {Data: []byte("// params: name string\nprefix(name)")},
+		"fn.tmpl":     {Data: []byte(`{{ prefix . }}`)},
+	}
+	cfg := &config.Transform{
+		Funcs: map[string]string{
+			"script": "script.expr",
+			"tmpl":   "fn.tmpl",
+		},
+		Templates: map[string]string{
+			"main": "main.tmpl",
+		},
+	}
+	transform, err := transform.New(config.Mode("text"), cfg,
+		transform.WithFS(fsys),
+		transform.WithFuncs(transform.Funcs{
+			"prefix": func(name string) string { return "x." + name },
+		}),
+	)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+
+	got, _, err := transform.Render(map[string]any{})
+	if err != nil {
+		t.Fatalf("Render() = %v", err)
+	}
+	if got, want := string(got), "x.widget x.gadget"; got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
+
+func TestTransform_TemplateError(t *testing.T) {
+	fsys := fstest.MapFS{
+		"exec.tmpl":  {Data: []byte("Name: {{ .Name }}\n\tType: {{ index .Name 10 }}\n")},
+		"parse.tmpl": {Data: []byte("Name: {{ .Name }}\n{{ .Name | undefined }}\n")},
+		"call.tmpl":  {Data: []byte("Name: {{ fn .Name }}\n")},
+		"fn.tmpl":    {Data: []byte("{{/* returns: string */}}\n{{ index . 10 }}\n")},
+		"badfn.tmpl": {Data: []byte("{{ . }}\n{{ end }}\n")},
+		"html.tmpl":  {Data: []byte("<p>{{ .Name }}</p>\n<a href=\"{{ if .Name }}/path/{{ else }}/search?q={{ end }}{{ .Name }}\">\n")},
+	}
+
+	testCases := []struct {
+		name        string
+		mode        config.Mode
+		template    string
+		fn          string
+		wantErr     *transform.TemplateError
+		wantExcerpt string
+	}{
+		{
+			name:     "execution error",
+			template: "exec.tmpl",
+			wantErr: &transform.TemplateError{
+				File:   "exec.tmpl",
+				Line:   2,
+				Column: 11,
+				Source: "\tType: {{ index .Name 10 }}",
+			},
+			wantExcerpt: strings.Join([]string{
+				" --> exec.tmpl:2:11",
+				"  |",
+				"2 | \tType: {{ index .Name 10 }}",
+				"  | \t         ^",
+			}, "\n") + "\n",
+		}, {
+			name:     "parse error",
+			template: "parse.tmpl",
+			wantErr: &transform.TemplateError{
+				File:   "parse.tmpl",
+				Line:   2,
+				Source: "{{ .Name | undefined }}",
+			},
+			wantExcerpt: strings.Join([]string{
+				" --> parse.tmpl:2",
+				"  |",
+				"2 | {{ .Name | undefined }}",
+			}, "\n") + "\n",
+		}, {
+			name:     "func execution error",
+			template: "call.tmpl",
+			fn:       "fn.tmpl",
+			wantErr: &transform.TemplateError{
+				File:   "fn.tmpl",
+				Line:   2,
+				Column: 4,
+				Source: "{{ index . 10 }}",
+			},
+			wantExcerpt: strings.Join([]string{
+				" --> fn.tmpl:2:4",
+				"  |",
+				"2 | {{ index . 10 }}",
+				"  |    ^",
+			}, "\n") + "\n",
+		}, {
+			name:     "func parse error",
+			template: "call.tmpl",
+			fn:       "badfn.tmpl",
+			wantErr: &transform.TemplateError{
+				File:   "badfn.tmpl",
+				Line:   2,
+				Source: "{{ end }}",
+			},
+			wantExcerpt: strings.Join([]string{
+				" --> badfn.tmpl:2",
+				"  |",
+				"2 | {{ end }}",
+			}, "\n") + "\n",
+		}, {
+			name:     "html escape error",
+			mode:     config.ModeHTML,
+			template: "html.tmpl",
+			wantErr: &transform.TemplateError{
+				File:   "html.tmpl",
+				Line:   2,
+				Column: 62,
+				Source: `<a href="{{ if .Name }}/path/{{ else }}/search?q={{ end }}{{ .Name }}">`,
+			},
+			wantExcerpt: strings.Join([]string{
+				" --> html.tmpl:2:62",
+				"  |",
+				`2 | <a href="{{ if .Name }}/path/{{ else }}/search?q={{ end }}{{ .Name }}">`,
+				"  |                                                              ^",
+			}, "\n") + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Transform{
+				Strict: true,
+				Templates: map[string]string{
+					"main": tc.template,
+				},
+			}
+			if tc.fn != "" {
+				cfg.Funcs = map[string]string{"fn": tc.fn}
+			}
+			mode := tc.mode
+			if mode == "" {
+				mode = config.ModeText
+			}
+			var got *transform.TemplateError
+			tr, err := transform.New(mode, cfg, transform.WithFS(fsys))
+			if err == nil {
+				_, _, err = tr.Render(map[string]any{"Name": "Patient"})
+			}
+
+			if !errors.As(err, &got) {
+				t.Fatalf("Render() = %v, want TemplateError", err)
+			}
+			if diff := cmp.Diff(tc.wantErr, got, cmpopts.IgnoreFields(transform.TemplateError{}, "Message", "Err")); diff != "" {
+				t.Errorf("Render() mismatch (-want +got):\n%s", diff)
+			}
+			var sb strings.Builder
+			if err := got.WriteExcerpt(&sb); err != nil {
+				t.Fatalf("WriteExcerpt() = %v", err)
+			}
+			if got, want := sb.String(), tc.wantExcerpt; got != want {
+				t.Errorf("WriteExcerpt() = %q, want %q", got, want)
+			}
+		})
+	}
+}